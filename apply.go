@@ -0,0 +1,300 @@
+package dbdiff
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+const DefaultApplyTxBatchSize = 50
+
+type ApplyOptions struct {
+	// DryRun prints the plan without connecting to the database.
+	DryRun bool
+	// ContinueOnError keeps applying the remaining statements after a failure.
+	// The other statements of a failed transaction are then applied again
+	// one at a time.
+	ContinueOnError bool
+	// TxBatchSize is the number of consecutive DML statements per transaction.
+	TxBatchSize int
+	// Out receives the statements and their timings, nil discards them.
+	Out io.Writer
+	// Safety admits lossy and blocking changes, nil admits only safe ones.
+	Safety *SafetyOptions
+}
+
+type ApplyResult struct {
+	Statement *PlanStatement
+	Applied   bool
+	// RolledBack is set for statements undone by the rollback of a failed
+	// transaction.
+	RolledBack bool
+	// ImplicitCommit is set for DDL that the database commits on its own.
+	ImplicitCommit bool
+	Duration       time.Duration
+	Err            error
+}
+
+type ApplyReport struct {
+	DryRun bool
+	// Statements is the size of the plan, the statements after a failure
+	// that stopped the apply have no result.
+	Statements int
+	Results    []*ApplyResult
+	Duration   time.Duration
+}
+
+func (report *ApplyReport) Applied() int {
+	count := 0
+	for _, result := range report.Results {
+		if result.Applied {
+			count++
+		}
+	}
+	return count
+}
+
+func (report *ApplyReport) Failed() int {
+	count := 0
+	for _, result := range report.Results {
+		if result.Err != nil {
+			count++
+		}
+	}
+	return count
+}
+
+func (report *ApplyReport) String() string {
+	if report.DryRun {
+		return fmt.Sprintf("dry run: %d statements not applied", len(report.Results))
+	}
+	implicit := 0
+	for _, result := range report.Results {
+		if result.Applied && result.ImplicitCommit {
+			implicit++
+		}
+	}
+	return fmt.Sprintf("applied %d/%d statements, %d failed, %d committed implicitly, in %s",
+		report.Applied(), report.Statements, report.Failed(), implicit, report.Duration)
+}
+
+// Apply runs plan against conn, which is the new database of the diff the
+// plan was built from. Consecutive DML statements are grouped into
+// transactions where the driver supports it, DDL always runs on its own.
+func Apply(plan *Plan, conn *DBConn, opts *ApplyOptions) (*ApplyReport, error) {
+	if opts == nil {
+		opts = &ApplyOptions{}
+	}
+	out := opts.Out
+	if out == nil {
+		out = ioutil.Discard
+	}
+	applier := &applier{
+		driverName: conn.DriverName,
+		opts:       opts,
+		out:        out,
+		report:     &ApplyReport{DryRun: opts.DryRun},
+	}
+	if opts.DryRun {
+		applier.dryRun(plan)
+		return applier.report, nil
+	}
+	if err := plan.Check(opts.Safety); err != nil {
+		return nil, err
+	}
+
+	db, err := conn.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	report, err := applier.run(plan, db)
+	return report, conn.redact(err)
+}
+
+// run applies plan on one connection of db, so that the session settings of
+// the plan, such as SET FOREIGN_KEY_CHECKS, hold for all of its statements
+// and do not leak to the other connections of the pool.
+func (applier *applier) run(plan *Plan, db *sql.DB) (*ApplyReport, error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, &DataAccessError{Message: "get connection error", Err: err}
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	applier.report.Statements = len(plan.Statements)
+	applier.tpl = NewConnTemplate(conn)
+	err = applier.apply(plan)
+	applier.report.Duration = time.Since(start)
+	fmt.Fprintln(applier.out, "--", applier.report)
+	return applier.report, err
+}
+
+type applier struct {
+	driverName DriverType
+	tpl        *DBTemplate
+	opts       *ApplyOptions
+	out        io.Writer
+	report     *ApplyReport
+}
+
+func (applier *applier) dryRun(plan *Plan) {
+	applier.report.Statements = len(plan.Statements)
+	for i, statement := range plan.Statements {
+		result := &ApplyResult{
+			Statement:      statement,
+			ImplicitCommit: applier.implicitCommit(statement),
+		}
+		applier.report.Results = append(applier.report.Results, result)
+		applier.printStatement(i, len(plan.Statements), result)
+	}
+	fmt.Fprintln(applier.out, "--", applier.report)
+}
+
+func (applier *applier) apply(plan *Plan) error {
+	var (
+		statements = plan.Statements
+		batchSize  = applier.opts.TxBatchSize
+		firstErr   error
+	)
+	if batchSize <= 0 {
+		batchSize = DefaultApplyTxBatchSize
+	}
+
+	for i := 0; i < len(statements); {
+		group := []*PlanStatement{statements[i]}
+		if !statements[i].DDL && applier.driverName.TransactionalDML() {
+			for j := i + 1; j < len(statements) && !statements[j].DDL && len(group) < batchSize; j++ {
+				group = append(group, statements[j])
+			}
+		}
+
+		var err error
+		if len(group) == 1 {
+			err = applier.applySingle(i, len(statements), group[0], nil)
+		} else {
+			var results []*ApplyResult
+			results, err = applier.applyTx(i, len(statements), group)
+			if err != nil && applier.opts.ContinueOnError {
+				applier.applyEach(i, len(statements), group, results)
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		i += len(group)
+		if err != nil && !applier.opts.ContinueOnError {
+			break
+		}
+	}
+	return firstErr
+}
+
+// applySingle runs statement on its own, recording it in result when it has
+// one already.
+func (applier *applier) applySingle(idx, total int, statement *PlanStatement, result *ApplyResult) error {
+	if result == nil {
+		result = &ApplyResult{Statement: statement}
+		applier.report.Results = append(applier.report.Results, result)
+	}
+	result.ImplicitCommit = applier.implicitCommit(statement)
+	result.RolledBack = false
+	applier.printStatement(idx, total, result)
+
+	start := time.Now()
+	_, err := applier.tpl.Exec(statement.Sql)
+	result.Duration = time.Since(start)
+	result.Err = err
+	result.Applied = err == nil
+	applier.printResult(result)
+	return err
+}
+
+// applyEach runs the statements of a failed transaction one at a time, but
+// the one that failed it: those rolled back run again and those after it run
+// for the first time.
+func (applier *applier) applyEach(idx, total int, group []*PlanStatement, results []*ApplyResult) {
+	fmt.Fprintln(applier.out, "-- applying the transaction one statement at a time")
+	for i, statement := range group {
+		var result *ApplyResult
+		if i < len(results) {
+			if results[i].Err != nil {
+				continue
+			}
+			result = results[i]
+		}
+		applier.applySingle(idx+i, total, statement, result)
+	}
+}
+
+func (applier *applier) applyTx(idx, total int, group []*PlanStatement) ([]*ApplyResult, error) {
+	fmt.Fprintln(applier.out, "-- BEGIN")
+	results := make([]*ApplyResult, 0, len(group))
+	err := applier.tpl.InTransaction(func(tx Operations) error {
+		for i, statement := range group {
+			result := &ApplyResult{Statement: statement}
+			results = append(results, result)
+			applier.report.Results = append(applier.report.Results, result)
+			applier.printStatement(idx+i, total, result)
+
+			start := time.Now()
+			_, result.Err = tx.Exec(statement.Sql)
+			result.Duration = time.Since(start)
+			applier.printResult(result)
+			if result.Err != nil {
+				return result.Err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		fmt.Fprintln(applier.out, "-- COMMIT")
+		for _, result := range results {
+			result.Applied = true
+		}
+		return results, nil
+	}
+
+	if rollbackErr, ok := err.(*RollbackError); ok {
+		fmt.Fprintf(applier.out, "-- ROLLBACK failed: %s\n", rollbackErr.RollbackErr)
+		return results, err
+	}
+	fmt.Fprintln(applier.out, "-- ROLLBACK")
+	for _, result := range results {
+		result.RolledBack = result.Err == nil
+	}
+	return results, err
+}
+
+func (applier *applier) implicitCommit(statement *PlanStatement) bool {
+	return statement.DDL && !applier.driverName.TransactionalDDL()
+}
+
+func (applier *applier) printStatement(idx, total int, result *ApplyResult) {
+	kind := "DML"
+	if result.Statement.DDL {
+		kind = "DDL"
+	}
+	if result.ImplicitCommit {
+		kind += ", implicit commit"
+	}
+	if change := result.Statement.Change; change != nil && change.Severity != SeveritySafe {
+		kind += ", " + change.String()
+		if !applier.opts.Safety.Allows(change) {
+			kind += ", refused"
+		}
+	}
+	fmt.Fprintf(applier.out, "-- [%d/%d] %s %s\n%s;\n", idx+1, total, kind, result.Statement.TableName, result.Statement.Sql)
+}
+
+func (applier *applier) printResult(result *ApplyResult) {
+	if result.Err != nil {
+		fmt.Fprintf(applier.out, "-- failed after %s: %s\n", result.Duration, result.Err)
+	} else {
+		fmt.Fprintf(applier.out, "-- ok in %s\n", result.Duration)
+	}
+}
@@ -0,0 +1,89 @@
+package dbdiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestApply_DryRun(t *testing.T) {
+	plan := NewPlan()
+	plan.AddDDL("student", "ALTER TABLE student ADD COLUMN age int")
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (1)")
+
+	var out bytes.Buffer
+	report, err := Apply(plan, getDBConn(), &ApplyOptions{DryRun: true, Out: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "Apply dry run", "results", len(report.Results), 2)
+	verify(t, 2, "Apply dry run", "applied", report.Applied(), 0)
+	verify(t, 3, "Apply dry run", "implicit commit", report.Results[0].ImplicitCommit, true)
+	verify(t, 4, "Apply dry run", "output",
+		strings.Contains(out.String(), "-- [1/2] DDL, implicit commit student\nALTER TABLE student ADD COLUMN age int;"), true)
+	verify(t, 5, "Apply dry run", "summary", strings.Contains(out.String(), "dry run: 2 statements not applied"), true)
+}
+
+func TestApply_pinnedConnection(t *testing.T) {
+	tpl := rowsTemplate(t, nil)
+	result := rowsResults[t.Name()]
+	//a pooled statement would open a connection of its own
+	tpl.db.SetMaxIdleConns(0)
+
+	plan := NewPlan()
+	plan.AddDML("", "SET FOREIGN_KEY_CHECKS=0")
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (1)")
+	plan.AddDML("course", "DELETE FROM course WHERE id IN (1)")
+	plan.AddDDL("course", "ALTER TABLE course DROP COLUMN note")
+	plan.AddDML("", "SET FOREIGN_KEY_CHECKS=1")
+	applier := &applier{driverName: MYSQL, opts: &ApplyOptions{TxBatchSize: 2}, out: &bytes.Buffer{}, report: &ApplyReport{}}
+	report, err := applier.run(plan, tpl.db)
+	verify(t, 1, "Apply", "err", err, nil)
+	verify(t, 2, "Apply", "applied", report.Applied(), 5)
+	verify(t, 3, "Apply", "connections", result.opened, 1)
+	verify(t, 4, "Apply", "log", strings.Join(result.log, ";"),
+		"BEGIN;SET FOREIGN_KEY_CHECKS=0;DELETE FROM student WHERE id IN (1);COMMIT;DELETE FROM course WHERE id IN (1);ALTER TABLE course DROP COLUMN note;SET FOREIGN_KEY_CHECKS=1")
+}
+
+func TestApply_failedRollback(t *testing.T) {
+	tpl := rowsTemplate(t, nil)
+	rowsResults[t.Name()].failRollback = true
+
+	plan := NewPlan()
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (1)")
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (fail)")
+	var out bytes.Buffer
+	applier := &applier{driverName: MYSQL, opts: &ApplyOptions{}, out: &out, report: &ApplyReport{}}
+	report, err := applier.run(plan, tpl.db)
+	_, ok := err.(*RollbackError)
+	verify(t, 1, "Apply", "rollback error", ok, true)
+	verify(t, 2, "Apply", "rolled back", report.Results[0].RolledBack, false)
+	verify(t, 3, "Apply", "output", strings.Contains(out.String(), "-- ROLLBACK failed: rollback failed"), true)
+}
+
+func TestApply_continueOnError(t *testing.T) {
+	tpl := rowsTemplate(t, nil)
+	result := rowsResults[t.Name()]
+	tpl.db.SetMaxIdleConns(0)
+
+	plan := NewPlan()
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (1)")
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (fail)")
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (3)")
+	plan.AddDML("student", "DELETE FROM student WHERE id IN (4)")
+	continuing := &applier{driverName: MYSQL, opts: &ApplyOptions{TxBatchSize: 3, ContinueOnError: true}, out: &bytes.Buffer{}, report: &ApplyReport{}}
+	report, err := continuing.run(plan, tpl.db)
+	verify(t, 1, "Apply", "err", err != nil, true)
+	verify(t, 2, "Apply", "results", len(report.Results), 4)
+	verify(t, 3, "Apply", "applied", report.Applied(), 3)
+	verify(t, 4, "Apply", "failed", report.Failed(), 1)
+	verify(t, 5, "Apply", "retried", report.Results[0].Applied && !report.Results[0].RolledBack, true)
+	verify(t, 6, "Apply", "log", strings.Join(result.log, ";"),
+		"BEGIN;DELETE FROM student WHERE id IN (1);DELETE FROM student WHERE id IN (fail);ROLLBACK;"+
+			"DELETE FROM student WHERE id IN (1);DELETE FROM student WHERE id IN (3);DELETE FROM student WHERE id IN (4)")
+	verify(t, 7, "Apply", "summary", strings.HasPrefix(report.String(), "applied 3/4 statements, 1 failed"), true)
+
+	stopping := &applier{driverName: MYSQL, opts: &ApplyOptions{TxBatchSize: 3}, out: &bytes.Buffer{}, report: &ApplyReport{}}
+	report, _ = stopping.run(plan, tpl.db)
+	verify(t, 8, "Apply", "stopped", strings.HasPrefix(report.String(), "applied 0/4 statements, 1 failed"), true)
+}
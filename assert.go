@@ -6,7 +6,7 @@ import (
 )
 
 func AssertTypePtrOfSlice(ptr interface{}) bool {
-	return reflect.ValueOf(ptr).Type().Elem().Kind() == reflect.Slice
+	return assertTypePtrOf(ptr, reflect.Slice)
 }
 
 // ptr the point of the input
@@ -16,7 +16,12 @@ func AssertTypePtrOfSliceWithStruct(ptr interface{}) bool {
 }
 
 func AssertTypePtrOfStruct(ptr interface{}) bool {
-	return reflect.ValueOf(ptr).Type().Elem().Kind() == reflect.Struct
+	return assertTypePtrOf(ptr, reflect.Struct)
+}
+
+func assertTypePtrOf(ptr interface{}, kind reflect.Kind) bool {
+	typ := reflect.TypeOf(ptr)
+	return typ != nil && typ.Kind() == reflect.Ptr && typ.Elem().Kind() == kind
 }
 
 func AssertStrEmpty(str string) bool {
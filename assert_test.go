@@ -17,6 +17,8 @@ func TestAssertTypePtrOfSliceWithStruct(t *testing.T) {
 func TestAssertTypePtrOfStruct(t *testing.T) {
 	a := A{}
 	verify(t, 1, "AssertTypePtrOfStruct", &a, AssertTypePtrOfStruct(&a), true)
+	verify(t, 2, "AssertTypePtrOfStruct", a, AssertTypePtrOfStruct(a), false)
+	verify(t, 3, "AssertTypePtrOfStruct", nil, AssertTypePtrOfStruct(nil), false)
 }
 
 func verify(t *testing.T, testnum int, testcase string, input, output, expected interface{}) {
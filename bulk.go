@@ -0,0 +1,59 @@
+package dbdiff
+
+import "context"
+
+// bulkSchemes holds the columns, indexes and foreign keys of every table of a
+// database, grouped by table name in the order of the queries.
+type bulkSchemes struct {
+	columns     map[string][]ColumnScheme
+	indexes     map[string][]IndexScheme
+	foreignKeys map[string][]ForeignKeyScheme
+}
+
+// loadBulkSchemes runs one query per kind of object the filter includes,
+// whatever the number of tables. The CREATE TABLE statements are rendered
+// from all of them, unless ShowCreateTable, so that they are loaded whenever
+// the filter includes tables.
+func (scheme *Scheme) loadBulkSchemes(ctx context.Context) (*bulkSchemes, error) {
+	var (
+		dbName = scheme.DbConn.DBName
+		render = scheme.Filter.IncludesObject(ObjectTable) && !scheme.ShowCreateTable
+		bulk   = &bulkSchemes{
+			columns:     map[string][]ColumnScheme{},
+			indexes:     map[string][]IndexScheme{},
+			foreignKeys: map[string][]ForeignKeyScheme{},
+		}
+	)
+	if render || scheme.Filter.IncludesObject(ObjectColumn) {
+		columnSchemes := []ColumnScheme{}
+		sql, args := scheme.schemeSql.AllColumnSchemeSql(dbName)
+		if err := scheme.tpl.QueryListContext(ctx, sql, &columnSchemes, args...); err != nil {
+			return nil, err
+		}
+		scheme.readColumns(columnSchemes)
+		for _, columnScheme := range columnSchemes {
+			bulk.columns[columnScheme.TableName] = append(bulk.columns[columnScheme.TableName], columnScheme)
+		}
+	}
+	if render || scheme.Filter.IncludesObject(ObjectIndex) {
+		indexSchemes := []IndexScheme{}
+		sql, args := scheme.schemeSql.AllIndexSchemeSql(dbName)
+		if err := scheme.tpl.QueryListContext(ctx, sql, &indexSchemes, args...); err != nil {
+			return nil, err
+		}
+		for _, indexScheme := range indexSchemes {
+			bulk.indexes[indexScheme.TableName] = append(bulk.indexes[indexScheme.TableName], indexScheme)
+		}
+	}
+	if render || scheme.Filter.IncludesObject(ObjectForeignKey) {
+		foreignKeySchemes := []ForeignKeyScheme{}
+		sql, args := scheme.schemeSql.AllForeignKeySchemeSql(dbName)
+		if err := scheme.tpl.QueryListContext(ctx, sql, &foreignKeySchemes, args...); err != nil {
+			return nil, err
+		}
+		for _, foreignKeyScheme := range foreignKeySchemes {
+			bulk.foreignKeys[foreignKeyScheme.TableName] = append(bulk.foreignKeys[foreignKeyScheme.TableName], foreignKeyScheme)
+		}
+	}
+	return bulk, nil
+}
@@ -0,0 +1,317 @@
+package dbdiff
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// Severity grades what applying a change can do to the data of the new
+// database. Lossy changes may drop or truncate data, blocking changes may
+// fail on existing data or cannot be converted automatically.
+type Severity int
+
+const (
+	SeveritySafe Severity = iota
+	SeverityLossy
+	SeverityBlocking
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeveritySafe:
+		return "safe"
+	case SeverityLossy:
+		return "lossy"
+	case SeverityBlocking:
+		return "blocking"
+	}
+	return "unknown"
+}
+
+type ObjectType string
+
+const (
+	ObjectTable      ObjectType = "table"
+	ObjectColumn     ObjectType = "column"
+	ObjectIndex      ObjectType = "index"
+	ObjectForeignKey ObjectType = "foreign_key"
+	ObjectOption     ObjectType = "option"
+)
+
+// ChangeKind describes what the migration does to the new database: added
+// objects exist only in the old database, removed ones only in the new.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+type Change struct {
+	ObjectType ObjectType
+	Kind       ChangeKind
+	TableName  string
+	Name       string
+	Severity   Severity
+	Reasons    []string
+}
+
+func (change *Change) QualifiedName() string {
+	if change.ObjectType == ObjectTable || change.ObjectType == ObjectOption {
+		return change.Name
+	}
+	return change.TableName + "." + change.Name
+}
+
+func (change *Change) String() string {
+	s := fmt.Sprintf("%s %s %s (%s)", change.Kind, change.ObjectType, change.QualifiedName(), change.Severity)
+	if len(change.Reasons) != 0 {
+		s += ": " + strings.Join(change.Reasons, ", ")
+	}
+	return s
+}
+
+func (change *Change) raise(severity Severity, reason string) {
+	if severity > change.Severity {
+		change.Severity = severity
+	}
+	change.Reasons = append(change.Reasons, reason)
+}
+
+// Classify lists every change of the diff with its severity.
+func Classify(diff *DiffDataBase) []*Change {
+	changes := []*Change{}
+	for _, diffTable := range diff.DiffTables {
+		if change := classifyTable(diffTable); change != nil {
+			changes = append(changes, change)
+		}
+		if diffTable.TableOld == nil || diffTable.TableNew == nil {
+			continue
+		}
+		for _, diffColumn := range diffTable.DiffColumns {
+			changes = append(changes, classifyColumn(diffTable.TableName, diffColumn))
+		}
+		for _, diffIndex := range diffTable.DiffIndex {
+			changes = append(changes, classifyIndex(diffTable.TableName, diffIndex))
+		}
+	}
+	for _, diffOption := range diff.DiffOptions {
+		changes = append(changes, classifyOption(diffOption))
+	}
+	return changes
+}
+
+func classifyTable(diffTable *DiffTable) *Change {
+	change := &Change{ObjectType: ObjectTable, TableName: diffTable.TableName, Name: diffTable.TableName}
+	switch {
+	case diffTable.TableNew == nil:
+		change.Kind = ChangeAdded
+	case diffTable.TableOld == nil:
+		change.Kind = ChangeRemoved
+		change.raise(SeverityLossy, "drops table")
+	case len(diffTable.Changes) != 0:
+		change.Kind = ChangeModified
+		if attr := diffTable.Change(AttrCollation); attr != nil && collationCharset(attr.Old) != collationCharset(attr.New) {
+			change.raise(SeverityLossy, fmt.Sprintf("changes charset from %s to %s", collationCharset(attr.New), collationCharset(attr.Old)))
+		}
+		//the row format is migrated on its own
+		if attr := diffTable.Change(AttrCreateOptions); attr != nil && createOptions(attr.Old) != createOptions(attr.New) {
+			change.raise(SeverityBlocking, fmt.Sprintf("changes create options from %q to %q, not migrated", attr.New, attr.Old))
+		}
+	default:
+		return nil
+	}
+	return change
+}
+
+// classifyColumn grades the change from the new column (current state) to the
+// old column (desired state).
+func classifyColumn(tableName string, diffColumn *DiffColumn) *Change {
+	change := &Change{ObjectType: ObjectColumn, TableName: tableName}
+	switch {
+	case diffColumn.ItemNew == nil:
+		change.Kind = ChangeAdded
+		change.Name = diffColumn.ItemOld.ColumnName
+		return change
+	case diffColumn.ItemOld == nil:
+		change.Kind = ChangeRemoved
+		change.Name = diffColumn.ItemNew.ColumnName
+		change.raise(SeverityLossy, "drops column")
+		return change
+	}
+
+	var (
+		from = diffColumn.ItemNew
+		to   = diffColumn.ItemOld
+	)
+	change.Kind = ChangeModified
+	change.Name = to.ColumnName
+	if diffColumn.Change(AttrType) != nil {
+		severity, reason := classifyTypeChange(from.ColumnType, to.ColumnType)
+		if severity == SeverityLossy && diffColumn.Members != nil && diffColumn.Members.Unused() {
+			//no row uses the dropped members
+			change.raise(SeveritySafe, "removes unused members")
+		} else if severity != SeveritySafe {
+			change.raise(severity, reason)
+		}
+	}
+	if diffColumn.Change(AttrGeneration) != nil && columnGeneration(to.Extra) == "STORED" {
+		change.raise(SeverityBlocking, "computes a stored generated column, rebuilds the table")
+	}
+	if diffColumn.Change(AttrNullable) != nil && "NO" == to.NullAble {
+		change.raise(SeverityLossy, "makes column NOT NULL")
+	}
+	if diffColumn.Change(AttrCharset) != nil && !AssertStrEmpty(from.CharacterSetName) && !AssertStrEmpty(to.CharacterSetName) {
+		change.raise(SeverityLossy, fmt.Sprintf("changes charset from %s to %s", from.CharacterSetName, to.CharacterSetName))
+	}
+	return change
+}
+
+func classifyIndex(tableName string, diffIndex *DiffIndex) *Change {
+	change := &Change{ObjectType: ObjectIndex, TableName: tableName}
+	switch {
+	case diffIndex.ItemNew == nil:
+		change.Kind = ChangeAdded
+		change.Name = diffIndex.ItemOld.KeyName
+		if diffIndex.ItemOld.Unique() {
+			change.raise(SeverityBlocking, "adds unique index, fails on duplicate rows")
+		}
+	case diffIndex.ItemOld == nil:
+		change.Kind = ChangeRemoved
+		change.Name = diffIndex.ItemNew.KeyName
+		if diffIndex.ItemNew.Primary() {
+			change.raise(SeverityLossy, "drops primary key")
+		}
+	default:
+		change.Kind = ChangeModified
+		change.Name = diffIndex.ItemOld.KeyName
+		if diffIndex.ItemOld.Unique() {
+			change.raise(SeverityBlocking, "rebuilds unique index, fails on duplicate rows")
+		}
+	}
+	return change
+}
+
+// classifyForeignKeys lists the foreign keys that differ between the sides
+// of a table in both databases. They are not migrated, so each one blocks.
+func classifyForeignKeys(diffTable *DiffTable) []*Change {
+	var (
+		changes = []*Change{}
+		add     = func(kind ChangeKind, name string) {
+			change := &Change{ObjectType: ObjectForeignKey, Kind: kind, TableName: diffTable.TableName, Name: name}
+			change.raise(SeverityBlocking, "foreign keys are not migrated")
+			changes = append(changes, change)
+		}
+	)
+	for _, foreignKey := range diffTable.TableOld.ForeignKeyList {
+		switch other := diffTable.TableNew.foreignKey(foreignKey.ConstraintName); {
+		case other == nil:
+			add(ChangeAdded, foreignKey.ConstraintName)
+		case !reflect.DeepEqual(foreignKey.Columns, other.Columns) || foreignKey.ReferencedTable != other.ReferencedTable ||
+			!reflect.DeepEqual(foreignKey.ReferencedColumns, other.ReferencedColumns):
+			add(ChangeModified, foreignKey.ConstraintName)
+		}
+	}
+	for _, foreignKey := range diffTable.TableNew.ForeignKeyList {
+		if diffTable.TableOld.foreignKey(foreignKey.ConstraintName) == nil {
+			add(ChangeRemoved, foreignKey.ConstraintName)
+		}
+	}
+	return changes
+}
+
+func classifyOption(diffOption *DiffOption) *Change {
+	change := &Change{ObjectType: ObjectOption, Kind: ChangeModified}
+	switch {
+	case diffOption.ItemNew == nil:
+		change.Kind = ChangeAdded
+		change.Name = diffOption.ItemOld.VariableName
+	case diffOption.ItemOld == nil:
+		change.Kind = ChangeRemoved
+		change.Name = diffOption.ItemNew.VariableName
+	default:
+		change.Name = diffOption.ItemOld.VariableName
+	}
+	return change
+}
+
+// createOptions are the CREATE_OPTIONS of a table but the row format.
+func createOptions(options string) string {
+	kept := []string{}
+	for _, option := range strings.Fields(strings.ToLower(options)) {
+		if !strings.HasPrefix(option, "row_format=") {
+			kept = append(kept, option)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+func collationCharset(collation string) string {
+	if i := strings.Index(collation, "_"); i > 0 {
+		return collation[:i]
+	}
+	return collation
+}
+
+// classifyTypeChange grades converting a column of type from to type to.
+func classifyTypeChange(from, to string) (Severity, string) {
+	dataTypeFrom, errFrom := ParseDataType(from)
+	dataTypeTo, errTo := ParseDataType(to)
+	if errFrom != nil || errTo != nil {
+		return SeverityBlocking, fmt.Sprintf("converts %s to unknown %s", from, to)
+	}
+
+	switch CompareDataType(dataTypeFrom, dataTypeTo) {
+	case TypeNarrowing:
+		if dataTypeFrom.Unsigned != dataTypeTo.Unsigned {
+			return SeverityLossy, fmt.Sprintf("changes signedness of %s to %s", from, to)
+		}
+		if family := dataTypeFrom.Family(); (family == FamilyEnum || family == FamilySet) && family == dataTypeTo.Family() {
+			return SeverityLossy, fmt.Sprintf("removes members of %s in %s", from, to)
+		}
+		return SeverityLossy, fmt.Sprintf("narrows %s to %s", from, to)
+	case TypeIncompatible:
+		return SeverityBlocking, fmt.Sprintf("converts %s to incompatible %s", from, to)
+	}
+	return SeveritySafe, ""
+}
+
+// SafetyOptions is the gate for lossy and blocking changes. AllowDestructive
+// admits lossy changes; blocking changes are only admitted by the allow list,
+// whose entries are path.Match patterns over the qualified name ("table",
+// "table.column", "table.index" or "table.foreign key").
+type SafetyOptions struct {
+	AllowDestructive bool
+	AllowList        []string
+}
+
+func (safety *SafetyOptions) Allows(change *Change) bool {
+	if change == nil || change.Severity == SeveritySafe {
+		return true
+	}
+	if safety == nil {
+		return false
+	}
+	for _, pattern := range safety.AllowList {
+		if ok, _ := path.Match(pattern, change.QualifiedName()); ok {
+			return true
+		}
+	}
+	return change.Severity == SeverityLossy && safety.AllowDestructive
+}
+
+func (safety *SafetyOptions) Check(changes []*Change) error {
+	refused := []*Change{}
+	for _, change := range changes {
+		if !safety.Allows(change) {
+			refused = append(refused, change)
+		}
+	}
+	if len(refused) != 0 {
+		return &UnsafeChangeError{Changes: refused}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package dbdiff
+
+import (
+	"testing"
+)
+
+func classifyTestColumn(columnType, nullAble, charset string) *Column {
+	return &Column{ColumnScheme: ColumnScheme{
+		TableName:        "student",
+		ColumnName:       "name",
+		ColumnType:       columnType,
+		NullAble:         nullAble,
+		CharacterSetName: charset,
+	}}
+}
+
+func TestClassifyTypeChange(t *testing.T) {
+	cases := []struct {
+		from, to string
+		expected Severity
+	}{
+		{"varchar(255)", "varchar(64)", SeverityLossy},
+		{"varchar(64)", "varchar(255)", SeveritySafe},
+		{"char(10)", "varchar(10)", SeveritySafe},
+		{"int(11)", "int(10)", SeveritySafe},
+		{"int(11)", "bigint(20)", SeveritySafe},
+		{"bigint(20)", "int(11)", SeverityLossy},
+		{"int(11)", "int(10) unsigned", SeverityLossy},
+		{"decimal(10,2)", "decimal(12,2)", SeveritySafe},
+		{"decimal(10,2)", "decimal(10,1)", SeverityLossy},
+		{"datetime(6)", "datetime", SeverityLossy},
+		{"text", "tinytext", SeverityLossy},
+		{"varchar(64)", "int(11)", SeverityBlocking},
+		{"enum('a','b')", "enum('a')", SeverityLossy},
+	}
+	for i, c := range cases {
+		severity, _ := classifyTypeChange(c.from, c.to)
+		verify(t, i+1, "classifyTypeChange", c.from+" -> "+c.to, severity, c.expected)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	var (
+		//migrations turn the new column into the old one
+		narrowOld  = classifyTestColumn("varchar(64)", "YES", "utf8mb4")
+		narrowNew  = classifyTestColumn("varchar(255)", "YES", "utf8mb4")
+		notNullOld = classifyTestColumn("varchar(64)", "NO", "utf8")
+		notNullNew = classifyTestColumn("varchar(64)", "YES", "utf8")
+		charsetOld = classifyTestColumn("varchar(64)", "YES", "latin1")
+		charsetNew = classifyTestColumn("varchar(64)", "YES", "utf8")
+		diff       = &DiffDataBase{DiffTables: []*DiffTable{
+			{TableName: "gone", TableNew: &Table{DropTableSql: "drop TABLE gone"}},
+			{
+				TableName: "student",
+				TableOld:  &Table{},
+				TableNew:  &Table{},
+				DiffColumns: []*DiffColumn{
+					{ItemNew: classifyTestColumn("int(11)", "YES", "")},
+					{ItemOld: narrowOld, ItemNew: narrowNew, Changes: columnChanges(narrowOld, narrowNew, nil)},
+					{ItemOld: notNullOld, ItemNew: notNullNew, Changes: columnChanges(notNullOld, notNullNew, nil)},
+					{ItemOld: charsetOld, ItemNew: charsetNew, Changes: columnChanges(charsetOld, charsetNew, nil)},
+				},
+				DiffIndex: []*DiffIndex{
+					{ItemOld: &Index{KeyName: "uk_name", ColumnIndex: []*IndexScheme{{NonUnique: 0}}}},
+				},
+			},
+		}}
+	)
+	changes := Classify(diff)
+	expected := []Severity{SeverityLossy, SeverityLossy, SeverityLossy, SeverityLossy, SeverityLossy, SeverityBlocking}
+	verify(t, 1, "Classify", "size", len(changes), len(expected))
+	for i := 0; i < len(expected) && i < len(changes); i++ {
+		verify(t, i+2, "Classify", changes[i], changes[i].Severity, expected[i])
+	}
+
+	var (
+		dropTable = changes[0]
+		addUnique = changes[5]
+	)
+	verify(t, 10, "SafetyOptions", "nil", (*SafetyOptions)(nil).Allows(dropTable), false)
+	verify(t, 11, "SafetyOptions", "destructive", (&SafetyOptions{AllowDestructive: true}).Allows(dropTable), true)
+	verify(t, 12, "SafetyOptions", "destructive blocking", (&SafetyOptions{AllowDestructive: true}).Allows(addUnique), false)
+	verify(t, 13, "SafetyOptions", "allow list", (&SafetyOptions{AllowList: []string{"student.uk_*"}}).Allows(addUnique), true)
+
+	_, err := NewMigrationPlan(diff, nil)
+	_, ok := err.(*UnsafeChangeError)
+	verify(t, 14, "NewMigrationPlan", "refused", ok, true)
+}
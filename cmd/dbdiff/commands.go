@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/atuowgo/dbdiff"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff",
+		Short: "Report the differences between the old and the new database",
+		Long: `Report the differences between the old and the new database.
+
+The exit code tells the result apart for CI: 0 when the databases do not
+differ, 1 when they differ, 3 when every difference is suppressed by the rules
+of the configuration and 2 on errors. The report is written to --output with
+the exit codes 1 and 3 as well, only errors leave it unwritten.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			//checked before connecting, the report would fail after the diff
+			if dbdiff.ReportFormat(opts.format) == dbdiff.FormatSARIF && opts.sarifArtifact == "" {
+				return fmt.Errorf("--sarif-artifact is required with --format sarif")
+			}
+			diff, err := opts.newDBDiff()
+			if err != nil {
+				return err
+			}
+			diffDataBase, err := opts.parseDiff(diff)
+			if err != nil {
+				return err
+			}
+			out, closeOut, err := opts.out(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeOut()
+			err = dbdiff.WriteReportWith(out, diffDataBase, &dbdiff.ReportOptions{
+				Format:        dbdiff.ReportFormat(opts.format),
+				Color:         opts.useColor(out),
+				SARIFArtifact: opts.sarifArtifact,
+			})
+			if err != nil {
+				return err
+			}
+			if status := diffDataBase.Status(); status != dbdiff.StatusEmpty {
+				return &exitError{code: status.ExitCode()}
+			}
+			return nil
+		},
+	}
+}
+
+func newPlanCommand(opts *options) *cobra.Command {
+	safety := &dbdiff.SafetyOptions{}
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Print the migration SQL that turns the new database into the old one",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, err := opts.migrationPlan(safety)
+			if err != nil {
+				return err
+			}
+			out, closeOut, err := opts.out(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeOut()
+			for _, sql := range plan.Sqls() {
+				fmt.Fprintln(out, strings.TrimSuffix(sql, ";")+";")
+			}
+			//admitted by the allow list, the reviewer still sees them
+			for _, change := range plan.Unsupported {
+				if len(change.Reasons) != 0 {
+					fmt.Fprintln(out, "-- not migrated: "+change.String())
+				}
+			}
+			return nil
+		},
+	}
+	opts.safetyFlags(cmd.Flags(), safety)
+	return cmd
+}
+
+func newApplyCommand(opts *options) *cobra.Command {
+	var (
+		applyOpts = &dbdiff.ApplyOptions{Safety: &dbdiff.SafetyOptions{}}
+		yes       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply the migration to the new database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !applyOpts.DryRun && !yes {
+				return fmt.Errorf("refusing to change the new database without --yes, use --dry-run to preview")
+			}
+			if opts.new.snapshot != "" && !applyOpts.DryRun {
+				return fmt.Errorf("cannot apply to a snapshot, --new-db is required")
+			}
+			plan, err := opts.migrationPlan(applyOpts.Safety)
+			if err != nil {
+				return err
+			}
+			out, closeOut, err := opts.out(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeOut()
+			applyOpts.Out = out
+
+			//a dry run only needs the driver of the new database
+			conn := &dbdiff.DBConn{DriverName: dbdiff.DriverType(opts.driver)}
+			if !applyOpts.DryRun {
+				if conn, err = opts.conn(&opts.new); err != nil {
+					return err
+				}
+			}
+			_, err = dbdiff.Apply(plan, conn, applyOpts)
+			return err
+		},
+	}
+	flags := cmd.Flags()
+	flags.BoolVar(&applyOpts.DryRun, "dry-run", false, "print the statements without running them")
+	flags.BoolVar(&applyOpts.ContinueOnError, "continue-on-error", false, "keep applying after a failed statement")
+	flags.IntVar(&applyOpts.TxBatchSize, "tx-batch-size", dbdiff.DefaultApplyTxBatchSize, "DML statements per transaction")
+	flags.BoolVarP(&yes, "yes", "y", false, "confirm changing the new database")
+	opts.safetyFlags(flags, applyOpts.Safety)
+	return cmd
+}
+
+func (opts *options) migrationPlan(safety *dbdiff.SafetyOptions) (*dbdiff.Plan, error) {
+	diff, err := opts.newDBDiff()
+	if err != nil {
+		return nil, err
+	}
+	diffDataBase, err := opts.parseDiff(diff)
+	if err != nil {
+		return nil, err
+	}
+	return dbdiff.NewMigrationPlan(diffDataBase, safety)
+}
+
+func newSnapshotCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "snapshot old|new",
+		Short: "Export the scheme of one database to a JSON snapshot",
+		Long: `Export the scheme of one database to a JSON snapshot. A snapshot can stand in
+for a database with --old-snapshot or --new-snapshot.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"old", "new"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbName, dataBase, err := opts.inspect(args[0])
+			if err != nil {
+				return err
+			}
+			out, closeOut, err := opts.out(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeOut()
+			return dbdiff.WriteSnapshot(out, dbName, dataBase)
+		},
+	}
+}
+
+func newInspectCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:       "inspect old|new",
+		Short:     "Print the tables of one database",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"old", "new"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, dataBase, err := opts.inspect(args[0])
+			if err != nil {
+				return err
+			}
+			out, closeOut, err := opts.out(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeOut()
+			writeDataBase(out, dataBase)
+			return nil
+		},
+	}
+}
+
+// inspect loads one side and returns the name of its database with it.
+func (opts *options) inspect(name string) (string, *dbdiff.DataBase, error) {
+	side, err := opts.side(name)
+	if err != nil {
+		return "", nil, err
+	}
+	diff, err := opts.newDBDiff()
+	if err != nil {
+		return "", nil, err
+	}
+	return opts.dataBase(opts.ctx, diff, side)
+}
+
+// writeDataBase prints the CREATE TABLE statement of every table, or its
+// columns and indexes when the statement was not loaded.
+func writeDataBase(out io.Writer, dataBase *dbdiff.DataBase) {
+	for _, table := range dataBase.Tables {
+		if table.CreateTableSql != "" {
+			fmt.Fprintf(out, "%s;\n\n", table.CreateTableSql)
+			continue
+		}
+		fmt.Fprintf(out, "table %s\n", table.TableName)
+		for _, column := range table.ColumnList {
+			fmt.Fprintf(out, "  column %s %s\n", column.ColumnName, column.ColumnType)
+		}
+		for _, index := range table.IndexList {
+			fmt.Fprintf(out, "  index %s (%s)\n", index.KeyName, strings.Join(index.Columns, ","))
+		}
+		fmt.Fprintln(out)
+	}
+	for _, option := range dataBase.Options {
+		fmt.Fprintf(out, "option %s = %s\n", option.VariableName, option.Value)
+	}
+}
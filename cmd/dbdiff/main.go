@@ -0,0 +1,80 @@
+// Command dbdiff compares the schemes of two MySQL databases, prints the
+// migration that turns the new database into the old one and applies it.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/atuowgo/dbdiff"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := newRootCommand(ctx).Execute()
+	stop()
+	if err != nil {
+		if exit, ok := err.(*exitError); ok {
+			os.Exit(exit.code)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(dbdiff.ExitError)
+	}
+}
+
+// exitError ends a command that succeeded with a non zero exit code, such as
+// diff finding differences.
+type exitError struct {
+	code int
+}
+
+func (err *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", err.code)
+}
+
+func newRootCommand(ctx context.Context) *cobra.Command {
+	opts := &options{ctx: ctx}
+	root := &cobra.Command{
+		Use:   "dbdiff",
+		Short: "Compare the schemes of two databases",
+		Long: `dbdiff compares the scheme of an old (reference) database with a new one.
+Migrations make the new database look like the old one. Either side can be
+read from a snapshot file instead of a live connection.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	opts.addPersistentFlags(root)
+	root.AddCommand(
+		newDiffCommand(opts),
+		newPlanCommand(opts),
+		newApplyCommand(opts),
+		newSnapshotCommand(opts),
+		newInspectCommand(opts),
+		newCompletionCommand(root),
+	)
+	return root
+}
+
+func newCompletionCommand(root *cobra.Command) *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh",
+		Short: "Print the shell completion script",
+		Long: `Print the shell completion script, for example:
+
+    source <(dbdiff completion bash)`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"bash", "zsh"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletion(cmd.OutOrStdout())
+			case "zsh":
+				return root.GenZshCompletion(cmd.OutOrStdout())
+			}
+			return fmt.Errorf("unsupported shell %s", args[0])
+		},
+	}
+}
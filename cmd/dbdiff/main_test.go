@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/atuowgo/dbdiff"
+)
+
+// writeSnapshot writes the tables in a snapshot file of dir.
+func writeSnapshot(t *testing.T, dir, name string, tables ...*dbdiff.Table) string {
+	path := filepath.Join(dir, name+".json")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := dbdiff.WriteSnapshot(f, "dbdiff", &dbdiff.DataBase{Tables: tables}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// run runs the command line with args and returns its output.
+func run(args ...string) (string, error) {
+	var out bytes.Buffer
+	root := newRootCommand(context.Background())
+	root.SetOutput(&out)
+	root.SetArgs(args)
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		student = &dbdiff.Table{
+			TableScheme: dbdiff.TableScheme{TableName: "student", Engine: "InnoDB"},
+			ColumnList: []*dbdiff.Column{{ColumnScheme: dbdiff.ColumnScheme{
+				TableName: "student", ColumnName: "id", ColumnType: "int", NullAble: "NO"}}},
+			IndexList: []*dbdiff.Index{{TableName: "student", KeyName: "PRIMARY", Columns: []string{"id"}}},
+		}
+		course = &dbdiff.Table{
+			TableScheme:  dbdiff.TableScheme{TableName: "course", Engine: "InnoDB"},
+			DropTableSql: "DROP TABLE IF EXISTS `course`",
+		}
+		oldPath = writeSnapshot(t, dir, "old", student)
+		newPath = writeSnapshot(t, dir, "new", student, course)
+	)
+
+	out, err := run("diff", "--old-snapshot", oldPath, "--new-snapshot", oldPath)
+	if err != nil {
+		t.Fatalf("diff of the same snapshot: %v\n%s", err, out)
+	}
+
+	out, err = run("diff", "--old-snapshot", oldPath, "--new-snapshot", newPath)
+	exit, ok := err.(*exitError)
+	if !ok || exit.code != dbdiff.StatusChanged.ExitCode() {
+		t.Fatalf("diff: exit %v, output\n%s", err, out)
+	}
+	if !strings.Contains(out, "course") {
+		t.Errorf("diff does not report the extra table:\n%s", out)
+	}
+
+	out, err = run("plan", "--old-snapshot", oldPath, "--new-snapshot", newPath, "--allow-destructive")
+	if err != nil || !strings.Contains(out, "DROP TABLE IF EXISTS `course`") {
+		t.Errorf("plan: %v\n%s", err, out)
+	}
+
+	if _, err := run("diff", "--old-snapshot", oldPath, "--new-snapshot", newPath, "-f", "sarif"); err == nil || !strings.Contains(err.Error(), "--sarif-artifact") {
+		t.Errorf("sarif without an artifact: %v", err)
+	}
+	out, err = run("diff", "--old-snapshot", oldPath, "--new-snapshot", newPath, "-f", "sarif", "--sarif-artifact", "migrations/042.sql")
+	if _, ok := err.(*exitError); !ok || !strings.Contains(out, `"uri": "migrations/042.sql"`) {
+		t.Errorf("sarif: %v\n%s", err, out)
+	}
+
+	out, err = run("snapshot", "old", "--old-snapshot", oldPath)
+	if err != nil || !strings.Contains(out, `"DBName": "dbdiff"`) {
+		t.Errorf("snapshot of a snapshot: %v\n%s", err, out)
+	}
+
+	if _, err := run("diff", "--no-such-flag"); err == nil {
+		t.Error("unknown flag accepted")
+	}
+	if out, err := run("completion", "bash"); err != nil || !strings.Contains(out, "dbdiff") {
+		t.Errorf("completion: %v", err)
+	}
+}
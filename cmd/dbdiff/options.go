@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atuowgo/dbdiff"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// options holds the persistent flags shared by every subcommand.
+type options struct {
+	// ctx is canceled on interrupt, stopping the queries in flight.
+	ctx context.Context
+
+	driver string
+	old    sideOptions
+	new    sideOptions
+
+	config           string
+	environment      string
+	includeTables    []string
+	excludeTables    []string
+	excludeColumns   []string
+	excludeIndexes   []string
+	objectTypes      []string
+	keepDisplayWidth bool
+	createTableDiff  bool
+	queryTimeout     time.Duration
+	parallelism      int
+	bulk             bool
+
+	format        string
+	output        string
+	color         string
+	sarifArtifact string
+}
+
+// sideOptions are the connection flags of one side, prefixed old- or new-.
+type sideOptions struct {
+	name     string
+	dsn      string
+	host     string
+	port     int
+	socket   string
+	user     string
+	password string
+	dbName   string
+	snapshot string
+	params   map[string]string
+	tls      dbdiff.TLSOptions
+	ssh      string
+	sshOpts  dbdiff.SSHTunnel
+
+	passwordFile     string
+	passwordPrompt   bool
+	passphrasePrompt bool
+	defaultsFile     string
+	defaultsGroups   []string
+	// conn is built once, so that the password is asked only once.
+	conn *dbdiff.DBConn
+}
+
+func (opts *options) addPersistentFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.driver, "driver", dbdiff.MYSQL.String(), "database driver")
+	opts.old.addFlags(flags, "old")
+	opts.new.addFlags(flags, "new")
+
+	flags.StringVarP(&opts.config, "config", "c", "", "configuration file with filters and rules")
+	flags.StringVar(&opts.environment, "environment", "", "environment selecting the rules of the configuration")
+	flags.StringSliceVar(&opts.includeTables, "include-table", nil, "only compare the tables matching these patterns")
+	flags.StringSliceVar(&opts.excludeTables, "exclude-table", nil, "skip the tables matching these patterns")
+	flags.StringSliceVar(&opts.excludeColumns, "exclude-column", nil, "skip the columns matching these patterns")
+	flags.StringSliceVar(&opts.excludeIndexes, "exclude-index", nil, "skip the indexes matching these patterns")
+	flags.StringSliceVar(&opts.objectTypes, "object", nil, "only compare these object types (table, column, index, foreign_key, option)")
+	flags.BoolVar(&opts.keepDisplayWidth, "keep-display-width", false, "compare integer display widths")
+	flags.BoolVar(&opts.createTableDiff, "create-table-diff", false, "show a unified diff of the CREATE TABLE statements")
+	flags.IntVarP(&opts.parallelism, "parallelism", "j", dbdiff.DefaultParallelism, "tables introspected at once, and connections opened, per database")
+	flags.BoolVar(&opts.bulk, "bulk", false, "load columns, indexes and CREATE TABLE statements with one query per kind instead of one per table")
+	flags.DurationVar(&opts.queryTimeout, "query-timeout", 0, "give up on an introspection query after this long, such as 30s (0 waits forever)")
+
+	flags.StringVarP(&opts.format, "format", "f", string(dbdiff.FormatText), "output format ("+formatNames()+")")
+	flags.StringVarP(&opts.output, "output", "o", "", "write the output to this file instead of stdout")
+	flags.StringVar(&opts.color, "color", "auto", "color the text output (auto, always, never)")
+	flags.StringVar(&opts.sarifArtifact, "sarif-artifact", "", "file the SARIF results point at, such as the migration under review (required by -f sarif)")
+
+	cmd.MarkPersistentFlagFilename("config", "toml")
+	cmd.MarkPersistentFlagFilename("old-snapshot", "json")
+	cmd.MarkPersistentFlagFilename("new-snapshot", "json")
+	cmd.MarkPersistentFlagFilename("output")
+}
+
+func (side *sideOptions) addFlags(flags *pflag.FlagSet, name string) {
+	side.name = name
+	flags.StringVar(&side.host, name+"-host", "", "host of the "+name+" database (default 127.0.0.1)")
+	flags.IntVar(&side.port, name+"-port", 0, "port of the "+name+" database (default 3306)")
+	flags.StringVar(&side.user, name+"-user", "", "user of the "+name+" database (default root)")
+	flags.StringVar(&side.password, name+"-password", "", "password of the "+name+" database, prefer the other credential sources")
+	flags.StringVar(&side.passwordFile, name+"-password-file", "", "read the password of the "+name+" database from this file")
+	flags.BoolVar(&side.passwordPrompt, name+"-password-prompt", false, "ask for the password of the "+name+" database on the terminal")
+	flags.StringVar(&side.defaultsFile, name+"-defaults-file", "", "MySQL option file of the "+name+" database (default ~/.my.cnf)")
+	flags.StringSliceVar(&side.defaultsGroups, name+"-defaults-group", nil, "option file groups read after [client] for the "+name+" database")
+	flags.StringVar(&side.dbName, name+"-db", "", "name of the "+name+" database")
+	flags.StringVar(&side.snapshot, name+"-snapshot", "", "read the "+name+" database from a snapshot file")
+	flags.StringVar(&side.dsn, name+"-dsn", "", "DSN or mysql:// URL of the "+name+" database, instead of the host, port and user flags")
+	flags.StringVar(&side.socket, name+"-socket", "", "unix socket of the "+name+" database, instead of the host and port")
+	flags.StringToStringVar(&side.params, name+"-param", nil, "driver parameters of the "+name+" database, such as charset=utf8mb4,readTimeout=30s")
+	flags.StringVar(&side.tls.CAFile, name+"-tls-ca", "", "CA certificate verifying the "+name+" database")
+	flags.StringVar(&side.tls.CertFile, name+"-tls-cert", "", "client certificate for the "+name+" database")
+	flags.StringVar(&side.tls.KeyFile, name+"-tls-key", "", "client key for the "+name+" database")
+	flags.StringVar(&side.tls.ServerName, name+"-tls-server-name", "", "server name expected in the certificate of the "+name+" database")
+	flags.BoolVar(&side.tls.InsecureSkipVerify, name+"-tls-skip-verify", false, "encrypt the connection to the "+name+" database without verifying its certificate")
+	flags.StringVar(&side.ssh, name+"-ssh", "", "reach the "+name+" database through this bastion, [user@]host[:port]")
+	flags.StringSliceVar(&side.sshOpts.Jump, name+"-ssh-jump", nil, "hosts crossed in order before the bastion of the "+name+" database")
+	flags.StringVar(&side.sshOpts.KeyFile, name+"-ssh-key", "", "private key authenticating with the bastion of the "+name+" database")
+	flags.BoolVar(&side.passphrasePrompt, name+"-ssh-key-passphrase-prompt", false, "ask for the passphrase of the encrypted --"+name+"-ssh-key on the terminal")
+	flags.StringVar(&side.sshOpts.AgentSocket, name+"-ssh-agent", "", "ssh-agent socket for the bastion of the "+name+" database, none to disable (default $SSH_AUTH_SOCK)")
+	flags.StringVar(&side.sshOpts.KnownHostsFile, name+"-ssh-known-hosts", "", "known_hosts file verifying the bastion of the "+name+" database (default ~/.ssh/known_hosts)")
+	flags.BoolVar(&side.sshOpts.InsecureIgnoreHostKey, name+"-ssh-skip-host-key-check", false, "accept any host key from the bastion of the "+name+" database")
+}
+
+func formatNames() string {
+	names := make([]string, len(dbdiff.ReportFormats))
+	for i, format := range dbdiff.ReportFormats {
+		names[i] = string(format)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (opts *options) side(name string) (*sideOptions, error) {
+	switch name {
+	case "old":
+		return &opts.old, nil
+	case "new":
+		return &opts.new, nil
+	}
+	return nil, fmt.Errorf("unknown side %s, expected old or new", name)
+}
+
+// conn builds the connection of side from its DSN or from its host, port and
+// user flags. The database, socket, TLS, SSH and parameter flags apply to both.
+// Missing credentials are read, in order, from the password file, the
+// DBDIFF_OLD_USER and DBDIFF_OLD_PASSWORD (or NEW) environment variables, the
+// option file and the terminal.
+func (opts *options) conn(side *sideOptions) (*dbdiff.DBConn, error) {
+	if side.conn != nil {
+		return side.conn, nil
+	}
+	conn := dbdiff.NewDBConn(dbdiff.DriverType(opts.driver), side.user, side.password, side.host, side.port, side.dbName)
+	if side.dsn != "" {
+		var err error
+		if conn, err = dbdiff.ParseDBConn(side.dsn); err != nil {
+			return nil, err
+		}
+		if side.dbName != "" {
+			conn.DBName = side.dbName
+		}
+	}
+	if side.socket != "" {
+		conn.Socket = side.socket
+	}
+	for key, value := range side.params {
+		if conn.Params == nil {
+			conn.Params = map[string]string{}
+		}
+		conn.Params[key] = value
+	}
+	if side.tls != (dbdiff.TLSOptions{}) {
+		tls := side.tls
+		conn.TLS = &tls
+	}
+	if side.ssh != "" {
+		tunnel, err := dbdiff.ParseSSHTunnel(side.ssh)
+		if err != nil {
+			return nil, err
+		}
+		tunnel.Jump, tunnel.KeyFile, tunnel.AgentSocket = side.sshOpts.Jump, side.sshOpts.KeyFile, side.sshOpts.AgentSocket
+		tunnel.KnownHostsFile, tunnel.InsecureIgnoreHostKey = side.sshOpts.KnownHostsFile, side.sshOpts.InsecureIgnoreHostKey
+		conn.SSH = tunnel
+	}
+
+	if side.passwordFile != "" {
+		conn.Credentials = append(conn.Credentials, &dbdiff.PasswordFile{Path: side.passwordFile})
+	}
+	prefix := "DBDIFF_" + strings.ToUpper(side.name) + "_"
+	conn.Credentials = append(conn.Credentials, &dbdiff.EnvCredentials{
+		UserVar: prefix + "USER", PasswordVar: prefix + "PASSWORD", PassphraseVar: prefix + "SSH_PASSPHRASE",
+	})
+	if side.defaultsFile != "" {
+		conn.Credentials = append(conn.Credentials, &dbdiff.OptionFile{Path: side.defaultsFile, Groups: side.defaultsGroups})
+	} else {
+		conn.Credentials = append(conn.Credentials, dbdiff.DefaultOptionFile(side.defaultsGroups...))
+	}
+	if side.passwordPrompt {
+		conn.Credentials = append(conn.Credentials, &dbdiff.PromptPassword{
+			Prompt: fmt.Sprintf("Password of the %s database: ", side.name),
+		})
+	}
+	if side.passphrasePrompt {
+		conn.Credentials = append(conn.Credentials, &dbdiff.PromptPassphrase{
+			Prompt: fmt.Sprintf("Passphrase of the ssh key of the %s database: ", side.name),
+		})
+	}
+	if err := conn.ResolveCredentials(); err != nil {
+		return nil, err
+	}
+	if conn.Username == "" {
+		conn.Username = "root"
+	}
+	if conn.Ip == "" && conn.Socket == "" {
+		conn.Ip = "127.0.0.1"
+	}
+	if conn.Port == 0 {
+		conn.Port = 3306
+	}
+	if conn.DBName == "" {
+		return nil, fmt.Errorf("--%s-db is required", side.name)
+	}
+	side.conn = conn
+	return conn, nil
+}
+
+// newDBDiff builds the DBDiff from the configuration file and the filter
+// flags, the flags are added to the filter of the configuration.
+func (opts *options) newDBDiff() (*dbdiff.DBDiff, error) {
+	diff := dbdiff.NewDBDiff()
+	diff.Normalizer.KeepDisplayWidth = opts.keepDisplayWidth
+	diff.CreateTableDiff = opts.createTableDiff
+	diff.QueryTimeout = opts.queryTimeout
+	diff.Parallelism = opts.parallelism
+	diff.BulkIntrospection = opts.bulk
+	if opts.config != "" {
+		if err := diff.LoadConfig(opts.config); err != nil {
+			return nil, err
+		}
+	}
+	if opts.environment != "" {
+		if diff.Rules == nil {
+			diff.Rules = &dbdiff.RuleSet{}
+		}
+		diff.Rules.Environment = opts.environment
+	}
+
+	filter := diff.Filter
+	if filter == nil {
+		filter = &dbdiff.Filter{}
+	}
+	filter.IncludeTables = append(filter.IncludeTables, opts.includeTables...)
+	filter.ExcludeTables = append(filter.ExcludeTables, opts.excludeTables...)
+	filter.ExcludeColumns = append(filter.ExcludeColumns, opts.excludeColumns...)
+	filter.ExcludeIndexes = append(filter.ExcludeIndexes, opts.excludeIndexes...)
+	for _, objectType := range opts.objectTypes {
+		filter.ObjectTypes = append(filter.ObjectTypes, dbdiff.ObjectType(objectType))
+	}
+	if err := filter.Compile(); err != nil {
+		return nil, err
+	}
+	diff.Filter = filter
+	return diff, nil
+}
+
+// dataBase introspects one side, or reads it from its snapshot file, along
+// with the name of its database.
+func (opts *options) dataBase(ctx context.Context, diff *dbdiff.DBDiff, side *sideOptions) (string, *dbdiff.DataBase, error) {
+	if side.snapshot != "" {
+		f, err := os.Open(side.snapshot)
+		if err != nil {
+			return "", nil, err
+		}
+		defer f.Close()
+		snapshot, err := dbdiff.ReadSnapshot(f)
+		if err != nil {
+			return "", nil, err
+		}
+		dataBase, err := diff.Filter.FilterDataBase(snapshot.DataBase)
+		return snapshot.DBName, dataBase, err
+	}
+	conn, err := opts.conn(side)
+	if err != nil {
+		return "", nil, err
+	}
+	dataBase, err := diff.ParseDataBaseContext(ctx, conn)
+	return conn.DBName, dataBase, dbdiff.WithSide(side.name, err)
+}
+
+// parseDiff loads both sides at the same time, the first failure cancels the
+// other side.
+func (opts *options) parseDiff(diff *dbdiff.DBDiff) (*dbdiff.DiffDataBase, error) {
+	//credentials are resolved first, one prompt at a time
+	for _, side := range []*sideOptions{&opts.old, &opts.new} {
+		if side.snapshot == "" {
+			if _, err := opts.conn(side); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(opts.ctx)
+	defer cancel()
+	var (
+		sides     = []*sideOptions{&opts.old, &opts.new}
+		dataBases = make([]*dbdiff.DataBase, len(sides))
+		errs      = make([]error, len(sides))
+		wg        sync.WaitGroup
+	)
+	for i, side := range sides {
+		wg.Add(1)
+		go func(i int, side *sideOptions) {
+			defer wg.Done()
+			_, dataBases[i], errs[i] = opts.dataBase(ctx, diff, side)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, side)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		//the side canceled by the failure of the other reports context canceled
+		if _, canceled := err.(*dbdiff.CanceledError); err != nil && !canceled {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return diff.DiffDataBases(dataBases[0], dataBases[1])
+}
+
+// out opens the output of the command, the returned function closes it.
+func (opts *options) out(cmd *cobra.Command) (io.Writer, func() error, error) {
+	if opts.output == "" {
+		return cmd.OutOrStdout(), func() error { return nil }, nil
+	}
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+func (opts *options) safetyFlags(flags *pflag.FlagSet, safety *dbdiff.SafetyOptions) {
+	flags.BoolVar(&safety.AllowDestructive, "allow-destructive", false, "admit lossy changes")
+	flags.StringSliceVar(&safety.AllowList, "allow", nil, "admit the lossy and blocking changes of these table, table.column or table.index patterns")
+}
+
+// useColor follows --color, auto colors the output of terminals unless the
+// NO_COLOR environment variable is set.
+func (opts *options) useColor(out io.Writer) bool {
+	switch opts.color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	f, ok := out.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,445 @@
+package dbdiff
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Config is the content of a dbdiff configuration file. The file is written
+// in a subset of TOML: top level keys, a [filter] table and [[rule]] tables
+// whose values are strings, booleans, integers or arrays of them.
+//
+//	environment = "staging"
+//
+//	[filter]
+//	exclude_tables = ["tmp_*", "/^_.*_(gho|del)$/"]
+//
+//	[[rule]]
+//	name = "ignore-auto-increment"
+//	ignore = ["auto_increment"]
+//
+//	[[rule]]
+//	name = "int-display-width"
+//	objects = ["column"]
+//	equivalent_types = ["int(11)", "int"]
+type Config struct {
+	Environment string
+	Filter      *Filter
+	Rules       *RuleSet
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{Path: path, Message: err.Error()}
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		if configErr, ok := err.(*ConfigError); ok {
+			configErr.Path = path
+		}
+		return nil, err
+	}
+	return config, nil
+}
+
+func ParseConfig(data []byte) (*Config, error) {
+	doc, err := parseToml(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{Rules: &RuleSet{}}
+	for key, value := range doc.root {
+		switch key {
+		case "environment":
+			if config.Environment, err = tomlString(key, value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, &ConfigError{Message: "unknown key " + key}
+		}
+	}
+	config.Rules.Environment = config.Environment
+
+	if table, ok := doc.tables["filter"]; ok {
+		config.Filter = &Filter{}
+		for key, value := range table {
+			var target *[]string
+			switch key {
+			case "include_tables":
+				target = &config.Filter.IncludeTables
+			case "exclude_tables":
+				target = &config.Filter.ExcludeTables
+			case "exclude_columns":
+				target = &config.Filter.ExcludeColumns
+			case "exclude_indexes":
+				target = &config.Filter.ExcludeIndexes
+			case "object_types":
+				objectTypes, err := tomlStrings(key, value)
+				if err != nil {
+					return nil, err
+				}
+				for _, objectType := range objectTypes {
+					config.Filter.ObjectTypes = append(config.Filter.ObjectTypes, ObjectType(objectType))
+				}
+				continue
+			default:
+				return nil, &ConfigError{Message: "unknown key filter." + key}
+			}
+			if *target, err = tomlStrings(key, value); err != nil {
+				return nil, err
+			}
+		}
+		if err := config.Filter.Compile(); err != nil {
+			return nil, err
+		}
+	}
+	for name := range doc.tables {
+		if name != "filter" {
+			return nil, &ConfigError{Message: "unknown table " + name}
+		}
+	}
+
+	for name, tables := range doc.arrays {
+		if name != "rule" {
+			return nil, &ConfigError{Message: "unknown table array " + name}
+		}
+		for _, table := range tables {
+			rule, err := decodeRule(table)
+			if err != nil {
+				return nil, err
+			}
+			config.Rules.Rules = append(config.Rules.Rules, rule)
+		}
+	}
+	if err := config.Rules.Compile(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func decodeRule(table tomlTable) (*Rule, error) {
+	var (
+		rule = &Rule{}
+		err  error
+	)
+	for key, value := range table {
+		switch key {
+		case "name":
+			rule.Name, err = tomlString(key, value)
+		case "objects":
+			var objectTypes []string
+			objectTypes, err = tomlStrings(key, value)
+			for _, objectType := range objectTypes {
+				rule.ObjectTypes = append(rule.ObjectTypes, ObjectType(objectType))
+			}
+		case "tables":
+			rule.Tables, err = tomlStrings(key, value)
+		case "names":
+			rule.Names, err = tomlStrings(key, value)
+		case "environments":
+			rule.Environments, err = tomlStrings(key, value)
+		case "ignore":
+			rule.Ignore, err = tomlStrings(key, value)
+		case "equivalent_types":
+			rule.EquivalentTypes, err = tomlStrings(key, value)
+		case "compare":
+			rule.Compare, err = tomlStrings(key, value)
+		default:
+			err = &ConfigError{Message: "unknown key rule." + key}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rule, nil
+}
+
+// LoadConfig applies the filter and rules of a configuration file to diff.
+func (diff *DBDiff) LoadConfig(path string) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	if config.Filter != nil {
+		diff.Filter = config.Filter
+	}
+	diff.Rules = config.Rules
+	return nil
+}
+
+func tomlString(key string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", &ConfigError{Message: fmt.Sprintf("%s must be a string", key)}
+	}
+	return s, nil
+}
+
+func tomlStrings(key string, value interface{}) ([]string, error) {
+	if s, ok := value.(string); ok {
+		return []string{s}, nil
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, &ConfigError{Message: fmt.Sprintf("%s must be an array of strings", key)}
+	}
+	strs := make([]string, len(values))
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, &ConfigError{Message: fmt.Sprintf("%s must be an array of strings", key)}
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+type tomlTable map[string]interface{}
+
+type tomlDocument struct {
+	root   tomlTable
+	tables map[string]tomlTable
+	arrays map[string][]tomlTable
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func parseToml(src string) (*tomlDocument, error) {
+	var (
+		parser  = &tomlParser{src: src, line: 1}
+		doc     = &tomlDocument{root: tomlTable{}, tables: map[string]tomlTable{}, arrays: map[string][]tomlTable{}}
+		current = doc.root
+	)
+	for {
+		parser.skipBlank(true)
+		if parser.eof() {
+			return doc, nil
+		}
+		switch {
+		case strings.HasPrefix(parser.src[parser.pos:], "[["):
+			name, err := parser.header("[[", "]]")
+			if err != nil {
+				return nil, err
+			}
+			current = tomlTable{}
+			doc.arrays[name] = append(doc.arrays[name], current)
+		case parser.peek() == '[':
+			name, err := parser.header("[", "]")
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.tables[name]; ok {
+				return nil, parser.errorf("duplicate table %s", name)
+			}
+			current = tomlTable{}
+			doc.tables[name] = current
+		default:
+			key, value, err := parser.keyValue()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := current[key]; ok {
+				return nil, parser.errorf("duplicate key %s", key)
+			}
+			current[key] = value
+		}
+		if err := parser.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (parser *tomlParser) eof() bool {
+	return parser.pos >= len(parser.src)
+}
+
+func (parser *tomlParser) peek() byte {
+	return parser.src[parser.pos]
+}
+
+func (parser *tomlParser) errorf(format string, args ...interface{}) error {
+	return &ConfigError{Line: parser.line, Message: fmt.Sprintf(format, args...)}
+}
+
+// skipBlank skips spaces and comments, and newlines when multiline is set.
+func (parser *tomlParser) skipBlank(multiline bool) {
+	for !parser.eof() {
+		switch c := parser.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			parser.pos++
+		case c == '\n' && multiline:
+			parser.pos++
+			parser.line++
+		case c == '#':
+			for !parser.eof() && parser.peek() != '\n' {
+				parser.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (parser *tomlParser) endOfLine() error {
+	parser.skipBlank(false)
+	if parser.eof() {
+		return nil
+	}
+	if parser.peek() != '\n' {
+		return parser.errorf("unexpected %q", parser.peek())
+	}
+	return nil
+}
+
+func (parser *tomlParser) header(open, close string) (string, error) {
+	parser.pos += len(open)
+	end := strings.Index(parser.src[parser.pos:], close)
+	if end < 0 || strings.Contains(parser.src[parser.pos:parser.pos+end], "\n") {
+		return "", parser.errorf("unterminated table header")
+	}
+	name := strings.TrimSpace(parser.src[parser.pos : parser.pos+end])
+	parser.pos += end + len(close)
+	if !isTomlBareKey(name) {
+		return "", parser.errorf("invalid table name %q", name)
+	}
+	return name, nil
+}
+
+func (parser *tomlParser) keyValue() (string, interface{}, error) {
+	start := parser.pos
+	for !parser.eof() && isTomlBareKeyChar(parser.peek()) {
+		parser.pos++
+	}
+	key := parser.src[start:parser.pos]
+	if key == "" {
+		return "", nil, parser.errorf("expected a key")
+	}
+	parser.skipBlank(false)
+	if parser.eof() || parser.peek() != '=' {
+		return "", nil, parser.errorf("expected = after %s", key)
+	}
+	parser.pos++
+	parser.skipBlank(false)
+	value, err := parser.value()
+	return key, value, err
+}
+
+func (parser *tomlParser) value() (interface{}, error) {
+	if parser.eof() {
+		return nil, parser.errorf("expected a value")
+	}
+	switch c := parser.peek(); {
+	case c == '"':
+		return parser.basicString()
+	case c == '\'':
+		parser.pos++
+		end := strings.IndexAny(parser.src[parser.pos:], "'\n")
+		if end < 0 || parser.src[parser.pos+end] != '\'' {
+			return nil, parser.errorf("unterminated string")
+		}
+		s := parser.src[parser.pos : parser.pos+end]
+		parser.pos += end + 1
+		return s, nil
+	case c == '[':
+		parser.pos++
+		values := []interface{}{}
+		for {
+			parser.skipBlank(true)
+			if parser.eof() {
+				return nil, parser.errorf("unterminated array")
+			}
+			if parser.peek() == ']' {
+				parser.pos++
+				return values, nil
+			}
+			value, err := parser.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			parser.skipBlank(true)
+			if !parser.eof() && parser.peek() == ',' {
+				parser.pos++
+			} else if parser.eof() || parser.peek() != ']' {
+				return nil, parser.errorf("expected , or ] in array")
+			}
+		}
+	default:
+		start := parser.pos
+		for !parser.eof() && isTomlBareKeyChar(parser.peek()) || !parser.eof() && parser.peek() == '+' {
+			parser.pos++
+		}
+		word := parser.src[start:parser.pos]
+		switch word {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		n, err := strconv.ParseInt(strings.Replace(word, "_", "", -1), 10, 64)
+		if err != nil {
+			return nil, parser.errorf("invalid value %q", word)
+		}
+		return n, nil
+	}
+}
+
+func (parser *tomlParser) basicString() (string, error) {
+	parser.pos++
+	var buff strings.Builder
+	for !parser.eof() {
+		c := parser.peek()
+		parser.pos++
+		switch c {
+		case '"':
+			return buff.String(), nil
+		case '\n':
+			return "", parser.errorf("unterminated string")
+		case '\\':
+			if parser.eof() {
+				return "", parser.errorf("unterminated string")
+			}
+			e := parser.peek()
+			parser.pos++
+			switch e {
+			case 'n':
+				buff.WriteByte('\n')
+			case 't':
+				buff.WriteByte('\t')
+			case 'r':
+				buff.WriteByte('\r')
+			case '"', '\\':
+				buff.WriteByte(e)
+			default:
+				return "", parser.errorf("invalid escape \\%c", e)
+			}
+		default:
+			buff.WriteByte(c)
+		}
+	}
+	return "", parser.errorf("unterminated string")
+}
+
+func isTomlBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !isTomlBareKeyChar(key[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTomlBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
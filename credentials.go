@@ -0,0 +1,342 @@
+package dbdiff
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// CredentialSource fills the empty connection fields of a DBConn, such as the
+// user and the password, from somewhere else than the code or the command
+// line. Resolve reports an error only when the source exists and is broken.
+type CredentialSource interface {
+	Resolve(dbConn *DBConn) error
+}
+
+// ResolveCredentials runs the Credentials of dbConn in order, a field set by
+// a source is kept by the next ones. Conn calls it, later calls do nothing.
+func (dbConn *DBConn) ResolveCredentials() error {
+	if dbConn.resolved {
+		return nil
+	}
+	for _, source := range dbConn.Credentials {
+		if err := source.Resolve(dbConn); err != nil {
+			return dbConn.redact(err)
+		}
+	}
+	dbConn.resolved = true
+	return nil
+}
+
+// EnvCredentials reads the user, the password and the passphrase of the ssh
+// key from environment variables, an empty name skips the field.
+type EnvCredentials struct {
+	UserVar       string
+	PasswordVar   string
+	PassphraseVar string
+}
+
+func (source *EnvCredentials) Resolve(dbConn *DBConn) error {
+	if source.UserVar != "" && dbConn.Username == "" {
+		dbConn.Username = os.Getenv(source.UserVar)
+	}
+	if source.PasswordVar != "" && dbConn.Password == "" {
+		dbConn.Password = os.Getenv(source.PasswordVar)
+	}
+	if source.PassphraseVar != "" && dbConn.SSH != nil && dbConn.SSH.Passphrase == "" {
+		dbConn.SSH.Passphrase = os.Getenv(source.PassphraseVar)
+	}
+	return nil
+}
+
+// PasswordFile reads the password from the first line of a file, as mounted
+// by secret stores.
+type PasswordFile struct {
+	Path string
+}
+
+func (source *PasswordFile) Resolve(dbConn *DBConn) error {
+	if dbConn.Password != "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(source.Path)
+	if err != nil {
+		return &DBConnError{Message: "read password file", Err: err}
+	}
+	dbConn.Password = strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r")
+	return nil
+}
+
+// OptionFile reads a MySQL option file such as ~/.my.cnf. The options of
+// the [client] group are read first, then those of Groups, later groups
+// overriding earlier ones. The user, password, host, port, socket and
+// database options fill the empty fields of the DBConn.
+type OptionFile struct {
+	Path   string
+	Groups []string
+	// Optional ignores a missing file, as the mysql client does for ~/.my.cnf.
+	Optional bool
+}
+
+// DefaultOptionFile is ~/.my.cnf, ignored when it does not exist.
+func DefaultOptionFile(groups ...string) *OptionFile {
+	home, _ := os.UserHomeDir()
+	return &OptionFile{Path: filepath.Join(home, ".my.cnf"), Groups: groups, Optional: true}
+}
+
+func (source *OptionFile) Resolve(dbConn *DBConn) error {
+	if _, err := os.Stat(source.Path); err != nil && source.Optional && os.IsNotExist(err) {
+		return nil
+	}
+	groups, err := ReadOptionFile(source.Path)
+	if err != nil {
+		return err
+	}
+	options := map[string]string{}
+	for _, group := range append([]string{"client"}, source.Groups...) {
+		for key, value := range groups[group] {
+			options[key] = value
+		}
+	}
+
+	if dbConn.Username == "" {
+		dbConn.Username = options["user"]
+	}
+	if dbConn.Password == "" {
+		dbConn.Password = options["password"]
+	}
+	if dbConn.DBName == "" {
+		dbConn.DBName = options["database"]
+	}
+	if dbConn.Ip == "" && dbConn.Socket == "" {
+		dbConn.Ip = options["host"]
+		dbConn.Socket = options["socket"]
+	}
+	if port, ok := options["port"]; ok && dbConn.Port == 0 {
+		if dbConn.Port, err = strconv.Atoi(port); err != nil {
+			return &DBConnError{Message: fmt.Sprintf("invalid port %s in %s", port, source.Path)}
+		}
+	}
+	return nil
+}
+
+// ReadOptionFile parses a MySQL option file into its groups. Option names
+// are lower case with dashes for underscores, quotes around values are
+// removed and the !include and !includedir directives are followed.
+func ReadOptionFile(path string) (map[string]map[string]string, error) {
+	groups := map[string]map[string]string{}
+	if err := readOptionFile(path, groups, 0); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func readOptionFile(path string, groups map[string]map[string]string, depth int) error {
+	if depth > 10 {
+		return &DBConnError{Message: "option files include each other: " + path}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return &DBConnError{Message: "read option file", Err: err}
+	}
+	defer f.Close()
+
+	var (
+		scanner = bufio.NewScanner(f)
+		group   map[string]string
+		lineNum = 0
+	)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case strings.HasPrefix(line, "!includedir "):
+			dir := strings.TrimSpace(strings.TrimPrefix(line, "!includedir "))
+			paths, _ := filepath.Glob(filepath.Join(dir, "*.cnf"))
+			sort.Strings(paths)
+			for _, included := range paths {
+				if err := readOptionFile(included, groups, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		case strings.HasPrefix(line, "!include "):
+			if err := readOptionFile(strings.TrimSpace(strings.TrimPrefix(line, "!include ")), groups, depth+1); err != nil {
+				return err
+			}
+			continue
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return &DBConnError{Message: fmt.Sprintf("invalid group at %s line %d", path, lineNum)}
+			}
+			name := strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if groups[name] == nil {
+				groups[name] = map[string]string{}
+			}
+			group = groups[name]
+			continue
+		}
+		if group == nil {
+			return &DBConnError{Message: fmt.Sprintf("option outside of a group at %s line %d", path, lineNum)}
+		}
+		key, value := line, ""
+		if i := strings.Index(line, "="); i >= 0 {
+			key, value = strings.TrimSpace(line[:i]), optionValue(strings.TrimSpace(line[i+1:]))
+		}
+		group[strings.Replace(strings.ToLower(key), "_", "-", -1)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return &DBConnError{Message: "read option file", Err: err}
+	}
+	return nil
+}
+
+// optionValue reads a value as the mysql client does: the quotes around it
+// are removed, or the comment after an unquoted one, and the escape sequences
+// \b, \t, \n, \r, \s (a space), \\, \" and \' are replaced. Other
+// backslashes are kept as they are.
+func optionValue(value string) string {
+	quoted := false
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		for i := 1; i < len(value); i++ {
+			if value[i] == '\\' {
+				i++
+			} else if value[i] == value[0] {
+				value, quoted = value[1:i], true
+				break
+			}
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 && !quoted {
+		value = strings.TrimSpace(value[:i])
+	}
+	return unescapeOption(value)
+}
+
+var optionEscapes = map[byte]byte{'b': '\b', 't': '\t', 'n': '\n', 'r': '\r', 's': ' ', '\\': '\\', '"': '"', '\'': '\''}
+
+func unescapeOption(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var buff strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			if c, ok := optionEscapes[value[i+1]]; ok {
+				buff.WriteByte(c)
+				i++
+				continue
+			}
+		}
+		buff.WriteByte(value[i])
+	}
+	return buff.String()
+}
+
+// PromptPassword asks for the password on the terminal without echoing it.
+// It does nothing when In is not a terminal, so that scripts fail on the
+// missing password rather than hang.
+type PromptPassword struct {
+	Prompt string
+	In     *os.File
+	Out    io.Writer
+}
+
+func (source *PromptPassword) Resolve(dbConn *DBConn) error {
+	if dbConn.Password != "" {
+		return nil
+	}
+	prompt := source.Prompt
+	if prompt == "" {
+		prompt = fmt.Sprintf("Password for %s@%s: ", dbConn.Username, dbConn.DBName)
+	}
+	password, err := readSecret(source.In, source.Out, prompt, "password")
+	if err != nil {
+		return err
+	}
+	dbConn.Password = password
+	return nil
+}
+
+// PromptPassphrase asks for the passphrase of the ssh key file on the
+// terminal, when the key is encrypted, like PromptPassword.
+type PromptPassphrase struct {
+	Prompt string
+	In     *os.File
+	Out    io.Writer
+}
+
+func (source *PromptPassphrase) Resolve(dbConn *DBConn) error {
+	tunnel := dbConn.SSH
+	if tunnel == nil || !tunnel.needsPassphrase() {
+		return nil
+	}
+	prompt := source.Prompt
+	if prompt == "" {
+		prompt = fmt.Sprintf("Passphrase for %s: ", tunnel.KeyFile)
+	}
+	passphrase, err := readSecret(source.In, source.Out, prompt, "passphrase")
+	if err != nil {
+		return err
+	}
+	tunnel.Passphrase = passphrase
+	return nil
+}
+
+// readSecret reads the secret named what from the terminal in without echoing
+// it, it returns nothing when in is not a terminal. The terminal is restored
+// also when the read is interrupted, which fails with context.Canceled.
+func readSecret(in *os.File, out io.Writer, prompt, what string) (string, error) {
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stderr
+	}
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return "", nil
+	}
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", &DBConnError{Message: "read terminal state", Err: err}
+	}
+	defer term.Restore(fd, state)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	fmt.Fprint(out, prompt)
+	type read struct {
+		secret []byte
+		err    error
+	}
+	reads := make(chan read, 1)
+	go func() {
+		secret, err := term.ReadPassword(fd)
+		reads <- read{secret: secret, err: err}
+	}()
+	select {
+	case <-interrupts:
+		fmt.Fprintln(out)
+		return "", &DBConnError{Message: "read " + what, Err: context.Canceled}
+	case read := <-reads:
+		fmt.Fprintln(out)
+		if read.err != nil && read.err != io.EOF {
+			return "", &DBConnError{Message: "read " + what, Err: read.err}
+		}
+		return string(read.secret), nil
+	}
+}
@@ -0,0 +1,139 @@
+package dbdiff
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOptionFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	os.Mkdir(filepath.Join(dir, "conf.d"), 0700)
+	write("conf.d/port.cnf", "[client]\nport = 3307\n")
+	path := write("my.cnf", `# credentials of the reporting replica
+[client]
+user = report
+password = "s3cr#t"
+host=10.0.0.2 # replica
+!includedir `+filepath.Join(dir, "conf.d")+`
+
+[client_prod]
+user=admin
+database=app
+`)
+
+	groups, err := ReadOptionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "ReadOptionFile", "quoted", groups["client"]["password"], "s3cr#t")
+	verify(t, 2, "ReadOptionFile", "comment", groups["client"]["host"], "10.0.0.2")
+	verify(t, 3, "ReadOptionFile", "includedir", groups["client"]["port"], "3307")
+
+	dbConn := &DBConn{DriverName: MYSQL, Credentials: []CredentialSource{&OptionFile{Path: path, Groups: []string{"client_prod"}}}}
+	if err := dbConn.ResolveCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 4, "OptionFile", "user", dbConn.Username, "admin")
+	verify(t, 5, "OptionFile", "password", dbConn.Password, "s3cr#t")
+	verify(t, 6, "OptionFile", "address", dbConn.Ip+":"+dbConn.DBName, "10.0.0.2:app")
+	verify(t, 7, "OptionFile", "port", dbConn.Port, 3307)
+
+	//a missing ~/.my.cnf is not an error, a missing --defaults-file is
+	missing := filepath.Join(dir, "missing.cnf")
+	verify(t, 8, "OptionFile", "optional", (&OptionFile{Path: missing, Optional: true}).Resolve(&DBConn{}), nil)
+	_, ok := (&OptionFile{Path: missing}).Resolve(&DBConn{}).(*DBConnError)
+	verify(t, 9, "OptionFile", "missing", ok, true)
+
+	_, err = ReadOptionFile(write("bad.cnf", "user=root\n"))
+	verify(t, 10, "ReadOptionFile", "no group", err != nil, true)
+
+	values := map[string]string{
+		`"say \"hi\""`:     `say "hi"`,
+		`'it\'s' # quoted`: `it's`,
+		`C:\\tmp\sdir`:     `C:\tmp dir`,
+		`a\tb\nc`:          "a\tb\nc",
+		`\d+ # pattern`:    `\d+`,
+		`"unterminated \"`: `"unterminated "`,
+	}
+	for value, expected := range values {
+		verify(t, 11, "optionValue", value, optionValue(value), expected)
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	passwordFile := filepath.Join(dir, "password")
+	ioutil.WriteFile(passwordFile, []byte("from-file\n"), 0600)
+	os.Setenv("DBDIFF_TEST_USER", "env-user")
+	os.Setenv("DBDIFF_TEST_PASSWORD", "from-env")
+	defer os.Unsetenv("DBDIFF_TEST_USER")
+	defer os.Unsetenv("DBDIFF_TEST_PASSWORD")
+
+	//earlier sources win
+	dbConn := &DBConn{DriverName: MYSQL, Credentials: []CredentialSource{
+		&PasswordFile{Path: passwordFile},
+		&EnvCredentials{UserVar: "DBDIFF_TEST_USER", PasswordVar: "DBDIFF_TEST_PASSWORD"},
+	}}
+	dbConn.ResolveCredentials()
+	verify(t, 1, "ResolveCredentials", "password", dbConn.Password, "from-file")
+	verify(t, 2, "ResolveCredentials", "user", dbConn.Username, "env-user")
+
+	//a prompt without a terminal leaves the password empty
+	r, w, _ := os.Pipe()
+	defer r.Close()
+	defer w.Close()
+	dbConn = &DBConn{DriverName: MYSQL, Credentials: []CredentialSource{&PromptPassword{In: r, Out: ioutil.Discard}}}
+	dbConn.ResolveCredentials()
+	verify(t, 3, "ResolveCredentials", "prompt", dbConn.Password, "")
+}
+
+func TestDBConnRedaction(t *testing.T) {
+	dbConn := NewDBConn(MYSQL, "app", "hunter2", "db.internal", 3306, "app")
+	verify(t, 1, "String", "redacted", dbConn.String(), "app:xxxxx@tcp(db.internal:3306)/app")
+
+	err := dbConn.redact(errors.New("dial app:hunter2@tcp(db.internal:3306)/app failed"))
+	verify(t, 2, "redact", "message", err.Error(), "dial app:xxxxx@tcp(db.internal:3306)/app failed")
+
+	dbConn.Credentials = []CredentialSource{failingSource{}}
+	_, err = dbConn.Conn()
+	verify(t, 3, "Conn", "redacted", err.Error(), "connection error:login app:xxxxx refused")
+
+	//String reads no file and registers nothing with the driver
+	dbConn = NewDBConn(MYSQL, "app", "hunter2", "db.internal", 3306, "app")
+	dbConn.TLS = &TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}
+	dbConn.SSH = &SSHTunnel{Host: "bastion"}
+	verify(t, 4, "String", "tls and ssh", dbConn.String(), "app:xxxxx@tcp(db.internal:3306)/app?tls=custom through ssh bastion")
+
+	//errors of the later queries are masked too, here the password is in the dial error
+	plan := NewPlan()
+	plan.AddDML("student", "DELETE FROM student")
+	dbConn = NewDBConn(MYSQL, "app", "refused", "127.0.0.1", 1, "app")
+	_, err = Apply(plan, dbConn, nil)
+	verify(t, 5, "Apply", "redacted", err != nil && !strings.Contains(err.Error(), "refused"), true)
+}
+
+type failingSource struct{}
+
+func (failingSource) Resolve(dbConn *DBConn) error {
+	return &DBConnError{Message: "login " + dbConn.Username + ":" + dbConn.Password + " refused"}
+}
@@ -0,0 +1,157 @@
+package dbdiff
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+)
+
+// ErrStopEach stops QueryEach without an error when returned by its callback.
+var ErrStopEach = errors.New("stop each")
+
+// Cursor streams the rows of a query, holding one row at a time. Close it
+// when done, also after an early stop. Like the other methods of DBTemplate
+// it maps by reflection rather than with type parameters, so that the
+// template stays usable through the Operations interface, whose methods
+// cannot be generic: Scan checks out when called.
+type Cursor struct {
+	rows   *sql.Rows
+	ctx    context.Context
+	cancel context.CancelFunc
+	sql    string
+	mapper RowMapper
+	rowNum int
+}
+
+func (tpl *DBTemplate) QueryCursor(ctx context.Context, query string, args ...interface{}) (*Cursor, error) {
+	cancel := context.CancelFunc(func() {})
+	if tpl.QueryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, tpl.QueryTimeout)
+	}
+	var rs *sql.Rows
+	err := tpl.hooked(ctx, &Statement{Sql: query, Args: args}, func() (err error) {
+		if rs, err = tpl.executor().QueryContext(ctx, query, args...); err != nil {
+			return queryError(ctx, query, &DataAccessError{Message: "Db query error", Err: err})
+		}
+		return nil
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Cursor{
+		rows:   rs,
+		ctx:    ctx,
+		cancel: cancel,
+		sql:    query,
+		mapper: &defaultRowMapper4Struct{strict: tpl.StrictMapping},
+		rowNum: -1,
+	}, nil
+}
+
+// QueryCursorByMapper is QueryCursor mapping the rows with rowMapper, Scan
+// then takes what rowMapper maps into.
+func (tpl *DBTemplate) QueryCursorByMapper(ctx context.Context, query string, rowMapper RowMapper, args ...interface{}) (*Cursor, error) {
+	cursor, err := tpl.QueryCursor(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	cursor.mapper = rowMapper
+	return cursor, nil
+}
+
+// Next moves to the next row, false at the end or on error, see Err.
+func (cursor *Cursor) Next() bool {
+	if !cursor.rows.Next() {
+		return false
+	}
+	cursor.rowNum++
+	return true
+}
+
+// Scan maps the current row into out, a pointer to a struct with col tags
+// unless the cursor has its own mapper. Every row of a cursor must be scanned
+// into the same type.
+func (cursor *Cursor) Scan(out interface{}) error {
+	if _, ok := cursor.mapper.(*defaultRowMapper4Struct); ok && !AssertTypePtrOfStruct(out) {
+		return &DataAccessError{Message: "out param must be a ptr of struct"}
+	}
+	if err := cursor.mapper.MapRow(cursor.rows, cursor.rowNum, out); err != nil {
+		return &RowError{Row: cursor.rowNum, Err: err}
+	}
+	return nil
+}
+
+// Err is the error that ended the iteration, if any.
+func (cursor *Cursor) Err() error {
+	if err := cursor.rows.Err(); err != nil {
+		return queryError(cursor.ctx, cursor.sql, &DataAccessError{Message: "Db query error", Err: err})
+	}
+	return nil
+}
+
+func (cursor *Cursor) Close() error {
+	defer cursor.cancel()
+	return cursor.rows.Close()
+}
+
+func (tpl *DBTemplate) QueryEach(sql string, fn interface{}, args ...interface{}) error {
+	return tpl.QueryEachContext(context.Background(), sql, fn, args...)
+}
+
+// QueryEachContext streams the rows of a query to fn, a func(row T) error or
+// func(row *T) error where T is a struct with col tags. A row is mapped into a
+// new T and passed to fn before the next one is read. The first error of fn
+// stops the query and is returned, ErrStopEach stops it and returns nil.
+//
+// A row that cannot be mapped stops the query with its *RowError, unless fn
+// takes it as a second parameter, func(row T, err error) error: fn then gets
+// every row with the *RowError of those that failed, as far as they were
+// mapped, and decides whether to go on.
+func (tpl *DBTemplate) QueryEachContext(ctx context.Context, sql string, fn interface{}, args ...interface{}) error {
+	var (
+		fnV     = reflect.ValueOf(fn)
+		errType = reflect.TypeOf((*error)(nil)).Elem()
+	)
+	if fnV.Kind() != reflect.Func || fnV.Type().NumIn() < 1 || fnV.Type().NumIn() > 2 || fnV.Type().NumOut() != 1 ||
+		fnV.Type().Out(0) != errType || structType(fnV.Type().In(0)) == nil ||
+		fnV.Type().NumIn() == 2 && fnV.Type().In(1) != errType {
+		return &DataAccessError{Message: "fn must be a func(row T) error or func(row T, err error) error with a struct T"}
+	}
+	var (
+		inType    = fnV.Type().In(0)
+		rowType   = structType(inType)
+		rowByPtr  = inType.Kind() == reflect.Ptr
+		rowErrors = fnV.Type().NumIn() == 2
+	)
+
+	cursor, err := tpl.QueryCursor(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		row := reflect.New(rowType)
+		in := []reflect.Value{row}
+		rowErr := cursor.Scan(row.Interface())
+		if rowErrors {
+			in = append(in, reflect.Zero(errType))
+			if rowErr != nil {
+				in[1] = reflect.ValueOf(rowErr)
+			}
+		} else if rowErr != nil {
+			return rowErr
+		}
+		if !rowByPtr {
+			in[0] = row.Elem()
+		}
+		if err, _ := fnV.Call(in)[0].Interface().(error); err != nil {
+			if err == ErrStopEach {
+				return nil
+			}
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,86 @@
+package dbdiff
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestDBTemplate_QueryEach(t *testing.T) {
+	tpl := rowsTemplate(t, []string{"id", "name", "age"},
+		[]driver.Value{int64(1), "ann", int64(20)},
+		[]driver.Value{int64(2), "bob", nil},
+		[]driver.Value{int64(3), "cid", int64(30)},
+	)
+	names := ""
+	err := tpl.QueryEach("select", func(student Student) error {
+		names += student.Name
+		return nil
+	})
+	verify(t, 1, "QueryEach", "err", err, nil)
+	verify(t, 2, "QueryEach", "rows", names, "annbobcid")
+
+	count := 0
+	err = tpl.QueryEach("select", func(student *Student) error {
+		count++
+		if student.Id == 2 {
+			return ErrStopEach
+		}
+		return nil
+	})
+	verify(t, 3, "QueryEach", "stop", err, nil)
+	verify(t, 4, "QueryEach", "stopped", count, 2)
+
+	failed := errors.New("failed")
+	err = tpl.QueryEach("select", func(student *Student) error { return failed })
+	verify(t, 5, "QueryEach", "fn error", err, failed)
+
+	_, ok := tpl.QueryEach("select", func(id int) error { return nil }).(*DataAccessError)
+	verify(t, 6, "QueryEach", "invalid fn", ok, true)
+
+	tpl = rowsTemplate(t, []string{"id", "age"}, []driver.Value{int64(1), int64(20)}, []driver.Value{int64(2), "old"})
+	err = tpl.QueryEach("select", func(student Student) error { return nil })
+	rowErr, ok := err.(*RowError)
+	verify(t, 7, "QueryEach", "row error", ok, true)
+	verify(t, 8, "QueryEach", "row", rowErr.Row, 1)
+
+	tpl = rowsTemplate(t, []string{"id", "age"},
+		[]driver.Value{int64(1), int64(20)}, []driver.Value{int64(2), "old"}, []driver.Value{int64(3), int64(30)})
+	var (
+		ids     = 0
+		badRows = []int{}
+	)
+	err = tpl.QueryEach("select", func(student *Student, err error) error {
+		if rowErr, ok := err.(*RowError); ok {
+			badRows = append(badRows, rowErr.Row)
+			return nil
+		}
+		ids += student.Id
+		return err
+	})
+	verify(t, 9, "QueryEach", "row errors", err, nil)
+	verify(t, 10, "QueryEach", "rows", ids, 4)
+	verify(t, 11, "QueryEach", "failed rows", len(badRows) == 1 && badRows[0] == 1, true)
+	_, ok = tpl.QueryEach("select", func(student Student, err int) error { return nil }).(*DataAccessError)
+	verify(t, 12, "QueryEach", "invalid fn", ok, true)
+}
+
+func TestCursor(t *testing.T) {
+	tpl := rowsTemplate(t, []string{"id", "name"}, []driver.Value{int64(1), "ann"}, []driver.Value{int64(2), "bob"})
+	cursor, err := tpl.QueryCursor(context.Background(), "select")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+	ids := 0
+	for cursor.Next() {
+		student := Student{}
+		if err := cursor.Scan(&student); err != nil {
+			t.Fatal(err)
+		}
+		ids += student.Id
+	}
+	verify(t, 1, "Cursor", "rows", ids, 3)
+	verify(t, 2, "Cursor", "err", cursor.Err(), nil)
+}
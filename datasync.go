@@ -0,0 +1,906 @@
+package dbdiff
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const DefaultSyncBatchSize = 100
+
+// Row is a table row keyed by column name. Values are what the driver
+// returned: nil for NULL, []byte for the text protocol, or time.Time when
+// the connection parses times.
+type Row map[string]interface{}
+
+type rowMapper4Row struct {
+}
+
+func (rm *rowMapper4Row) MapRow(rs *sql.Rows, rowNum int, out interface{}) error {
+	colNames, err := rs.Columns()
+	if err != nil {
+		return &DataAccessError{Message: "get columns from result set error", Err: err}
+	}
+	values := make([]interface{}, len(colNames))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	err = rs.Scan(values...)
+	if err != nil {
+		return &DataAccessError{Message: "error when scan", Err: err}
+	}
+
+	row := make(Row, len(colNames))
+	for i, name := range colNames {
+		value := *(values[i].(*interface{}))
+		if b, ok := value.([]byte); ok {
+			//the driver reuses its buffer between rows
+			value = append([]byte{}, b...)
+		}
+		row[name] = value
+	}
+	reflect.ValueOf(out).Elem().Set(reflect.ValueOf(row))
+	return nil
+}
+
+// DiffRow pairs the rows of both sides sharing a primary key. Like the
+// scheme diffs, ItemOld is nil for rows only in the new database and
+// ItemNew is nil for rows only in the old one.
+type DiffRow struct {
+	ItemOld Row
+	ItemNew Row
+	Columns []string
+}
+
+// DiffTableData holds the row differences of one table. The old database is
+// the source and the new database the target of a sync.
+type DiffTableData struct {
+	TableName  string
+	Table      *Table
+	PrimaryKey []string
+	// Columns are compared and synced: the columns stored in both databases.
+	Columns []string
+	// SkippedColumns are in one database only or generated in either, they
+	// are neither compared nor synced.
+	SkippedColumns []string
+	DiffRows       []*DiffRow
+}
+
+// DiffTableRows compares rows loaded in memory, sorting both sides by primary
+// key. Text keys are compared byte by byte here, ParseDataDiff compares them
+// under their collation.
+func DiffTableRows(table *Table, rowsOld, rowsNew []Row) (*DiffTableData, error) {
+	diff, err := newDiffTableData(table, table)
+	if err != nil {
+		return nil, err
+	}
+
+	keyComparators := make([]func(left, right interface{}) int, len(diff.PrimaryKey))
+	for i, name := range diff.PrimaryKey {
+		keyComparators[i] = valueComparator(table.Column(name))
+	}
+	rowsComp := KeySlice{
+		keyCompareAction: &compDiffRows{diff: diff},
+		keyComparator: func(left, right interface{}) int {
+			return compareRowKey(diff.PrimaryKey, keyComparators, left.(Row), right.(Row))
+		},
+	}
+	rowsComp.Compare(&rowsOld, &rowsNew)
+	return diff, nil
+}
+
+// newDiffTableData prepares the comparison of a table, which needs the same
+// primary key on both sides.
+func newDiffTableData(tableOld, tableNew *Table) (*DiffTableData, error) {
+	primaryKey := tableOld.PrimaryKey()
+	if len(primaryKey) == 0 {
+		return nil, &DataSyncError{TableName: tableOld.TableName, Message: "table has no primary key"}
+	}
+	if strings.Join(tableNew.PrimaryKey(), ",") != strings.Join(primaryKey, ",") {
+		return nil, &DataSyncError{TableName: tableOld.TableName, Message: "primary keys differ"}
+	}
+
+	diff := &DiffTableData{
+		TableName:      tableOld.TableName,
+		Table:          tableOld,
+		PrimaryKey:     primaryKey,
+		Columns:        []string{},
+		SkippedColumns: []string{},
+		DiffRows:       []*DiffRow{},
+	}
+	for _, column := range tableOld.ColumnList {
+		columnNew := tableNew.Column(column.ColumnName)
+		if columnNew == nil || column.isGenerated() || columnNew.isGenerated() {
+			diff.SkippedColumns = append(diff.SkippedColumns, column.ColumnName)
+		} else {
+			diff.Columns = append(diff.Columns, column.ColumnName)
+		}
+	}
+	for _, column := range tableNew.ColumnList {
+		if tableOld.Column(column.ColumnName) == nil {
+			diff.SkippedColumns = append(diff.SkippedColumns, column.ColumnName)
+		}
+	}
+	return diff, nil
+}
+
+type compDiffRows struct {
+	diff *DiffTableData
+}
+
+func (this *compDiffRows) ActionBothExists(itemLeft, itemRight interface{}) {
+	var (
+		left    = itemLeft.(Row)
+		right   = itemRight.(Row)
+		columns = []string{}
+	)
+	for _, name := range this.diff.Columns {
+		if valueComparator(this.diff.Table.Column(name))(left[name], right[name]) != 0 {
+			columns = append(columns, name)
+		}
+	}
+	if len(columns) != 0 {
+		this.diff.DiffRows = append(this.diff.DiffRows, &DiffRow{ItemOld: left, ItemNew: right, Columns: columns})
+	}
+}
+
+func (this *compDiffRows) ActionLeftExists(itemLeft interface{}) {
+	this.diff.DiffRows = append(this.diff.DiffRows, &DiffRow{ItemOld: itemLeft.(Row)})
+}
+
+func (this *compDiffRows) ActionRightExists(itemRight interface{}) {
+	this.diff.DiffRows = append(this.diff.DiffRows, &DiffRow{ItemNew: itemRight.(Row)})
+}
+
+func compareRowKey(primaryKey []string, comparators []func(left, right interface{}) int, left, right Row) int {
+	for i, name := range primaryKey {
+		if res := comparators[i](left[name], right[name]); res != 0 {
+			return res
+		}
+	}
+	return 0
+}
+
+// valueComparator compares the values of column, numbers by value and the
+// others byte by byte. It follows the type of the column rather than the
+// values, so that the rows are sorted the same way whatever they hold.
+func valueComparator(column *Column) func(left, right interface{}) int {
+	if column != nil && column.category() == columnCategoryNumeric {
+		return compareNumber
+	}
+	return compareText
+}
+
+// compareNull orders NULL first, ok is false when neither value is NULL.
+func compareNull(left, right interface{}) (res int, ok bool) {
+	switch {
+	case left == nil && right == nil:
+		return 0, true
+	case left == nil:
+		return -1, true
+	case right == nil:
+		return 1, true
+	}
+	return 0, false
+}
+
+func compareText(left, right interface{}) int {
+	if res, ok := compareNull(left, right); ok {
+		return res
+	}
+	return strings.Compare(valueString(left), valueString(right))
+}
+
+// compareNumber compares exactly integers, decimals and floats. Values that
+// are not numbers, which a numeric column does not hold, come after them.
+func compareNumber(left, right interface{}) int {
+	if res, ok := compareNull(left, right); ok {
+		return res
+	}
+	var (
+		leftStr, rightStr = valueString(left), valueString(right)
+		leftRat, okLeft   = new(big.Rat).SetString(leftStr)
+		rightRat, okRight = new(big.Rat).SetString(rightStr)
+	)
+	switch {
+	case okLeft && okRight:
+		return leftRat.Cmp(rightRat)
+	case okLeft:
+		return -1
+	case okRight:
+		return 1
+	}
+	return strings.Compare(leftStr, rightStr)
+}
+
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999")
+	}
+	return fmt.Sprint(value)
+}
+
+func (diff *DBDiff) ParseDataDiff(connOld, connNew *DBConn, tableNames ...string) ([]*DiffTableData, error) {
+	return diff.ParseDataDiffContext(context.Background(), connOld, connNew, tableNames...)
+}
+
+// ParseDataDiffContext compares the rows of the tables on both sides, all of
+// them when tableNames is empty. Both databases are introspected at once and
+// each table is streamed from both in primary key order, holding one row of
+// each side besides the differences found.
+func (diff *DBDiff) ParseDataDiffContext(ctx context.Context, connOld, connNew *DBConn, tableNames ...string) ([]*DiffTableData, error) {
+	diffs, err := diff.parseDataDiff(ctx, connOld, connNew, tableNames)
+	return diffs, connNew.redact(connOld.redact(err))
+}
+
+func (diff *DBDiff) parseDataDiff(ctx context.Context, connOld, connNew *DBConn, tableNames []string) ([]*DiffTableData, error) {
+	var (
+		conns     = []*DBConn{connOld, connNew}
+		sides     = []string{SideOld, SideNew}
+		dbs       = make([]*sql.DB, len(conns))
+		dataBases = make([]*DataBase, len(conns))
+	)
+	defer func() {
+		for _, db := range dbs {
+			if db != nil {
+				db.Close()
+			}
+		}
+	}()
+	err := forEachParallel(ctx, len(conns), len(conns), func(ctx context.Context, i int) error {
+		db, err := conns[i].ConnContext(ctx)
+		if err != nil {
+			return WithSide(sides[i], err)
+		}
+		dbs[i] = db
+		dataBase, err := diff.newScheme(conns[i], db).ParseContext(ctx)
+		dataBases[i] = dataBase
+		return WithSide(sides[i], err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tplOld = NewDBTemplate(dbs[0])
+		tplNew = NewDBTemplate(dbs[1])
+		diffs  = []*DiffTableData{}
+	)
+	for _, tableOld := range dataBases[0].Tables {
+		if len(tableNames) != 0 && !containsStr(tableNames, tableOld.TableName) {
+			continue
+		}
+		tableNew := dataBases[1].Table(tableOld.TableName)
+		if tableNew == nil {
+			continue
+		}
+		diffTableData, err := newDiffTableData(tableOld, tableNew)
+		if err != nil {
+			return nil, err
+		}
+		if err := diffTableData.compareStreams(ctx, tplOld, tplNew, tableNew); err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diffTableData)
+	}
+	return diffs, nil
+}
+
+// compareStreams streams the rows of both sides in primary key order and
+// merges them. The server sorts text keys under their collation, which must
+// be the same on both sides, so they are compared by their WEIGHT_STRING.
+func (diff *DiffTableData) compareStreams(ctx context.Context, tplOld, tplNew *DBTemplate, tableNew *Table) error {
+	var (
+		keyComparators = make([]func(left, right interface{}) int, len(diff.PrimaryKey))
+		keyNames       = make([]string, len(diff.PrimaryKey))
+		selects        = []string{}
+		orderBy        = make([]string, len(diff.PrimaryKey))
+	)
+	for _, name := range diff.Columns {
+		selects = append(selects, quoteIdent(name))
+	}
+	for i, name := range diff.PrimaryKey {
+		column := diff.Table.Column(name)
+		if !containsStr(diff.Columns, name) {
+			selects = append(selects, quoteIdent(name))
+		}
+		keyNames[i], keyComparators[i], orderBy[i] = name, valueComparator(column), quoteIdent(name)
+		if column.category() != columnCategoryString {
+			continue
+		}
+		if collationNew := tableNew.Column(name).CollationName; collationNew != column.CollationName {
+			return &DataSyncError{TableName: diff.TableName,
+				Message: fmt.Sprintf("primary key column %s is collated %s and %s", name, column.CollationName, collationNew)}
+		}
+		keyNames[i] = fmt.Sprintf("dbdiff_weight_%d", i)
+		keyComparators[i] = compareText
+		selects = append(selects, fmt.Sprintf("WEIGHT_STRING(%s) AS %s", quoteIdent(name), quoteIdent(keyNames[i])))
+		if dataType, err := ParseDataType(column.ColumnType); err == nil &&
+			(dataType.Family() == FamilyEnum || dataType.Family() == FamilySet) {
+			//enums and sets sort by their index, not their collation
+			orderBy[i] = quoteIdent(keyNames[i])
+		}
+	}
+
+	query := (&SchemeSql{}).SelectTableDataSql(diff.TableName, selects, orderBy)
+	streamOld, err := newRowStream(ctx, tplOld, query, SideOld, keyNames, diff.PrimaryKey)
+	if err != nil {
+		return err
+	}
+	defer streamOld.cursor.Close()
+	streamNew, err := newRowStream(ctx, tplNew, query, SideNew, keyNames, diff.PrimaryKey)
+	if err != nil {
+		return err
+	}
+	defer streamNew.cursor.Close()
+
+	action := &compDiffRows{diff: diff}
+	for streamOld.row != nil || streamNew.row != nil {
+		res := 0
+		switch {
+		case streamNew.row == nil:
+			res = -1
+		case streamOld.row == nil:
+			res = 1
+		default:
+			for i := range keyComparators {
+				if res = keyComparators[i](streamOld.keys[i], streamNew.keys[i]); res != 0 {
+					break
+				}
+			}
+		}
+		switch {
+		case res == 0:
+			action.ActionBothExists(streamOld.row, streamNew.row)
+			if err := streamOld.next(); err != nil {
+				return err
+			}
+			err = streamNew.next()
+		case res < 0:
+			action.ActionLeftExists(streamOld.row)
+			err = streamOld.next()
+		default:
+			action.ActionRightExists(streamNew.row)
+			err = streamNew.next()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowStream holds the current row of a side, nil past the last one, and the
+// values its rows are ordered by.
+type rowStream struct {
+	cursor   *Cursor
+	side     string
+	keyNames []string
+	keyCols  []string
+	row      Row
+	keys     []interface{}
+}
+
+func newRowStream(ctx context.Context, tpl *DBTemplate, query, side string, keyNames, keyCols []string) (*rowStream, error) {
+	cursor, err := tpl.QueryCursorByMapper(ctx, query, &rowMapper4Row{})
+	if err != nil {
+		return nil, WithSide(side, err)
+	}
+	stream := &rowStream{cursor: cursor, side: side, keyNames: keyNames, keyCols: keyCols}
+	if err := stream.next(); err != nil {
+		cursor.Close()
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (stream *rowStream) next() error {
+	stream.row = nil
+	if !stream.cursor.Next() {
+		return WithSide(stream.side, stream.cursor.Err())
+	}
+	var row Row
+	if err := stream.cursor.Scan(&row); err != nil {
+		return WithSide(stream.side, err)
+	}
+	stream.keys = make([]interface{}, len(stream.keyNames))
+	for i, name := range stream.keyNames {
+		stream.keys[i] = row[name]
+		if name != stream.keyCols[i] {
+			delete(row, name)
+		}
+	}
+	stream.row = row
+	return nil
+}
+
+func containsStr(items []string, item string) bool {
+	for _, s := range items {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+type DataSyncOptions struct {
+	// BatchSize is the number of rows per multi-row statement.
+	BatchSize int
+	// Upsert renders inserts and updates as INSERT ... ON DUPLICATE KEY UPDATE.
+	Upsert bool
+	// ServerVersion is the VERSION() of the new database. Upserts name the
+	// inserted row with the alias new on MySQL 8.0.20 and later, which
+	// deprecates VALUES(), and use VALUES() on the other servers.
+	ServerVersion string
+}
+
+// GenerateDataSync renders the statements that make the rows of the new
+// database match the old one. Deletes run child tables first, inserts and
+// updates parent tables first; when the foreign keys between the tables form
+// a cycle the script disables FOREIGN_KEY_CHECKS around itself.
+func GenerateDataSync(diffs []*DiffTableData, opts *DataSyncOptions) []string {
+	statements := generateDataSync(diffs, opts)
+	sqls := make([]string, len(statements))
+	for i, statement := range statements {
+		sqls[i] = statement.sql
+	}
+	return sqls
+}
+
+type syncStatement struct {
+	tableName string
+	sql       string
+}
+
+func generateDataSync(diffs []*DiffTableData, opts *DataSyncOptions) []*syncStatement {
+	if opts == nil {
+		opts = &DataSyncOptions{}
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultSyncBatchSize
+	}
+
+	var (
+		ordered, cyclic = sortByForeignKeys(diffs)
+		statements      = []*syncStatement{}
+		add             = func(tableName string, sqls []string) {
+			for _, sql := range sqls {
+				statements = append(statements, &syncStatement{tableName: tableName, sql: sql})
+			}
+		}
+	)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		add(ordered[i].TableName, ordered[i].deleteSqls(batchSize))
+	}
+	for _, diffTableData := range ordered {
+		if opts.Upsert {
+			add(diffTableData.TableName, diffTableData.upsertSqls(batchSize, rowAlias(opts.ServerVersion)))
+		} else {
+			add(diffTableData.TableName, diffTableData.insertSqls(batchSize))
+			add(diffTableData.TableName, diffTableData.updateSqls())
+		}
+	}
+
+	if cyclic && len(statements) != 0 {
+		statements = append([]*syncStatement{{sql: "SET FOREIGN_KEY_CHECKS=0"}}, statements...)
+		statements = append(statements, &syncStatement{sql: "SET FOREIGN_KEY_CHECKS=1"})
+	}
+	return statements
+}
+
+// sortByForeignKeys orders the tables of diffs as sortTablesByForeignKeys
+// does, a table referencing itself is cyclic when rows are inserted into it.
+func sortByForeignKeys(diffs []*DiffTableData) ([]*DiffTableData, bool) {
+	tables := make([]*Table, len(diffs))
+	for i, diffTableData := range diffs {
+		tables[i] = diffTableData.Table
+	}
+	order, cyclic := sortTablesByForeignKeys(tables)
+	ordered := make([]*DiffTableData, len(order))
+	for i, index := range order {
+		ordered[i] = diffs[index]
+		for _, foreignKey := range ordered[i].Table.ForeignKeyList {
+			if foreignKey.ReferencedTable == ordered[i].TableName {
+				cyclic = cyclic || ordered[i].hasInserts()
+			}
+		}
+	}
+	return ordered, cyclic
+}
+
+// sortTablesByForeignKeys returns the indexes of tables ordered parents
+// first, by name otherwise. When the foreign keys between different tables
+// form a cycle, cyclic is set and the tables left are kept in name order.
+func sortTablesByForeignKeys(tables []*Table) (order []int, cyclic bool) {
+	var (
+		pending = make(map[string]int, len(tables))
+		names   = make([]string, 0, len(tables))
+	)
+	for i, table := range tables {
+		pending[table.TableName] = i
+		names = append(names, table.TableName)
+	}
+	sort.Strings(names)
+
+	for len(pending) != 0 {
+		progress := false
+		for _, name := range names {
+			index, ok := pending[name]
+			if !ok {
+				continue
+			}
+			ready := true
+			for _, foreignKey := range tables[index].ForeignKeyList {
+				if foreignKey.ReferencedTable == name {
+					continue
+				}
+				if _, ok := pending[foreignKey.ReferencedTable]; ok {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				order = append(order, index)
+				delete(pending, name)
+				progress = true
+			}
+		}
+		if !progress {
+			//foreign keys form a cycle, keep the rest in name order
+			cyclic = true
+			for _, name := range names {
+				if index, ok := pending[name]; ok {
+					order = append(order, index)
+					delete(pending, name)
+				}
+			}
+		}
+	}
+	return order, cyclic
+}
+
+func (diff *DiffTableData) hasInserts() bool {
+	for _, diffRow := range diff.DiffRows {
+		if diffRow.ItemNew == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (diff *DiffTableData) deleteSqls(batchSize int) []string {
+	keys := []Row{}
+	for _, diffRow := range diff.DiffRows {
+		if diffRow.ItemOld == nil {
+			keys = append(keys, diffRow.ItemNew)
+		}
+	}
+
+	sqls := []string{}
+	for start := 0; start < len(keys); start += batchSize {
+		end := minInt(start+batchSize, len(keys))
+		var buff bytes.Buffer
+		buff.WriteString("DELETE FROM ")
+		buff.WriteString(quoteIdent(diff.TableName))
+		buff.WriteString(" WHERE ")
+		if len(diff.PrimaryKey) == 1 {
+			buff.WriteString(quoteIdent(diff.PrimaryKey[0]))
+		} else {
+			buff.WriteString("(")
+			buff.WriteString(quoteIdents(diff.PrimaryKey))
+			buff.WriteString(")")
+		}
+		buff.WriteString(" IN (")
+		for i, key := range keys[start:end] {
+			if i != 0 {
+				buff.WriteString(", ")
+			}
+			if len(diff.PrimaryKey) == 1 {
+				buff.WriteString(diff.literal(diff.PrimaryKey[0], key))
+			} else {
+				buff.WriteString("(")
+				buff.WriteString(diff.literals(diff.PrimaryKey, key))
+				buff.WriteString(")")
+			}
+		}
+		buff.WriteString(")")
+		sqls = append(sqls, buff.String())
+	}
+	return sqls
+}
+
+func (diff *DiffTableData) insertSqls(batchSize int) []string {
+	rows := []Row{}
+	for _, diffRow := range diff.DiffRows {
+		if diffRow.ItemNew == nil {
+			rows = append(rows, diffRow.ItemOld)
+		}
+	}
+	return diff.multiRowInsertSqls(rows, batchSize, nil, false)
+}
+
+func (diff *DiffTableData) updateSqls() []string {
+	sqls := []string{}
+	for _, diffRow := range diff.DiffRows {
+		if diffRow.ItemOld == nil || diffRow.ItemNew == nil {
+			continue
+		}
+		var buff bytes.Buffer
+		buff.WriteString("UPDATE ")
+		buff.WriteString(quoteIdent(diff.TableName))
+		buff.WriteString(" SET ")
+		for i, name := range diffRow.Columns {
+			if i != 0 {
+				buff.WriteString(", ")
+			}
+			buff.WriteString(quoteIdent(name))
+			buff.WriteString(" = ")
+			buff.WriteString(diff.literal(name, diffRow.ItemOld))
+		}
+		buff.WriteString(" WHERE ")
+		for i, name := range diff.PrimaryKey {
+			if i != 0 {
+				buff.WriteString(" AND ")
+			}
+			buff.WriteString(quoteIdent(name))
+			buff.WriteString(" = ")
+			buff.WriteString(diff.literal(name, diffRow.ItemNew))
+		}
+		sqls = append(sqls, buff.String())
+	}
+	return sqls
+}
+
+func (diff *DiffTableData) upsertSqls(batchSize int, alias bool) []string {
+	var (
+		inserts = []Row{}
+		//updates are grouped by their changed columns so each statement
+		//only overwrites what differs
+		updates    = make(map[string][]Row)
+		updateKeys = []string{}
+		updateCols = make(map[string][]string)
+	)
+	for _, diffRow := range diff.DiffRows {
+		switch {
+		case diffRow.ItemOld == nil:
+		case diffRow.ItemNew == nil:
+			inserts = append(inserts, diffRow.ItemOld)
+		default:
+			key := strings.Join(diffRow.Columns, ",")
+			if _, ok := updates[key]; !ok {
+				updateKeys = append(updateKeys, key)
+				updateCols[key] = diffRow.Columns
+			}
+			updates[key] = append(updates[key], diffRow.ItemOld)
+		}
+	}
+
+	updateAll := []string{}
+	for _, name := range diff.Columns {
+		if !containsStr(diff.PrimaryKey, name) {
+			updateAll = append(updateAll, name)
+		}
+	}
+	if len(updateAll) == 0 {
+		updateAll = diff.PrimaryKey[:1]
+	}
+
+	sqls := diff.multiRowInsertSqls(inserts, batchSize, updateAll, alias)
+	for _, key := range updateKeys {
+		sqls = append(sqls, diff.multiRowInsertSqls(updates[key], batchSize, updateCols[key], alias)...)
+	}
+	return sqls
+}
+
+func (diff *DiffTableData) multiRowInsertSqls(rows []Row, batchSize int, duplicateUpdate []string, alias bool) []string {
+	var (
+		names = diff.Columns
+		sqls  = []string{}
+	)
+	for start := 0; start < len(rows); start += batchSize {
+		end := minInt(start+batchSize, len(rows))
+		var buff bytes.Buffer
+		buff.WriteString("INSERT INTO ")
+		buff.WriteString(quoteIdent(diff.TableName))
+		buff.WriteString(" (")
+		buff.WriteString(quoteIdents(names))
+		buff.WriteString(") VALUES ")
+		for i, row := range rows[start:end] {
+			if i != 0 {
+				buff.WriteString(", ")
+			}
+			buff.WriteString("(")
+			buff.WriteString(diff.literals(names, row))
+			buff.WriteString(")")
+		}
+		if len(duplicateUpdate) != 0 {
+			if alias {
+				buff.WriteString(" AS `new`")
+			}
+			buff.WriteString(" ON DUPLICATE KEY UPDATE ")
+			for i, name := range duplicateUpdate {
+				if i != 0 {
+					buff.WriteString(", ")
+				}
+				if alias {
+					buff.WriteString(fmt.Sprintf("%s = `new`.%s", quoteIdent(name), quoteIdent(name)))
+				} else {
+					buff.WriteString(fmt.Sprintf("%s = VALUES(%s)", quoteIdent(name), quoteIdent(name)))
+				}
+			}
+		}
+		sqls = append(sqls, buff.String())
+	}
+	return sqls
+}
+
+func (diff *DiffTableData) literal(name string, row Row) string {
+	return SqlLiteral(diff.Table.Column(name), row[name])
+}
+
+func (diff *DiffTableData) literals(names []string, row Row) string {
+	literals := make([]string, len(names))
+	for i, name := range names {
+		literals[i] = diff.literal(name, row)
+	}
+	return strings.Join(literals, ", ")
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SqlLiteral renders value as a MySQL literal for column. Binary values are
+// written as hex literals, JSON is cast back to JSON and everything that is
+// not a plain number is quoted as quoteLiteral does.
+func SqlLiteral(column *Column, value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+	category := columnCategoryString
+	if column != nil {
+		category = column.category()
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		if category == columnCategoryDate {
+			return quoteLiteral(v.Format("2006-01-02"))
+		}
+		return quoteLiteral(v.Format("2006-01-02 15:04:05.999999"))
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	}
+
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		raw = []byte(fmt.Sprint(v))
+	}
+	switch category {
+	case columnCategoryBinary:
+		if len(raw) == 0 {
+			return "''"
+		}
+		return "X'" + strings.ToUpper(hex.EncodeToString(raw)) + "'"
+	case columnCategoryNumeric:
+		if isNumericLiteral(raw) {
+			return string(raw)
+		}
+	case columnCategoryJson:
+		return "CAST(" + quoteLiteral(string(raw)) + " AS JSON)"
+	}
+	return quoteLiteral(string(raw))
+}
+
+// quoteLiteral renders str as a string literal that reads the same whatever
+// the sql_mode. Quotes are doubled, and a string holding backslashes or
+// control characters, which NO_BACKSLASH_ESCAPES changes or a script may not
+// carry, is written as a hex literal: introduced as utf8mb4 when it is valid
+// UTF-8 so that it stays text, a binary string otherwise.
+func quoteLiteral(str string) string {
+	for i := 0; i < len(str); i++ {
+		if c := str[i]; c == '\\' || c < 0x20 && c != '\t' || c == 0x7f {
+			return hexLiteral(str)
+		}
+	}
+	if !utf8.ValidString(str) {
+		return hexLiteral(str)
+	}
+	return "'" + strings.Replace(str, "'", "''", -1) + "'"
+}
+
+func hexLiteral(str string) string {
+	literal := "X'" + strings.ToUpper(hex.EncodeToString([]byte(str))) + "'"
+	if utf8.ValidString(str) {
+		return "_utf8mb4 " + literal
+	}
+	return literal
+}
+
+// quoteText renders str for the COMMENT clauses of DDL, which only take a
+// quoted string. Quotes are doubled and backslashes escaped, which reads
+// right unless the sql_mode has NO_BACKSLASH_ESCAPES; control characters are
+// kept as they are.
+func quoteText(str string) string {
+	return "'" + strings.NewReplacer("'", "''", "\\", "\\\\").Replace(str) + "'"
+}
+
+func isNumericLiteral(raw []byte) bool {
+	_, err := strconv.ParseFloat(string(raw), 64)
+	return err == nil
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+const (
+	columnCategoryString = iota
+	columnCategoryNumeric
+	columnCategoryBinary
+	columnCategoryJson
+	columnCategoryDate
+	columnCategoryTemporal
+)
+
+func (column *Column) category() int {
+	dataType, err := ParseDataType(column.ColumnType)
+	if err != nil {
+		return columnCategoryString
+	}
+	switch dataType.Family() {
+	case FamilyInteger, FamilyDecimal, FamilyFloat:
+		return columnCategoryNumeric
+	case FamilyBinary, FamilyBit, FamilySpatial:
+		return columnCategoryBinary
+	case FamilyJson:
+		return columnCategoryJson
+	case FamilyTemporal:
+		if dataType.Base == "date" {
+			return columnCategoryDate
+		}
+		return columnCategoryTemporal
+	}
+	return columnCategoryString
+}
+
+func (column *Column) isGenerated() bool {
+	return strings.Contains(strings.ToUpper(column.Extra), "GENERATED") &&
+		!strings.Contains(strings.ToUpper(column.Extra), "DEFAULT_GENERATED")
+}
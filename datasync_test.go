@@ -0,0 +1,239 @@
+package dbdiff
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func syncTestTable(tableName string, foreignKeys ...*ForeignKey) *Table {
+	columns := []*Column{
+		{ColumnScheme: ColumnScheme{TableName: tableName, ColumnName: "id", ColumnType: "int(11)"}},
+		{ColumnScheme: ColumnScheme{TableName: tableName, ColumnName: "name", ColumnType: "varchar(64)"}},
+		{ColumnScheme: ColumnScheme{TableName: tableName, ColumnName: "parent_id", ColumnType: "int(11)"}},
+		{ColumnScheme: ColumnScheme{TableName: tableName, ColumnName: "name_len", ColumnType: "int(11)", Extra: "VIRTUAL GENERATED"}},
+	}
+	return &Table{
+		TableScheme:    TableScheme{TableName: tableName},
+		ColumnList:     columns,
+		IndexList:      []*Index{{TableName: tableName, KeyName: "PRIMARY", Columns: []string{"id"}}},
+		ForeignKeyList: foreignKeys,
+	}
+}
+
+func syncTestRow(id, name string, parentId interface{}) Row {
+	return Row{"id": []byte(id), "name": []byte(name), "parent_id": parentId, "name_len": []byte("0")}
+}
+
+func TestDiffTableRows(t *testing.T) {
+	table := syncTestTable("student")
+	rowsOld := []Row{syncTestRow("10", "b", nil), syncTestRow("2", "a", nil), syncTestRow("3", "c", nil)}
+	rowsNew := []Row{syncTestRow("3", "c", nil), syncTestRow("2", "x", nil), syncTestRow("4", "d", nil)}
+	rowsNew[0]["name_len"] = []byte("1")
+
+	diff, err := DiffTableRows(table, rowsOld, rowsNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "DiffTableRows", "size", len(diff.DiffRows), 3)
+	verify(t, 2, "DiffTableRows", "update", strings.Join(diff.DiffRows[0].Columns, ","), "name")
+	verify(t, 3, "DiffTableRows", "delete", string(diff.DiffRows[1].ItemNew["id"].([]byte)), "4")
+	verify(t, 4, "DiffTableRows", "insert", string(diff.DiffRows[2].ItemOld["id"].([]byte)), "10")
+
+	_, err = DiffTableRows(&Table{TableScheme: TableScheme{TableName: "log"}}, nil, nil)
+	verify(t, 5, "DiffTableRows", "no primary key", err != nil, true)
+}
+
+func TestDiffTableRows_mixedKeys(t *testing.T) {
+	table := &Table{
+		TableScheme: TableScheme{TableName: "code"},
+		ColumnList: []*Column{
+			{ColumnScheme: ColumnScheme{TableName: "code", ColumnName: "code", ColumnType: "varchar(16)"}},
+			{ColumnScheme: ColumnScheme{TableName: "code", ColumnName: "price", ColumnType: "decimal(10,2)"}},
+		},
+		IndexList: []*Index{{TableName: "code", KeyName: "PRIMARY", Columns: []string{"code"}}},
+	}
+	row := func(code, price string) Row {
+		return Row{"code": []byte(code), "price": []byte(price)}
+	}
+	//as numbers and strings 2 < 10 < 1a < 2, the keys must be compared as strings
+	keys := [][]string{
+		{"2", "10", "1a", "b", "011"},
+		{"1a", "b", "011", "10", "2"},
+		{"b", "011", "2", "1a", "10"},
+	}
+	for i, oldKeys := range keys {
+		for j, newKeys := range keys {
+			rowsOld, rowsNew := []Row{}, []Row{}
+			for _, key := range oldKeys {
+				rowsOld = append(rowsOld, row(key, "1.50"))
+			}
+			for _, key := range newKeys {
+				price := "1.5"
+				if key == "10" {
+					price = "2"
+				}
+				rowsNew = append(rowsNew, row(key, price))
+			}
+			diff, err := DiffTableRows(table, rowsOld, rowsNew)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify(t, i*len(keys)+j+1, "DiffTableRows", oldKeys, len(diff.DiffRows), 1)
+			verify(t, i*len(keys)+j+1, "DiffTableRows", newKeys, string(diff.DiffRows[0].ItemOld["code"].([]byte)), "10")
+		}
+	}
+
+	verify(t, 20, "compareNumber", "order", compareNumber([]byte("9"), []byte("10")), -1)
+	verify(t, 21, "compareNumber", "unsigned", compareNumber([]byte("18446744073709551615"), []byte("-1")), 1)
+	verify(t, 22, "compareText", "order", compareText([]byte("9"), []byte("10")), 1)
+	verify(t, 23, "compareText", "null", compareText(nil, []byte("")), -1)
+}
+
+func TestGenerateDataSync(t *testing.T) {
+	var (
+		parent = syncTestTable("parent")
+		child  = syncTestTable("child", &ForeignKey{TableName: "child", ReferencedTable: "parent"})
+	)
+	diffChild, _ := DiffTableRows(child,
+		[]Row{syncTestRow("1", "a", []byte("1"))},
+		[]Row{syncTestRow("2", "b", []byte("1"))})
+	diffParent, _ := DiffTableRows(parent,
+		[]Row{syncTestRow("1", "it's", nil), syncTestRow("2", "b", nil), syncTestRow("3", "c", nil)},
+		[]Row{syncTestRow("1", "x", nil), syncTestRow("4", "d", nil)})
+
+	sqls := GenerateDataSync([]*DiffTableData{diffChild, diffParent}, &DataSyncOptions{BatchSize: 1})
+	expected := []string{
+		"DELETE FROM `child` WHERE `id` IN (2)",
+		"DELETE FROM `parent` WHERE `id` IN (4)",
+		"INSERT INTO `parent` (`id`, `name`, `parent_id`) VALUES (2, 'b', NULL)",
+		"INSERT INTO `parent` (`id`, `name`, `parent_id`) VALUES (3, 'c', NULL)",
+		"UPDATE `parent` SET `name` = 'it''s' WHERE `id` = 1",
+		"INSERT INTO `child` (`id`, `name`, `parent_id`) VALUES (1, 'a', 1)",
+	}
+	verify(t, 1, "GenerateDataSync", "size", len(sqls), len(expected))
+	for i := 0; i < len(expected) && i < len(sqls); i++ {
+		verify(t, i+2, "GenerateDataSync", i, sqls[i], expected[i])
+	}
+
+	sqls = GenerateDataSync([]*DiffTableData{diffParent}, &DataSyncOptions{Upsert: true})
+	expected = []string{
+		"DELETE FROM `parent` WHERE `id` IN (4)",
+		"INSERT INTO `parent` (`id`, `name`, `parent_id`) VALUES (2, 'b', NULL), (3, 'c', NULL) " +
+			"ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `parent_id` = VALUES(`parent_id`)",
+		"INSERT INTO `parent` (`id`, `name`, `parent_id`) VALUES (1, 'it''s', NULL) " +
+			"ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)",
+	}
+	verify(t, 10, "GenerateDataSync upsert", "size", len(sqls), len(expected))
+	for i := 0; i < len(expected) && i < len(sqls); i++ {
+		verify(t, i+11, "GenerateDataSync upsert", i, sqls[i], expected[i])
+	}
+
+	sqls = GenerateDataSync([]*DiffTableData{diffParent}, &DataSyncOptions{Upsert: true, ServerVersion: "8.0.36"})
+	verify(t, 14, "GenerateDataSync upsert", "row alias", sqls[2], "INSERT INTO `parent` (`id`, `name`, `parent_id`) "+
+		"VALUES (1, 'it''s', NULL) AS `new` ON DUPLICATE KEY UPDATE `name` = `new`.`name`")
+	versions := map[string]bool{"8.0.19": false, "8.0.20": true, "9.1.0": true, "5.5.5-11.4.2-MariaDB": false, "": false}
+	for version, expected := range versions {
+		verify(t, 15, "rowAlias", version, rowAlias(version), expected)
+	}
+}
+
+func TestDiffTableData_compareStreams(t *testing.T) {
+	column := func(name, columnType, collation, extra string) *Column {
+		return &Column{ColumnScheme: ColumnScheme{TableName: "code", ColumnName: name, ColumnType: columnType, CollationName: collation, Extra: extra}}
+	}
+	table := func(columns ...*Column) *Table {
+		return &Table{
+			TableScheme: TableScheme{TableName: "code"},
+			ColumnList:  append([]*Column{column("code", "varchar(8)", "utf8mb4_general_ci", "")}, columns...),
+			IndexList:   []*Index{{TableName: "code", KeyName: "PRIMARY", Columns: []string{"code"}}},
+		}
+	}
+	var (
+		tableOld = table(column("name", "varchar(8)", "", ""), column("extra", "int", "", ""),
+			column("name_len", "int", "", "VIRTUAL GENERATED"))
+		tableNew = table(column("name", "varchar(8)", "", ""), column("name_len", "int", "", ""),
+			column("note", "int", "", ""))
+		columns = []string{"code", "name", "dbdiff_weight_0"}
+		side    = func(side string, rows ...[]driver.Value) *DBTemplate {
+			rowsResults[t.Name()+side] = &rowsResult{columns: columns, rows: rows}
+			db, err := sql.Open("dbdiff-rows", t.Name()+side)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return NewDBTemplate(db)
+		}
+	)
+	diff, err := newDiffTableData(tableOld, tableNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "newDiffTableData", "columns", strings.Join(diff.Columns, ","), "code,name")
+	verify(t, 2, "newDiffTableData", "skipped", strings.Join(diff.SkippedColumns, ","), "extra,name_len,note")
+
+	//the server sorts the keys case insensitively, as their weights
+	tplOld := side("old",
+		[]driver.Value{[]byte("a"), []byte("x"), []byte("A")},
+		[]driver.Value{[]byte("B"), []byte("y"), []byte("B")})
+	tplNew := side("new",
+		[]driver.Value{[]byte("A"), []byte("x"), []byte("A")},
+		[]driver.Value{[]byte("c"), []byte("z"), []byte("C")})
+	if err := diff.compareStreams(context.Background(), tplOld, tplNew, tableNew); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 3, "compareStreams", "query", rowsResults[t.Name()+"old"].log[0],
+		"SELECT `code`, `name`, WEIGHT_STRING(`code`) AS `dbdiff_weight_0` FROM `code` ORDER BY `code`")
+	verify(t, 4, "compareStreams", "size", len(diff.DiffRows), 3)
+	verify(t, 5, "compareStreams", "same key", strings.Join(diff.DiffRows[0].Columns, ","), "code")
+	verify(t, 6, "compareStreams", "weight dropped", len(diff.DiffRows[0].ItemOld), 2)
+	verify(t, 7, "compareStreams", "insert", string(diff.DiffRows[1].ItemOld["code"].([]byte)), "B")
+	verify(t, 8, "compareStreams", "delete", string(diff.DiffRows[2].ItemNew["code"].([]byte)), "c")
+
+	tableNew.Column("code").CollationName = "utf8mb4_bin"
+	_, ok := diff.compareStreams(context.Background(), tplOld, tplNew, tableNew).(*DataSyncError)
+	verify(t, 9, "compareStreams", "collations differ", ok, true)
+	tableNew.IndexList[0].Columns = []string{"name"}
+	_, err = newDiffTableData(tableOld, tableNew)
+	verify(t, 10, "newDiffTableData", "primary keys differ", err != nil, true)
+}
+
+func TestGenerateDataSync_Cycle(t *testing.T) {
+	table := syncTestTable("tree", &ForeignKey{TableName: "tree", ReferencedTable: "tree"})
+	diff, _ := DiffTableRows(table, []Row{syncTestRow("1", "root", nil)}, nil)
+	sqls := GenerateDataSync([]*DiffTableData{diff}, nil)
+	verify(t, 1, "GenerateDataSync cycle", "first", sqls[0], "SET FOREIGN_KEY_CHECKS=0")
+	verify(t, 2, "GenerateDataSync cycle", "last", sqls[len(sqls)-1], "SET FOREIGN_KEY_CHECKS=1")
+}
+
+func TestSqlLiteral(t *testing.T) {
+	column := func(columnType string) *Column {
+		return &Column{ColumnScheme: ColumnScheme{ColumnType: columnType}}
+	}
+	cases := []struct {
+		column   *Column
+		value    interface{}
+		expected string
+	}{
+		{column("int(11)"), nil, "NULL"},
+		{column("int(11)"), []byte("-12"), "-12"},
+		{column("decimal(10,2)"), []byte("1.50"), "1.50"},
+		{column("varchar(64)"), []byte("it's"), "'it''s'"},
+		{column("varchar(64)"), []byte("a'b\\c\n"), "_utf8mb4 X'6127625C630A'"},
+		{column("varchar(64)"), []byte{'a', 0xff}, "X'61FF'"},
+		{column("varbinary(16)"), []byte{0x00, 0xff}, "X'00FF'"},
+		{column("blob"), []byte{}, "''"},
+		{column("json"), []byte(`{"a": "b'c"}`), `CAST('{"a": "b''c"}' AS JSON)`},
+		{column("json"), []byte(`{"a": "b\"c"}`), `CAST(_utf8mb4 X'7B2261223A2022625C2263227D' AS JSON)`},
+		{column("datetime(3)"), time.Date(2018, 1, 2, 3, 4, 5, 6000000, time.UTC), "'2018-01-02 03:04:05.006'"},
+		{column("date"), time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC), "'2018-01-02'"},
+		{column("timestamp"), []byte("0000-00-00 00:00:00"), "'0000-00-00 00:00:00'"},
+		{nil, int64(7), "7"},
+	}
+	for i, c := range cases {
+		verify(t, i+1, "SqlLiteral", c.value, SqlLiteral(c.column, c.value), c.expected)
+	}
+	verify(t, len(cases)+1, "quoteText", "escaped", quoteText(`it's C:\tmp`), `'it''s C:\\tmp'`)
+}
@@ -0,0 +1,519 @@
+package dbdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type TypeFamily string
+
+const (
+	FamilyInteger  TypeFamily = "integer"
+	FamilyDecimal  TypeFamily = "decimal"
+	FamilyFloat    TypeFamily = "float"
+	FamilyBit      TypeFamily = "bit"
+	FamilyString   TypeFamily = "string"
+	FamilyBinary   TypeFamily = "binary"
+	FamilyTemporal TypeFamily = "temporal"
+	FamilyEnum     TypeFamily = "enum"
+	FamilySet      TypeFamily = "set"
+	FamilyJson     TypeFamily = "json"
+	FamilySpatial  TypeFamily = "spatial"
+)
+
+type baseTypeInfo struct {
+	family TypeFamily
+	//bytes of integers, maximum length of text and blob types
+	size uint64
+	//precision of the default length, 0 when the type takes no length
+	defaultLength int
+}
+
+var baseTypes = map[string]baseTypeInfo{
+	"tinyint":            {FamilyInteger, 1, 0},
+	"smallint":           {FamilyInteger, 2, 0},
+	"mediumint":          {FamilyInteger, 3, 0},
+	"int":                {FamilyInteger, 4, 0},
+	"bigint":             {FamilyInteger, 8, 0},
+	"decimal":            {FamilyDecimal, 0, 10},
+	"float":              {FamilyFloat, 4, 0},
+	"double":             {FamilyFloat, 8, 0},
+	"bit":                {FamilyBit, 0, 1},
+	"char":               {FamilyString, 0, 1},
+	"varchar":            {FamilyString, 0, 0},
+	"tinytext":           {FamilyString, 255, 0},
+	"text":               {FamilyString, 65535, 0},
+	"mediumtext":         {FamilyString, 16777215, 0},
+	"longtext":           {FamilyString, 4294967295, 0},
+	"binary":             {FamilyBinary, 0, 1},
+	"varbinary":          {FamilyBinary, 0, 0},
+	"tinyblob":           {FamilyBinary, 255, 0},
+	"blob":               {FamilyBinary, 65535, 0},
+	"mediumblob":         {FamilyBinary, 16777215, 0},
+	"longblob":           {FamilyBinary, 4294967295, 0},
+	"date":               {FamilyTemporal, 0, 0},
+	"time":               {FamilyTemporal, 0, 0},
+	"datetime":           {FamilyTemporal, 0, 0},
+	"timestamp":          {FamilyTemporal, 0, 0},
+	"year":               {FamilyTemporal, 0, 0},
+	"enum":               {FamilyEnum, 0, 0},
+	"set":                {FamilySet, 0, 0},
+	"json":               {FamilyJson, 0, 0},
+	"geometry":           {FamilySpatial, 0, 0},
+	"point":              {FamilySpatial, 0, 0},
+	"linestring":         {FamilySpatial, 0, 0},
+	"polygon":            {FamilySpatial, 0, 0},
+	"multipoint":         {FamilySpatial, 0, 0},
+	"multilinestring":    {FamilySpatial, 0, 0},
+	"multipolygon":       {FamilySpatial, 0, 0},
+	"geometrycollection": {FamilySpatial, 0, 0},
+}
+
+var baseTypeAliases = map[string]string{
+	"integer":           "int",
+	"int1":              "tinyint",
+	"int2":              "smallint",
+	"int3":              "mediumint",
+	"int4":              "int",
+	"int8":              "bigint",
+	"middleint":         "mediumint",
+	"bool":              "tinyint",
+	"boolean":           "tinyint",
+	"dec":               "decimal",
+	"numeric":           "decimal",
+	"fixed":             "decimal",
+	"real":              "double",
+	"double precision":  "double",
+	"float4":            "float",
+	"float8":            "double",
+	"character":         "char",
+	"nchar":             "char",
+	"national char":     "char",
+	"nvarchar":          "varchar",
+	"character varying": "varchar",
+	"national varchar":  "varchar",
+	"long":              "mediumtext",
+	"long varchar":      "mediumtext",
+	"long varbinary":    "mediumblob",
+	"geomcollection":    "geometrycollection",
+}
+
+// DataType is a parsed COLUMN_TYPE. Length is the display width of
+// integers, the character length of strings, the byte length of binaries and
+// the bit count of bit; it is -1 when the type does not carry one.
+// Precision and Scale belong to decimal and float(M,D), Fsp to time,
+// datetime and timestamp.
+type DataType struct {
+	Base      string
+	Length    int
+	Precision int
+	Scale     int
+	Fsp       int
+	Unsigned  bool
+	Zerofill  bool
+	Members   []string
+	Charset   string
+	Collation string
+}
+
+func (dataType *DataType) Family() TypeFamily {
+	return baseTypes[dataType.Base].family
+}
+
+// ParseDataType parses a column type as reported by COLUMN_TYPE or written
+// in a column definition, aliases resolved.
+func ParseDataType(columnType string) (*DataType, error) {
+	var (
+		src      = strings.TrimSpace(columnType)
+		lower    = strings.ToLower(src)
+		dataType = &DataType{Length: -1}
+		base     = lower
+		rest     = ""
+	)
+	if i := strings.IndexAny(lower, "( "); i >= 0 {
+		base, rest = lower[:i], src[i:]
+	}
+	//multi word aliases such as double precision
+	for alias, canonical := range baseTypeAliases {
+		if strings.Contains(alias, " ") && (lower == alias || strings.HasPrefix(lower, alias+" ") || strings.HasPrefix(lower, alias+"(")) {
+			base, rest = canonical, src[len(alias):]
+			break
+		}
+	}
+	if canonical, ok := baseTypeAliases[base]; ok {
+		if (base == "bool" || base == "boolean") && !strings.HasPrefix(strings.TrimSpace(rest), "(") {
+			dataType.Length = 1
+		}
+		base = canonical
+	}
+	info, ok := baseTypes[base]
+	if !ok {
+		return nil, &TypeParseError{ColumnType: columnType, Message: "unknown type " + base}
+	}
+	dataType.Base = base
+
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "(") {
+		params, remain, err := splitTypeParams(rest)
+		if err != nil {
+			return nil, &TypeParseError{ColumnType: columnType, Message: err.Error()}
+		}
+		rest = remain
+		if err := dataType.applyParams(info.family, params); err != nil {
+			return nil, &TypeParseError{ColumnType: columnType, Message: err.Error()}
+		}
+	}
+	dataType.applyDefaults(info)
+
+	words := strings.Fields(rest)
+	for i := 0; i < len(words); i++ {
+		switch strings.ToLower(words[i]) {
+		case "unsigned":
+			dataType.Unsigned = true
+		case "signed":
+		case "zerofill":
+			dataType.Zerofill = true
+			dataType.Unsigned = true
+		case "character", "charset":
+			if strings.ToLower(words[i]) == "character" {
+				i++
+			}
+			if i+1 < len(words) {
+				dataType.Charset = strings.ToLower(words[i+1])
+				i++
+			}
+		case "collate":
+			if i+1 < len(words) {
+				dataType.Collation = strings.ToLower(words[i+1])
+				i++
+			}
+		case "binary", "ascii", "unicode", "national":
+		default:
+			return nil, &TypeParseError{ColumnType: columnType, Message: "unknown attribute " + words[i]}
+		}
+	}
+	return dataType, nil
+}
+
+// splitTypeParams splits "(a,b) rest" into its parameters, keeping quoted
+// enum members intact.
+func splitTypeParams(s string) ([]string, string, error) {
+	var (
+		params  = []string{}
+		buff    bytes.Buffer
+		inQuote = false
+	)
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuote && c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			buff.WriteString("''")
+			i++
+		case inQuote && c == '\\' && i+1 < len(s):
+			buff.WriteByte(c)
+			buff.WriteByte(s[i+1])
+			i++
+		case c == '\'':
+			inQuote = !inQuote
+			buff.WriteByte(c)
+		case !inQuote && c == ',':
+			params = append(params, strings.TrimSpace(buff.String()))
+			buff.Reset()
+		case !inQuote && c == ')':
+			params = append(params, strings.TrimSpace(buff.String()))
+			return params, s[i+1:], nil
+		default:
+			buff.WriteByte(c)
+		}
+	}
+	return nil, "", fmt.Errorf("unterminated parameters")
+}
+
+func (dataType *DataType) applyParams(family TypeFamily, params []string) error {
+	if family == FamilyEnum || family == FamilySet {
+		for _, param := range params {
+			if len(param) < 2 || param[0] != '\'' || param[len(param)-1] != '\'' {
+				return fmt.Errorf("invalid member %s", param)
+			}
+			member := param[1 : len(param)-1]
+			member = strings.Replace(member, "''", "'", -1)
+			member = strings.Replace(member, `\\`, `\`, -1)
+			dataType.Members = append(dataType.Members, member)
+		}
+		return nil
+	}
+
+	numbers := make([]int, len(params))
+	for i, param := range params {
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return fmt.Errorf("invalid parameter %s", param)
+		}
+		numbers[i] = n
+	}
+	switch {
+	case family == FamilyDecimal || family == FamilyFloat:
+		dataType.Precision = numbers[0]
+		if len(numbers) > 1 {
+			dataType.Scale = numbers[1]
+		}
+	case family == FamilyTemporal:
+		dataType.Fsp = numbers[0]
+	default:
+		dataType.Length = numbers[0]
+	}
+	return nil
+}
+
+func (dataType *DataType) applyDefaults(info baseTypeInfo) {
+	switch info.family {
+	case FamilyDecimal:
+		if dataType.Precision == 0 {
+			dataType.Precision = info.defaultLength
+		}
+	case FamilyString, FamilyBinary, FamilyBit:
+		if dataType.Length < 0 && info.defaultLength != 0 {
+			dataType.Length = info.defaultLength
+		}
+	}
+	if dataType.Base == "year" {
+		//year(4) is the only year left
+		dataType.Length = -1
+		dataType.Fsp = 0
+	}
+}
+
+// String renders the type the way MySQL 8.0 reports it: integer display
+// widths are only kept for tinyint(1) and zerofill columns.
+func (dataType *DataType) String() string {
+	return dataType.render(false)
+}
+
+func (dataType *DataType) render(keepDisplayWidth bool) string {
+	var buff bytes.Buffer
+	buff.WriteString(dataType.Base)
+	switch dataType.Family() {
+	case FamilyInteger:
+		if dataType.Length >= 0 && (keepDisplayWidth || dataType.Zerofill || dataType.Base == "tinyint" && dataType.Length == 1) {
+			buff.WriteString(fmt.Sprintf("(%d)", dataType.Length))
+		}
+	case FamilyDecimal:
+		buff.WriteString(fmt.Sprintf("(%d,%d)", dataType.Precision, dataType.Scale))
+	case FamilyFloat:
+		if dataType.Precision != 0 {
+			buff.WriteString(fmt.Sprintf("(%d,%d)", dataType.Precision, dataType.Scale))
+		}
+	case FamilyTemporal:
+		if dataType.Fsp != 0 {
+			buff.WriteString(fmt.Sprintf("(%d)", dataType.Fsp))
+		}
+	case FamilyEnum, FamilySet:
+		members := make([]string, len(dataType.Members))
+		for i, member := range dataType.Members {
+			members[i] = "'" + strings.Replace(strings.Replace(member, `\`, `\\`, -1), "'", "''", -1) + "'"
+		}
+		buff.WriteString("(" + strings.Join(members, ",") + ")")
+	default:
+		if dataType.Length >= 0 && baseTypes[dataType.Base].size == 0 {
+			buff.WriteString(fmt.Sprintf("(%d)", dataType.Length))
+		}
+	}
+	if dataType.Unsigned {
+		buff.WriteString(" unsigned")
+	}
+	if dataType.Zerofill {
+		buff.WriteString(" zerofill")
+	}
+	return buff.String()
+}
+
+// DataType parses the type of the column with its charset and collation.
+func (column *Column) DataType() (*DataType, error) {
+	dataType, err := ParseDataType(column.ColumnType)
+	if err != nil {
+		return nil, err
+	}
+	dataType.Charset = strings.ToLower(column.CharacterSetName)
+	dataType.Collation = strings.ToLower(column.CollationName)
+	return dataType, nil
+}
+
+type TypeChange int
+
+const (
+	TypeSame TypeChange = iota
+	TypeWidening
+	TypeNarrowing
+	TypeIncompatible
+)
+
+func (change TypeChange) String() string {
+	switch change {
+	case TypeSame:
+		return "same"
+	case TypeWidening:
+		return "widening"
+	case TypeNarrowing:
+		return "narrowing"
+	case TypeIncompatible:
+		return "incompatible"
+	}
+	return "unknown"
+}
+
+// CompareDataType tells whether converting a column of type from to type to
+// keeps every value (same or widening), may lose values (narrowing) or cannot
+// convert them (incompatible). Charset and collation are not compared.
+func CompareDataType(from, to *DataType) TypeChange {
+	if from.render(false) == to.render(false) {
+		return TypeSame
+	}
+	var (
+		familyFrom = from.Family()
+		familyTo   = to.Family()
+	)
+	switch {
+	case familyFrom == FamilyInteger || familyFrom == FamilyDecimal || familyFrom == FamilyFloat:
+		return compareNumeric(from, to)
+	case familyFrom == FamilyString && familyTo == FamilyString,
+		familyFrom == FamilyBinary && familyTo == FamilyBinary,
+		familyFrom == FamilyBit && familyTo == FamilyBit:
+		return widenIf(from.maxLength() <= to.maxLength())
+	case familyFrom == FamilyTemporal && familyTo == FamilyTemporal:
+		return compareTemporal(from, to)
+	case familyFrom == FamilyTemporal && familyTo == FamilyString:
+		return widenIf(uint64(from.displayLength()) <= to.maxLength())
+	case (familyFrom == FamilyEnum || familyFrom == FamilySet) && familyFrom == familyTo:
+		for _, member := range from.Members {
+			if !containsStr(to.Members, member) {
+				return TypeNarrowing
+			}
+		}
+		return TypeWidening
+	case (familyFrom == FamilyEnum || familyFrom == FamilySet) && familyTo == FamilyString:
+		return widenIf(uint64(from.displayLength()) <= to.maxLength())
+	case familyFrom == FamilySpatial && familyTo == FamilySpatial:
+		return widenIf(to.Base == "geometry")
+	}
+	return TypeIncompatible
+}
+
+func widenIf(ok bool) TypeChange {
+	if ok {
+		return TypeWidening
+	}
+	return TypeNarrowing
+}
+
+func (dataType *DataType) maxLength() uint64 {
+	if size := baseTypes[dataType.Base].size; size != 0 && dataType.Family() != FamilyInteger {
+		return size
+	}
+	if dataType.Length < 0 {
+		return 0
+	}
+	return uint64(dataType.Length)
+}
+
+// displayLength is the number of characters needed to print any value.
+func (dataType *DataType) displayLength() int {
+	switch dataType.Family() {
+	case FamilyInteger:
+		return dataType.integerDigits() + 1
+	case FamilyDecimal:
+		return dataType.Precision + 2
+	case FamilyFloat:
+		return 23
+	case FamilyEnum, FamilySet:
+		length := 0
+		for _, member := range dataType.Members {
+			if dataType.Family() == FamilySet {
+				length += len(member) + 1
+			} else if len(member) > length {
+				length = len(member)
+			}
+		}
+		return length
+	}
+	fsp := 0
+	if dataType.Fsp != 0 {
+		fsp = dataType.Fsp + 1
+	}
+	switch dataType.Base {
+	case "date":
+		return 10
+	case "time":
+		return 10 + fsp
+	case "year":
+		return 4
+	}
+	return 19 + fsp
+}
+
+func (dataType *DataType) integerRange() (int64, uint64) {
+	bits := baseTypes[dataType.Base].size * 8
+	if dataType.Unsigned {
+		return 0, 1<<bits - 1
+	}
+	return -(1 << (bits - 1)), 1<<(bits-1) - 1
+}
+
+func (dataType *DataType) integerDigits() int {
+	_, max := dataType.integerRange()
+	return len(strconv.FormatUint(max, 10))
+}
+
+func compareNumeric(from, to *DataType) TypeChange {
+	var (
+		familyFrom = from.Family()
+		familyTo   = to.Family()
+		signLoss   = !from.Unsigned && to.Unsigned
+	)
+	switch {
+	case familyTo == FamilyString:
+		return widenIf(uint64(from.displayLength()) <= to.maxLength())
+	case familyTo != FamilyInteger && familyTo != FamilyDecimal && familyTo != FamilyFloat:
+		return TypeIncompatible
+	case familyFrom == FamilyInteger && familyTo == FamilyInteger:
+		minFrom, maxFrom := from.integerRange()
+		minTo, maxTo := to.integerRange()
+		return widenIf(minFrom >= minTo && maxFrom <= maxTo)
+	case familyFrom == FamilyInteger && familyTo == FamilyDecimal:
+		return widenIf(!signLoss && from.integerDigits() <= to.Precision-to.Scale)
+	case familyFrom == FamilyDecimal && familyTo == FamilyInteger:
+		return widenIf(!signLoss && from.Scale == 0 && from.Precision < to.integerDigits())
+	case familyFrom == FamilyDecimal && familyTo == FamilyDecimal:
+		return widenIf(!signLoss && from.Precision-from.Scale <= to.Precision-to.Scale && from.Scale <= to.Scale)
+	case familyFrom == FamilyInteger && familyTo == FamilyFloat:
+		//exact integers of the mantissa: 24 bits for float, 53 for double
+		mantissa := uint64(24)
+		if to.Base == "double" {
+			mantissa = 53
+		}
+		return widenIf(!signLoss && baseTypes[from.Base].size*8 <= mantissa)
+	case familyFrom == FamilyFloat && familyTo == FamilyFloat:
+		return widenIf(!signLoss && baseTypes[from.Base].size <= baseTypes[to.Base].size &&
+			(to.Precision == 0 || from.Precision != 0 && from.Precision <= to.Precision && from.Scale <= to.Scale))
+	}
+	return TypeNarrowing
+}
+
+func compareTemporal(from, to *DataType) TypeChange {
+	if from.Fsp > to.Fsp {
+		return TypeNarrowing
+	}
+	switch {
+	case from.Base == to.Base:
+		return TypeWidening
+	case from.Base == "date" && (to.Base == "datetime" || to.Base == "timestamp"):
+		return widenIf(to.Base == "datetime")
+	case from.Base == "timestamp" && to.Base == "datetime":
+		return TypeWidening
+	case from.Base == "datetime" && to.Base == "timestamp",
+		(from.Base == "datetime" || from.Base == "timestamp") && to.Base == "date":
+		return TypeNarrowing
+	}
+	return TypeIncompatible
+}
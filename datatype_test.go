@@ -0,0 +1,85 @@
+package dbdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseDataType(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected DataType
+	}{
+		{"int(11)", DataType{Base: "int", Length: 11}},
+		{"int(10) unsigned zerofill", DataType{Base: "int", Length: 10, Unsigned: true, Zerofill: true}},
+		{"boolean", DataType{Base: "tinyint", Length: 1}},
+		{"decimal(12,2) unsigned", DataType{Base: "decimal", Length: -1, Precision: 12, Scale: 2, Unsigned: true}},
+		{"numeric", DataType{Base: "decimal", Length: -1, Precision: 10}},
+		{"double precision", DataType{Base: "double", Length: -1}},
+		{"char", DataType{Base: "char", Length: 1}},
+		{"VARCHAR(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin", DataType{Base: "varchar", Length: 64, Charset: "utf8mb4", Collation: "utf8mb4_bin"}},
+		{"datetime(6)", DataType{Base: "datetime", Length: -1, Fsp: 6}},
+		{"bit", DataType{Base: "bit", Length: 1}},
+		{"year(4)", DataType{Base: "year", Length: -1}},
+		{"longtext", DataType{Base: "longtext", Length: -1}},
+	}
+	for i, c := range cases {
+		dataType, err := ParseDataType(c.input)
+		if err != nil {
+			t.Errorf("%d. ParseDataType(%s): %s", i+1, c.input, err)
+			continue
+		}
+		verify(t, i+1, "ParseDataType", c.input, fmt.Sprintf("%+v", *dataType), fmt.Sprintf("%+v", c.expected))
+	}
+
+	dataType, _ := ParseDataType(`enum('a','it''s','b,c')`)
+	verify(t, 20, "ParseDataType", "enum members", strings.Join(dataType.Members, "|"), "a|it's|b,c")
+	verify(t, 21, "ParseDataType", "enum render", dataType.String(), `enum('a','it''s','b,c')`)
+
+	for i, input := range []string{"money", "int(x)", "varchar(10", "int frobnicate"} {
+		_, err := ParseDataType(input)
+		_, ok := err.(*TypeParseError)
+		verify(t, i+22, "ParseDataType", input, ok, true)
+	}
+}
+
+func TestCompareDataType(t *testing.T) {
+	cases := []struct {
+		from, to string
+		expected TypeChange
+	}{
+		{"int(11)", "int", TypeSame},
+		{"int", "bigint", TypeWidening},
+		{"int unsigned", "bigint", TypeWidening},
+		{"int unsigned", "int", TypeNarrowing},
+		{"int", "int unsigned", TypeNarrowing},
+		{"bigint", "int", TypeNarrowing},
+		{"decimal(10,2)", "decimal(12,2)", TypeWidening},
+		{"decimal(10,2)", "decimal(12,4)", TypeWidening},
+		{"decimal(12,2)", "decimal(10,2)", TypeNarrowing},
+		{"decimal(10,2)", "decimal(10,3)", TypeNarrowing},
+		{"int", "decimal(10,0)", TypeWidening},
+		{"int", "double", TypeWidening},
+		{"bigint", "double", TypeNarrowing},
+		{"float", "double", TypeWidening},
+		{"varchar(64)", "varchar(255)", TypeWidening},
+		{"varchar(255)", "varchar(64)", TypeNarrowing},
+		{"varchar(255)", "text", TypeWidening},
+		{"mediumtext", "text", TypeNarrowing},
+		{"int", "varchar(11)", TypeWidening},
+		{"varchar(11)", "int", TypeIncompatible},
+		{"date", "datetime", TypeWidening},
+		{"datetime", "timestamp", TypeNarrowing},
+		{"datetime(3)", "datetime", TypeNarrowing},
+		{"datetime", "varchar(19)", TypeWidening},
+		{"enum('a','b')", "enum('a','b','c')", TypeWidening},
+		{"enum('a','b')", "enum('a','c')", TypeNarrowing},
+		{"json", "text", TypeIncompatible},
+	}
+	for i, c := range cases {
+		from, _ := ParseDataType(c.from)
+		to, _ := ParseDataType(c.to)
+		verify(t, i+1, "CompareDataType", c.from+" -> "+c.to, CompareDataType(from, to), c.expected)
+	}
+}
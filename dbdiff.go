@@ -1,41 +1,87 @@
 package dbdiff
 
 import (
-	"fmt"
+	"context"
+	"database/sql"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type DBDiff struct {
+	Filter     *Filter
+	Rules      *RuleSet
+	Normalizer *Normalizer
+	// CreateTableDiff compares the normalized SHOW CREATE TABLE statements of
+	// the tables on both sides, catching what the scheme model does not cover.
+	CreateTableDiff bool
+	// QueryTimeout bounds each introspection query, zero means no limit.
+	QueryTimeout time.Duration
+	// Parallelism is the number of tables introspected at once on each
+	// database, which is also the limit of connections opened to it.
+	Parallelism int
+	// BulkIntrospection loads columns, indexes and foreign keys with one
+	// query per database rather than one per table, for high latency links.
+	BulkIntrospection bool
 }
 
 func NewDBDiff() *DBDiff {
-	return &DBDiff{}
+	return &DBDiff{Normalizer: NewNormalizer(), Parallelism: DefaultParallelism}
 }
 
 func (diff *DBDiff) ParseDiff(connOld, connNew *DBConn) (*DiffDataBase, error) {
-	return diff.parseDiff(connOld, connNew)
+	return diff.parseDiff(context.Background(), connOld, connNew)
 }
 
-func (diff *DBDiff) parseDiff(connOld, connNew *DBConn) (*DiffDataBase, error) {
-	dataBaseOld, err := diff.newDatabase(connOld)
-	if err != nil {
-		return nil, err
-	}
-	dataBaseNew, err := diff.newDatabase(connNew)
+// ParseDiffContext is ParseDiff stopping when ctx is canceled or past its
+// deadline.
+func (diff *DBDiff) ParseDiffContext(ctx context.Context, connOld, connNew *DBConn) (*DiffDataBase, error) {
+	return diff.parseDiff(ctx, connOld, connNew)
+}
+
+// parseDiff introspects both databases at the same time, the failure of one
+// cancels the other.
+func (diff *DBDiff) parseDiff(ctx context.Context, connOld, connNew *DBConn) (*DiffDataBase, error) {
+	var (
+		conns     = []*DBConn{connOld, connNew}
+		sides     = []string{SideOld, SideNew}
+		dataBases = make([]*DataBase, len(conns))
+	)
+	err := forEachParallel(ctx, len(conns), len(conns), func(ctx context.Context, i int) error {
+		dataBase, err := diff.newDatabase(ctx, conns[i])
+		dataBases[i] = dataBase
+		return WithSide(sides[i], err)
+	})
 	if err != nil {
 		return nil, err
 	}
+	dataBaseOld, dataBaseNew := dataBases[0], dataBases[1]
 
 	return diff.parseDatabaseDiff(dataBaseOld, dataBaseNew)
 }
 
-func (diff *DBDiff) newDatabase(conn *DBConn) (*DataBase, error) {
+// ParseDataBase introspects one database with the filter of diff.
+func (diff *DBDiff) ParseDataBase(conn *DBConn) (*DataBase, error) {
+	return diff.newDatabase(context.Background(), conn)
+}
+
+func (diff *DBDiff) ParseDataBaseContext(ctx context.Context, conn *DBConn) (*DataBase, error) {
+	return diff.newDatabase(ctx, conn)
+}
+
+// DiffDataBases compares two databases already introspected or loaded from
+// snapshots.
+func (diff *DBDiff) DiffDataBases(dataBaseOld, dataBaseNew *DataBase) (*DiffDataBase, error) {
+	return diff.parseDatabaseDiff(dataBaseOld, dataBaseNew)
+}
+
+func (diff *DBDiff) newDatabase(ctx context.Context, conn *DBConn) (*DataBase, error) {
 	if conn == nil {
 		return nil, nil
 	}
 
-	db, err := conn.Conn()
+	db, err := conn.ConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -43,8 +89,23 @@ func (diff *DBDiff) newDatabase(conn *DBConn) (*DataBase, error) {
 		db.Close()
 	}()
 
+	scheme := diff.newScheme(conn, db)
+	scheme.ShowCreateTable = diff.CreateTableDiff
+	dataBase, err := scheme.ParseContext(ctx)
+	return dataBase, conn.redact(err)
+}
+
+// newScheme introspects db with the options of diff.
+func (diff *DBDiff) newScheme(conn *DBConn, db *sql.DB) *Scheme {
+	if diff.Parallelism > 0 {
+		db.SetMaxOpenConns(diff.Parallelism)
+	}
 	scheme := NewScheme(conn, db)
-	return scheme.Parse()
+	scheme.Filter = diff.Filter
+	scheme.QueryTimeout = diff.QueryTimeout
+	scheme.Parallelism = diff.Parallelism
+	scheme.Bulk = diff.BulkIntrospection
+	return scheme
 }
 
 func (diff *DBDiff) parseDatabaseDiff(databaseOld, dataBaseNew *DataBase) (*DiffDataBase, error) {
@@ -56,28 +117,47 @@ func (diff *DBDiff) parseDatabaseDiff(databaseOld, dataBaseNew *DataBase) (*Diff
 		return diff.copyDatabaseDiff(databaseOld, true), nil
 	}
 
+	if err := diff.Rules.Compile(); err != nil {
+		return nil, err
+	}
+
 	diffDataBase := &DiffDataBase{}
+	suppressed := []*Suppression{}
 	//diff tables
 	diffTables := []*DiffTable{}
 	tablesComp := KeySlice{
 		keyCompareAction: &compDiffTables{
-			items: &diffTables,
+			items:      &diffTables,
+			rules:      diff.Rules,
+			normalizer: diff.Normalizer,
+			suppressed: &suppressed,
 		},
 		keyComparator: SchemeKeyComparator,
 	}
 	tablesComp.Compare(&databaseOld.Tables, &dataBaseNew.Tables)
 	diffDataBase.DiffTables = diffTables
+	if diff.CreateTableDiff {
+		for _, diffTable := range diffTables {
+			if diffTable.TableOld != nil && diffTable.TableNew != nil {
+				diffTable.CreateTableDiff = diff.Normalizer.createTableDiff(diffTable)
+			}
+		}
+	}
 
 	//diff options
 	diffOptions := []*DiffOption{}
 	optionsComp := KeySlice{
 		keyCompareAction: &diffItems{
-			items: &diffOptions,
+			items:      &diffOptions,
+			rules:      diff.Rules,
+			normalizer: diff.Normalizer,
+			suppressed: &suppressed,
 		},
 		keyComparator: SchemeKeyComparator,
 	}
 	optionsComp.Compare(&databaseOld.Options, &dataBaseNew.Options)
 	diffDataBase.DiffOptions = diffOptions
+	diffDataBase.Suppressed = suppressed
 
 	return diffDataBase, nil
 }
@@ -94,7 +174,18 @@ const (
 )
 
 type diffItems struct {
-	items interface{}
+	items      interface{}
+	tableName  string
+	rules      *RuleSet
+	normalizer *Normalizer
+	suppressed *[]*Suppression
+}
+
+func (this *diffItems) keepChanges(objectType ObjectType, name string, changes []*AttrChange) []*AttrChange {
+	if this.rules == nil {
+		return changes
+	}
+	return this.rules.filterChanges(objectType, this.tableName, name, changes, this.suppressed)
 }
 
 func (this *diffItems) ActionBothExists(itemLeft, itemRight interface{}) {
@@ -104,25 +195,29 @@ func (this *diffItems) ActionBothExists(itemLeft, itemRight interface{}) {
 			left  = itemLeft.(*Column)
 			right = itemRight.(*Column)
 		)
-		if left.ColumnScheme != right.ColumnScheme {
+		changes := this.keepChanges(ObjectColumn, left.ColumnName, columnChanges(left, right, this.normalizer))
+		if len(changes) != 0 {
 			diffColumn := &DiffColumn{
 				ItemOld: left,
 				ItemNew: right,
+				Changes: changes,
+			}
+			if findChange(changes, AttrType) != nil {
+				diffColumn.Members = DiffMembers(right.ColumnType, left.ColumnType)
 			}
 			this.appendItem(diffColumn)
-			fmt.Println()
 		}
 	case *Index:
 		var (
 			left  = itemLeft.(*Index)
 			right = itemRight.(*Index)
 		)
-		srcCols := strings.Join(left.Columns, ",")
-		destCols := strings.Join(right.Columns, ",")
-		if srcCols != destCols {
+		changes := this.keepChanges(ObjectIndex, left.KeyName, indexChanges(left, right))
+		if len(changes) != 0 {
 			diffIndex := &DiffIndex{
 				ItemOld: left,
 				ItemNew: right,
+				Changes: changes,
 			}
 			this.appendItem(diffIndex)
 		}
@@ -131,10 +226,12 @@ func (this *diffItems) ActionBothExists(itemLeft, itemRight interface{}) {
 			left  = itemLeft.(*Variable)
 			right = itemRight.(*Variable)
 		)
-		if left.VariableName != right.VariableName {
+		changes := this.keepChanges(ObjectOption, left.VariableName, variableChanges(left, right))
+		if len(changes) != 0 {
 			diffOption := &DiffOption{
 				ItemOld: left,
 				ItemNew: right,
+				Changes: changes,
 			}
 			this.appendItem(diffOption)
 		}
@@ -155,6 +252,12 @@ func (this *diffItems) ActionRightExists(itemRight interface{}) {
 }
 
 func (this *diffItems) actionSingleExists(item interface{}, isOld bool) {
+	if this.rules != nil {
+		objectType, name := itemIdentity(item)
+		if this.rules.ignoresObject(objectType, this.tableName, name, this.suppressed) {
+			return
+		}
+	}
 	sliceValue := reflect.ValueOf(this.items).Elem()
 	diffItemType := sliceValue.Type().Elem().Elem()
 	diffItemValue := reflect.New(diffItemType)
@@ -171,6 +274,8 @@ func (this *diffItems) actionSingleExists(item interface{}, isOld bool) {
 type DiffDataBase struct {
 	DiffTables  []*DiffTable
 	DiffOptions []*DiffOption
+	// Suppressed lists the differences hidden by the rules of DBDiff.
+	Suppressed []*Suppression
 }
 
 func (diff *DiffDataBase) Copy(database *DataBase, isOld bool) {
@@ -195,12 +300,19 @@ type DiffTable struct {
 	TableName   string
 	TableOld    *Table
 	TableNew    *Table
+	Changes     []*AttrChange
 	DiffColumns []*DiffColumn
 	DiffIndex   []*DiffIndex
+	// CreateTableDiff is the unified diff of the CREATE TABLE statements, set
+	// when DBDiff.CreateTableDiff is enabled and they differ.
+	CreateTableDiff string
 }
 
 type compDiffTables struct {
-	items *[]*DiffTable
+	items      *[]*DiffTable
+	rules      *RuleSet
+	normalizer *Normalizer
+	suppressed *[]*Suppression
 }
 
 func (this *compDiffTables) ActionBothExists(itemLeft, itemRight interface{}) {
@@ -212,11 +324,22 @@ func (this *compDiffTables) ActionBothExists(itemLeft, itemRight interface{}) {
 	diffTable.TableName = left.TableName
 	diffTable.TableOld = left
 	diffTable.TableNew = right
+	diffTable.Changes = tableChanges(left, right, this.normalizer)
+	if this.rules.compares(ObjectTable, left.TableName, left.TableName, AttrAutoIncrement) {
+		diffTable.Changes = appendChange(diffTable.Changes, AttrAutoIncrement, left.AutoIncrement, right.AutoIncrement)
+	}
+	if this.rules != nil {
+		diffTable.Changes = this.rules.filterChanges(ObjectTable, left.TableName, left.TableName, diffTable.Changes, this.suppressed)
+	}
 
 	diffColumns := []*DiffColumn{}
 	columnComp := KeySlice{
 		keyCompareAction: &diffItems{
-			items: &diffColumns,
+			items:      &diffColumns,
+			tableName:  left.TableName,
+			rules:      this.rules,
+			normalizer: this.normalizer,
+			suppressed: this.suppressed,
 		},
 		keyComparator: SchemeKeyComparator,
 	}
@@ -226,7 +349,11 @@ func (this *compDiffTables) ActionBothExists(itemLeft, itemRight interface{}) {
 	diffIndex := []*DiffIndex{}
 	indexComp := KeySlice{
 		keyCompareAction: &diffItems{
-			items: &diffIndex,
+			items:      &diffIndex,
+			tableName:  left.TableName,
+			rules:      this.rules,
+			normalizer: this.normalizer,
+			suppressed: this.suppressed,
 		},
 		keyComparator: SchemeKeyComparator,
 	}
@@ -239,6 +366,9 @@ func (this *compDiffTables) ActionLeftExists(itemLeft interface{}) {
 		table     = itemLeft.(*Table)
 		diffTable = &DiffTable{}
 	)
+	if this.rules.ignoresObject(ObjectTable, table.TableName, table.TableName, this.suppressed) {
+		return
+	}
 	diffTable.Copy(table, true)
 	diffTable.TableName = table.TableName
 	diffTable.TableOld = table
@@ -249,9 +379,12 @@ func (this *compDiffTables) ActionRightExists(itemRight interface{}) {
 		table     = itemRight.(*Table)
 		diffTable = &DiffTable{}
 	)
+	if this.rules.ignoresObject(ObjectTable, table.TableName, table.TableName, this.suppressed) {
+		return
+	}
 	diffTable.Copy(table, false)
 	diffTable.TableName = table.TableName
-	diffTable.TableOld = table
+	diffTable.TableNew = table
 	*this.items = append(*this.items, diffTable)
 }
 
@@ -279,9 +412,24 @@ func (diff *DiffTable) Copy(table *Table, isOld bool) {
 	diff.DiffIndex = indexes
 }
 
+func itemIdentity(item interface{}) (ObjectType, string) {
+	switch v := item.(type) {
+	case *Column:
+		return ObjectColumn, v.ColumnName
+	case *Index:
+		return ObjectIndex, v.KeyName
+	case *Variable:
+		return ObjectOption, v.VariableName
+	}
+	return "", ""
+}
+
 type DiffColumn struct {
 	ItemOld *Column
 	ItemNew *Column
+	Changes []*AttrChange
+	// Members is set when the members of an ENUM or SET column change.
+	Members *MemberChange
 }
 
 func (diff *DiffColumn) Copy(column *Column, isOld bool) {
@@ -291,6 +439,7 @@ func (diff *DiffColumn) Copy(column *Column, isOld bool) {
 type DiffIndex struct {
 	ItemOld *Index
 	ItemNew *Index
+	Changes []*AttrChange
 }
 
 func (diff *DiffIndex) Copy(index *Index, isOld bool) {
@@ -300,6 +449,7 @@ func (diff *DiffIndex) Copy(index *Index, isOld bool) {
 type DiffOption struct {
 	ItemOld *Variable
 	ItemNew *Variable
+	Changes []*AttrChange
 }
 
 func (diff *DiffOption) Copy(option *Variable, isOld bool) {
@@ -314,3 +464,113 @@ func copy(owner, item interface{}, isOld bool) {
 		v.Elem().FieldByName(defaultItemNewName).Set(reflect.ValueOf(item))
 	}
 }
+
+// AttrChange is one attribute that differs between the old and the new item.
+type AttrChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+const (
+	AttrType          = "type"
+	AttrNullable      = "nullable"
+	AttrDefault       = "default"
+	AttrCharset       = "charset"
+	AttrCollation     = "collation"
+	AttrExtra         = "extra"
+	AttrGeneration    = "generation"
+	AttrComment       = "comment"
+	AttrColumns       = "columns"
+	AttrUnique        = "unique"
+	AttrIndexType     = "index_type"
+	AttrEngine        = "engine"
+	AttrRowFormat     = "row_format"
+	AttrAutoIncrement = "auto_increment"
+	AttrCreateOptions = "create_options"
+	AttrValue         = "value"
+)
+
+func (diff *DiffTable) Change(name string) *AttrChange {
+	return findChange(diff.Changes, name)
+}
+
+func (diff *DiffColumn) Change(name string) *AttrChange {
+	return findChange(diff.Changes, name)
+}
+
+func (diff *DiffIndex) Change(name string) *AttrChange {
+	return findChange(diff.Changes, name)
+}
+
+func findChange(changes []*AttrChange, name string) *AttrChange {
+	for _, change := range changes {
+		if change.Name == name {
+			return change
+		}
+	}
+	return nil
+}
+
+func appendChange(changes []*AttrChange, name, old, new string) []*AttrChange {
+	if old == new {
+		return changes
+	}
+	return append(changes, &AttrChange{Name: name, Old: old, New: new})
+}
+
+// appendNormalizedChange compares the normalized values and reports the raw
+// ones.
+func appendNormalizedChange(changes []*AttrChange, name, old, new, normalizedOld, normalizedNew string) []*AttrChange {
+	if normalizedOld == normalizedNew {
+		return changes
+	}
+	return append(changes, &AttrChange{Name: name, Old: old, New: new})
+}
+
+// columnChanges ignores ORDINAL_POSITION, so adding a column does not mark
+// every column after it as changed.
+func columnChanges(left, right *Column, normalizer *Normalizer) []*AttrChange {
+	var (
+		changes = []*AttrChange{}
+		l       = normalizer.NormalizeColumn(left.ColumnScheme)
+		r       = normalizer.NormalizeColumn(right.ColumnScheme)
+	)
+	changes = appendNormalizedChange(changes, AttrType, left.ColumnType, right.ColumnType, l.ColumnType, r.ColumnType)
+	changes = appendChange(changes, AttrNullable, left.NullAble, right.NullAble)
+	if l.ColumnDefault != r.ColumnDefault {
+		changes = append(changes, &AttrChange{Name: AttrDefault, Old: left.ColumnDefault.Text(), New: right.ColumnDefault.Text()})
+	}
+	changes = appendNormalizedChange(changes, AttrCharset, left.CharacterSetName, right.CharacterSetName, l.CharacterSetName, r.CharacterSetName)
+	changes = appendNormalizedChange(changes, AttrCollation, left.CollationName, right.CollationName, l.CollationName, r.CollationName)
+	changes = appendNormalizedChange(changes, AttrExtra, left.Extra, right.Extra, l.Extra, r.Extra)
+	changes = appendChange(changes, AttrGeneration, left.GenerationExpression, right.GenerationExpression)
+	changes = appendChange(changes, AttrComment, left.ColumnComment, right.ColumnComment)
+	return changes
+}
+
+func indexChanges(left, right *Index) []*AttrChange {
+	changes := []*AttrChange{}
+	changes = appendChange(changes, AttrColumns, strings.Join(left.Columns, ","), strings.Join(right.Columns, ","))
+	changes = appendChange(changes, AttrUnique, strconv.FormatBool(left.Unique()), strconv.FormatBool(right.Unique()))
+	changes = appendChange(changes, AttrIndexType, left.IndexType(), right.IndexType())
+	return changes
+}
+
+func tableChanges(left, right *Table, normalizer *Normalizer) []*AttrChange {
+	var (
+		changes = []*AttrChange{}
+		l       = normalizer.NormalizeTable(left.TableScheme)
+		r       = normalizer.NormalizeTable(right.TableScheme)
+	)
+	changes = appendNormalizedChange(changes, AttrEngine, left.Engine, right.Engine, l.Engine, r.Engine)
+	changes = appendNormalizedChange(changes, AttrRowFormat, left.RowFormat, right.RowFormat, l.RowFormat, r.RowFormat)
+	changes = appendChange(changes, AttrCreateOptions, left.CreateOptions, right.CreateOptions)
+	changes = appendNormalizedChange(changes, AttrCollation, left.TableCollation, right.TableCollation, l.TableCollation, r.TableCollation)
+	changes = appendChange(changes, AttrComment, left.TableComment, right.TableComment)
+	return changes
+}
+
+func variableChanges(left, right *Variable) []*AttrChange {
+	return appendChange([]*AttrChange{}, AttrValue, left.Value, right.Value)
+}
@@ -0,0 +1,278 @@
+package dbdiff
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NullString is a string that can be NULL, such as a column default. It
+// scans like sql.NullString and is written to JSON as a string or null.
+type NullString struct {
+	String string
+	Valid  bool
+}
+
+func NewNullString(s string) NullString {
+	return NullString{String: s, Valid: true}
+}
+
+func (ns *NullString) Scan(value interface{}) error {
+	var scanned sql.NullString
+	err := scanned.Scan(value)
+	*ns = NullString(scanned)
+	return err
+}
+
+func (ns NullString) Value() (driver.Value, error) {
+	return sql.NullString(ns).Value()
+}
+
+// Text is the value, or NULL.
+func (ns NullString) Text() string {
+	if !ns.Valid {
+		return "NULL"
+	}
+	return ns.String
+}
+
+func (ns NullString) MarshalJSON() ([]byte, error) {
+	if !ns.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(ns.String)
+}
+
+func (ns *NullString) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*ns = NullString{}
+		return nil
+	}
+	ns.Valid = true
+	return json.Unmarshal(data, &ns.String)
+}
+
+var (
+	timestampDefaultRegexp = regexp.MustCompile(`(?i)^(current_timestamp|now|localtimestamp|localtime)(\(\s*\d*\s*\))?$`)
+	bitLiteralRegexp       = regexp.MustCompile(`^b'[01]*'$`)
+)
+
+// ColumnDefinition renders the definition of a column as SHOW CREATE TABLE
+// does: name, type, charset and collation, nullability, default, extra and
+// comment, in the order MySQL expects them. A generated column has its
+// expression instead of a default and its nullability only when NOT NULL.
+func ColumnDefinition(scheme ColumnScheme) string {
+	var buff bytes.Buffer
+	buff.WriteString(quoteIdent(scheme.ColumnName))
+	buff.WriteString(" ")
+	buff.WriteString(scheme.ColumnType)
+	if scheme.CollationName != "" {
+		if scheme.CharacterSetName != "" {
+			buff.WriteString(" CHARACTER SET ")
+			buff.WriteString(scheme.CharacterSetName)
+		}
+		buff.WriteString(" COLLATE ")
+		buff.WriteString(scheme.CollationName)
+	}
+	generation := columnGeneration(scheme.Extra)
+	if generation != "" {
+		buff.WriteString(" GENERATED ALWAYS AS (")
+		buff.WriteString(generationExpressionSql(scheme.GenerationExpression))
+		buff.WriteString(") ")
+		buff.WriteString(generation)
+	}
+	if "NO" == scheme.NullAble {
+		buff.WriteString(" NOT NULL")
+	} else if generation == "" {
+		buff.WriteString(" NULL")
+	}
+	//a NULL default is implied by NULL and impossible with NOT NULL
+	if scheme.ColumnDefault.Valid && generation == "" {
+		buff.WriteString(" DEFAULT ")
+		buff.WriteString(defaultValueSql(scheme))
+	}
+	if extra := extraSql(scheme.Extra); extra != "" {
+		buff.WriteString(" ")
+		buff.WriteString(extra)
+	}
+	if scheme.ColumnComment != "" {
+		buff.WriteString(" COMMENT ")
+		buff.WriteString(quoteText(scheme.ColumnComment))
+	}
+	return buff.String()
+}
+
+// defaultValueSql is the default of a column as an expression. MySQL 8.0
+// marks expression defaults DEFAULT_GENERATED, they are parenthesized unless
+// they are the current timestamp. Other defaults are literals, the quotes of
+// MariaDB are removed when reading the columns.
+func defaultValueSql(scheme ColumnScheme) string {
+	value := scheme.ColumnDefault.String
+	switch {
+	case timestampDefaultRegexp.MatchString(value):
+		return value
+	case strings.Contains(strings.ToUpper(scheme.Extra), "DEFAULT_GENERATED"):
+		if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+			return value
+		}
+		return "(" + value + ")"
+	case bitLiteralRegexp.MatchString(value):
+		return value
+	}
+	return quoteLiteral(value)
+}
+
+// columnGeneration is VIRTUAL or STORED for a generated column, as its EXTRA
+// reads "VIRTUAL GENERATED" or "STORED GENERATED", empty otherwise.
+func columnGeneration(extra string) string {
+	words := strings.Fields(strings.ToUpper(extra))
+	for i := 1; i < len(words); i++ {
+		if words[i] == "GENERATED" && (words[i-1] == "VIRTUAL" || words[i-1] == "STORED") {
+			return words[i-1]
+		}
+	}
+	return ""
+}
+
+// generationExpressionSql is the GENERATION_EXPRESSION of information_schema
+// as SHOW CREATE TABLE writes it: MySQL 8.0 escapes the quotes of the string
+// literals with backslashes there.
+func generationExpressionSql(expression string) string {
+	return strings.Replace(expression, `\'`, "'", -1)
+}
+
+// extraSql turns the EXTRA column of information_schema into column options:
+// DEFAULT_GENERATED and the generation, rendered on its own, are dropped,
+// auto_increment and on update are upper cased.
+func extraSql(extra string) string {
+	extra = strings.TrimSpace(spacesRegexp.ReplaceAllString(extra, " "))
+	if extra == "" {
+		return ""
+	}
+	words := []string{}
+	split := strings.Split(extra, " ")
+	for i, word := range split {
+		switch upper := strings.ToUpper(word); {
+		case upper == "DEFAULT_GENERATED":
+		case (upper == "VIRTUAL" || upper == "STORED") && i+1 < len(split) && strings.ToUpper(split[i+1]) == "GENERATED":
+		case upper == "GENERATED" && i > 0 && columnGeneration(split[i-1]+" "+word) != "":
+		case upper == "AUTO_INCREMENT" || upper == "ON" || upper == "UPDATE":
+			words = append(words, upper)
+		case timestampDefaultRegexp.MatchString(word):
+			words = append(words, upper)
+		default:
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// renderCreateTable renders the CREATE TABLE statement of a table from its
+// information_schema rows, for the bulk introspection which cannot afford a
+// SHOW CREATE TABLE per table. ok is false for the tables information_schema
+// does not describe fully: partitioned tables, generated columns without
+// their expression and functional indexes, which need SHOW CREATE TABLE.
+func renderCreateTable(tableScheme TableScheme, columns []ColumnScheme, indexes []IndexScheme, foreignKeys []ForeignKeyScheme) (string, bool) {
+	if len(columns) == 0 || strings.Contains(strings.ToLower(tableScheme.CreateOptions), "partitioned") {
+		return "", false
+	}
+	definitions := []string{}
+	for _, column := range columns {
+		if columnGeneration(column.Extra) != "" && column.GenerationExpression == "" {
+			return "", false
+		}
+		definitions = append(definitions, ColumnDefinition(column))
+	}
+
+	for i := 0; i < len(indexes); {
+		keyName := indexes[i].KeyName
+		parts := []string{}
+		for j := i; j < len(indexes) && indexes[j].KeyName == keyName; j++ {
+			if indexes[j].ColumnName == "" {
+				return "", false
+			}
+			part := quoteIdent(indexes[j].ColumnName)
+			if indexes[j].SubPart != "" {
+				part += "(" + indexes[j].SubPart + ")"
+			}
+			if indexes[j].Collation == "D" {
+				part += " DESC"
+			}
+			parts = append(parts, part)
+		}
+		definitions = append(definitions, indexDefinition(indexes[i], strings.Join(parts, ",")))
+		i += len(parts)
+	}
+
+	for i := 0; i < len(foreignKeys); {
+		foreignKey := foreignKeys[i]
+		columns, referenced := []string{}, []string{}
+		for j := i; j < len(foreignKeys) && foreignKeys[j].ConstraintName == foreignKey.ConstraintName; j++ {
+			columns = append(columns, quoteIdent(foreignKeys[j].ColumnName))
+			referenced = append(referenced, quoteIdent(foreignKeys[j].ReferencedColumnName))
+		}
+		definition := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)", quoteIdent(foreignKey.ConstraintName),
+			strings.Join(columns, ","), quoteIdent(foreignKey.ReferencedTableName), strings.Join(referenced, ","))
+		definition += referentialAction("DELETE", foreignKey.DeleteRule) + referentialAction("UPDATE", foreignKey.UpdateRule)
+		definitions = append(definitions, definition)
+		i += len(columns)
+	}
+
+	var buff bytes.Buffer
+	buff.WriteString("CREATE TABLE ")
+	buff.WriteString(quoteIdent(tableScheme.TableName))
+	buff.WriteString(" (\n  ")
+	buff.WriteString(strings.Join(definitions, ",\n  "))
+	buff.WriteString("\n)")
+	if tableScheme.Engine != "" {
+		buff.WriteString(" ENGINE=" + tableScheme.Engine)
+	}
+	if collation := tableScheme.TableCollation; collation != "" {
+		buff.WriteString(" DEFAULT CHARSET=" + collationCharset(collation) + " COLLATE=" + collation)
+	}
+	//only the options given explicitly, SHOW CREATE TABLE omits the others
+	for _, option := range strings.Fields(tableScheme.CreateOptions) {
+		if i := strings.Index(option, "="); i > 0 {
+			buff.WriteString(" " + strings.ToUpper(option[:i]) + option[i:])
+		}
+	}
+	if tableScheme.TableComment != "" {
+		buff.WriteString(" COMMENT=" + quoteText(tableScheme.TableComment))
+	}
+	return buff.String(), true
+}
+
+func indexDefinition(index IndexScheme, parts string) string {
+	var definition string
+	switch indexType := strings.ToUpper(index.IndexType); {
+	case strings.ToUpper(index.KeyName) == "PRIMARY":
+		definition = "PRIMARY KEY (" + parts + ")"
+	case indexType == "FULLTEXT" || indexType == "SPATIAL":
+		definition = indexType + " KEY " + quoteIdent(index.KeyName) + " (" + parts + ")"
+	case index.NonUnique == 0:
+		definition = "UNIQUE KEY " + quoteIdent(index.KeyName) + " (" + parts + ")"
+	default:
+		definition = "KEY " + quoteIdent(index.KeyName) + " (" + parts + ")"
+	}
+	if strings.ToUpper(index.IndexType) == "HASH" {
+		definition += " USING HASH"
+	}
+	if index.IndexComment != "" {
+		definition += " COMMENT " + quoteText(index.IndexComment)
+	}
+	return definition
+}
+
+// referentialAction is the ON DELETE or ON UPDATE clause of a rule, omitted
+// for RESTRICT and NO ACTION which are the default of InnoDB.
+func referentialAction(event, rule string) string {
+	switch strings.ToUpper(rule) {
+	case "", "RESTRICT", "NO ACTION":
+		return ""
+	}
+	return " ON " + event + " " + strings.ToUpper(rule)
+}
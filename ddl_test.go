@@ -0,0 +1,244 @@
+package dbdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+// ddlGoldenCases are columns as information_schema reports them, with the
+// definition rendered for them.
+var ddlGoldenCases = []struct {
+	name   string
+	scheme ColumnScheme
+	sql    string
+	// mysql8 needs expression defaults, live needs the column to be a key,
+	// mariadb is the scheme as MariaDB 10.2.7 and later report it
+	mysql8, live, mariadb bool
+}{
+	{
+		name:   "null default",
+		scheme: ColumnScheme{ColumnName: "name", ColumnType: "varchar(64)", NullAble: "YES", CharacterSetName: "utf8mb4", CollationName: "utf8mb4_general_ci"},
+		sql:    "`name` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL",
+		live:   true,
+	},
+	{
+		name:   "empty default",
+		scheme: ColumnScheme{ColumnName: "nickname", ColumnType: "varchar(32)", NullAble: "NO", ColumnDefault: NewNullString(""), CharacterSetName: "utf8mb4", CollationName: "utf8mb4_bin"},
+		sql:    "`nickname` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT ''",
+		live:   true,
+	},
+	{
+		name:   "numeric default",
+		scheme: ColumnScheme{ColumnName: "age", ColumnType: "int unsigned", NullAble: "NO", ColumnDefault: NewNullString("0")},
+		sql:    "`age` int unsigned NOT NULL DEFAULT '0'",
+		live:   true,
+	},
+	{
+		name: "escaped literals",
+		scheme: ColumnScheme{ColumnName: "path", ColumnType: "varchar(64)", NullAble: "YES", ColumnDefault: NewNullString(`C:\tmp\it's`),
+			CharacterSetName: "utf8mb4", CollationName: "utf8mb4_general_ci", ColumnComment: `the "home" dir`},
+		sql:  "`path` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT _utf8mb4 X'433A5C746D705C69742773' COMMENT 'the \"home\" dir'",
+		live: true,
+	},
+	{
+		name: "current timestamp",
+		scheme: ColumnScheme{ColumnName: "updated_at", ColumnType: "timestamp(3)", NullAble: "NO", ColumnDefault: NewNullString("CURRENT_TIMESTAMP(3)"),
+			Extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP(3)"},
+		sql:  "`updated_at` timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3)",
+		live: true,
+	},
+	{
+		name:   "expression default",
+		scheme: ColumnScheme{ColumnName: "uid", ColumnType: "char(36)", NullAble: "YES", ColumnDefault: NewNullString("uuid()"), Extra: "DEFAULT_GENERATED", CharacterSetName: "ascii", CollationName: "ascii_bin"},
+		sql:    "`uid` char(36) CHARACTER SET ascii COLLATE ascii_bin NULL DEFAULT (uuid())",
+		mysql8: true,
+		live:   true,
+	},
+	{
+		name:   "bit default",
+		scheme: ColumnScheme{ColumnName: "flags", ColumnType: "bit(3)", NullAble: "NO", ColumnDefault: NewNullString("b'101'")},
+		sql:    "`flags` bit(3) NOT NULL DEFAULT b'101'",
+		live:   true,
+	},
+	{
+		name:   "quoted literal",
+		scheme: ColumnScheme{ColumnName: "quoted", ColumnType: "varchar(8)", NullAble: "NO", ColumnDefault: NewNullString("'x'"), CharacterSetName: "utf8mb4", CollationName: "utf8mb4_bin"},
+		sql:    "`quoted` varchar(8) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '''x'''",
+		live:   true,
+	},
+	{
+		name:   "null literal",
+		scheme: ColumnScheme{ColumnName: "word", ColumnType: "varchar(8)", NullAble: "NO", ColumnDefault: NewNullString("NULL"), CharacterSetName: "utf8mb4", CollationName: "utf8mb4_bin"},
+		sql:    "`word` varchar(8) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT 'NULL'",
+		live:   true,
+	},
+	{
+		name:    "mariadb literal",
+		scheme:  ColumnScheme{ColumnName: "state", ColumnType: "varchar(8)", NullAble: "NO", ColumnDefault: NewNullString("'it''s'")},
+		sql:     "`state` varchar(8) NOT NULL DEFAULT 'it''s'",
+		mariadb: true,
+	},
+	{
+		name:    "mariadb quoted literal",
+		scheme:  ColumnScheme{ColumnName: "quoted", ColumnType: "varchar(8)", NullAble: "NO", ColumnDefault: NewNullString("'''x'''")},
+		sql:     "`quoted` varchar(8) NOT NULL DEFAULT '''x'''",
+		mariadb: true,
+	},
+	{
+		name:    "mariadb null default",
+		scheme:  ColumnScheme{ColumnName: "note", ColumnType: "varchar(8)", NullAble: "YES", ColumnDefault: NewNullString("NULL")},
+		sql:     "`note` varchar(8) NULL",
+		mariadb: true,
+	},
+	{
+		name:    "mariadb expression default",
+		scheme:  ColumnScheme{ColumnName: "uid", ColumnType: "char(36)", NullAble: "YES", ColumnDefault: NewNullString("uuid()")},
+		sql:     "`uid` char(36) NULL DEFAULT (uuid())",
+		mariadb: true,
+	},
+	{
+		name:    "mariadb numeric default",
+		scheme:  ColumnScheme{ColumnName: "price", ColumnType: "decimal(8,2)", NullAble: "NO", ColumnDefault: NewNullString("0.00")},
+		sql:     "`price` decimal(8,2) NOT NULL DEFAULT '0.00'",
+		mariadb: true,
+	},
+	{
+		name: "virtual generated",
+		scheme: ColumnScheme{ColumnName: "total", ColumnType: "int", NullAble: "YES", ColumnDefault: NewNullString(""), Extra: "VIRTUAL GENERATED",
+			GenerationExpression: "(`price` * `quantity`)"},
+		sql: "`total` int GENERATED ALWAYS AS ((`price` * `quantity`)) VIRTUAL",
+	},
+	{
+		name: "stored generated",
+		scheme: ColumnScheme{ColumnName: "label", ColumnType: "varchar(64)", NullAble: "NO", Extra: "STORED GENERATED", CharacterSetName: "utf8mb4",
+			CollationName: "utf8mb4_bin", GenerationExpression: "concat(_utf8mb4\\'#\\',`id`)", ColumnComment: "tag"},
+		sql: "`label` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin GENERATED ALWAYS AS (concat(_utf8mb4'#',`id`)) STORED NOT NULL COMMENT 'tag'",
+	},
+	{
+		name:   "auto increment",
+		scheme: ColumnScheme{ColumnName: "id", ColumnType: "bigint unsigned", NullAble: "NO", Extra: "auto_increment"},
+		sql:    "`id` bigint unsigned NOT NULL AUTO_INCREMENT",
+	},
+}
+
+func TestColumnDefinition(t *testing.T) {
+	for i, c := range ddlGoldenCases {
+		scheme := c.scheme
+		if c.mariadb {
+			scheme = mariaDBColumn(scheme)
+		}
+		verify(t, i+1, "ColumnDefinition", c.name, ColumnDefinition(scheme), c.sql)
+	}
+
+	column := &Column{ColumnScheme: ddlGoldenCases[1].scheme}
+	column.TableName = "user-events"
+	column.fillAddColumnSql()
+	column.fillModifyColumnSql()
+	verify(t, 30, "fillAddColumnSql", "statement", column.AddColumnSql, "ALTER TABLE `user-events` ADD COLUMN "+ddlGoldenCases[1].sql)
+	verify(t, 31, "fillModifyColumnSql", "statement", column.ModifyColumnSql, "ALTER TABLE `user-events` MODIFY COLUMN "+ddlGoldenCases[1].sql)
+}
+
+// TestColumnDefinition_RoundTrip adds every golden column to a table of the
+// test database and checks that information_schema reports it back as it was.
+func TestColumnDefinition_RoundTrip(t *testing.T) {
+	db := getDB()
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Skip("no test database: ", err)
+	}
+	tpl := NewDBTemplate(db)
+	version := &struct {
+		Version string `col:"VERSION()"`
+	}{}
+	if err := tpl.QuerySingle("SELECT VERSION()", version); err != nil {
+		t.Fatal(err)
+	}
+	mysql8 := !strings.Contains(version.Version, "MariaDB") && !strings.HasPrefix(version.Version, "5.")
+	scheme := &Scheme{Version: version.Version}
+
+	if _, err := tpl.Exec("DROP TABLE IF EXISTS `dbdiff_ddl`"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpl.Exec("CREATE TABLE `dbdiff_ddl` (`pk` int NOT NULL PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	defer tpl.Exec("DROP TABLE IF EXISTS `dbdiff_ddl`")
+
+	normalizer := NewNormalizer()
+	for i, c := range ddlGoldenCases {
+		if !c.live || c.mysql8 && !mysql8 {
+			continue
+		}
+		column := &Column{ColumnScheme: c.scheme}
+		column.TableName = "dbdiff_ddl"
+		column.fillAddColumnSql()
+		if _, err := tpl.Exec(column.AddColumnSql); err != nil {
+			t.Errorf("%d. %s: %v", i+1, c.name, err)
+			continue
+		}
+		sql, args := (&SchemeSql{}).ColumnSchemeSql(getDBConn().DBName, "dbdiff_ddl")
+		columns := []ColumnScheme{}
+		if err := tpl.QueryList(sql, &columns, args...); err != nil {
+			t.Fatal(err)
+		}
+		scheme.readColumns(columns)
+		for _, read := range columns {
+			if read.ColumnName != c.scheme.ColumnName {
+				continue
+			}
+			expected := normalizer.NormalizeColumn(c.scheme)
+			read = normalizer.NormalizeColumn(read)
+			expected.TableName, expected.OrdinalPosition, expected.ColumnKey, expected.CharacterMaximumLength = read.TableName, read.OrdinalPosition, read.ColumnKey, read.CharacterMaximumLength
+			if expected.CollationName == "" {
+				expected.CharacterSetName, expected.CollationName = read.CharacterSetName, read.CollationName
+			}
+			verify(t, i+1, "round trip", column.AddColumnSql, read, expected)
+		}
+		tpl.Exec("ALTER TABLE `dbdiff_ddl` DROP COLUMN " + quoteIdent(c.scheme.ColumnName))
+	}
+}
+
+func TestRenderCreateTable(t *testing.T) {
+	var (
+		tableScheme = TableScheme{TableName: "enrollment", Engine: "InnoDB", TableCollation: "utf8mb4_general_ci",
+			CreateOptions: "row_format=DYNAMIC", TableComment: "who's in"}
+		columns = []ColumnScheme{
+			{ColumnName: "id", ColumnType: "bigint unsigned", NullAble: "NO", Extra: "auto_increment"},
+			{ColumnName: "student_id", ColumnType: "int", NullAble: "NO"},
+			{ColumnName: "note", ColumnType: "varchar(255)", NullAble: "YES", CharacterSetName: "utf8mb4", CollationName: "utf8mb4_general_ci"},
+		}
+		indexes = []IndexScheme{
+			{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id", IndexType: "BTREE"},
+			{KeyName: "idx_note", NonUnique: 1, SeqInIndex: 1, ColumnName: "note", SubPart: "16", IndexType: "BTREE", IndexComment: "prefix"},
+			{KeyName: "uk_student", SeqInIndex: 1, ColumnName: "student_id", IndexType: "BTREE"},
+			{KeyName: "uk_student", SeqInIndex: 2, ColumnName: "id", Collation: "D", IndexType: "BTREE"},
+		}
+		foreignKeys = []ForeignKeyScheme{
+			{ConstraintName: "fk_student", ColumnName: "student_id", ReferencedTableName: "student", ReferencedColumnName: "id",
+				UpdateRule: "CASCADE", DeleteRule: "RESTRICT"},
+		}
+	)
+	sql, ok := renderCreateTable(tableScheme, columns, indexes, foreignKeys)
+	verify(t, 1, "renderCreateTable", "ok", ok, true)
+	verify(t, 2, "renderCreateTable", "sql", sql, "CREATE TABLE `enrollment` (\n"+
+		"  `id` bigint unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `student_id` int NOT NULL,\n"+
+		"  `note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_note` (`note`(16)) COMMENT 'prefix',\n"+
+		"  UNIQUE KEY `uk_student` (`student_id`,`id` DESC),\n"+
+		"  CONSTRAINT `fk_student` FOREIGN KEY (`student_id`) REFERENCES `student` (`id`) ON UPDATE CASCADE\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci ROW_FORMAT=DYNAMIC COMMENT='who''s in'")
+
+	generated := append([]ColumnScheme{{ColumnName: "total", ColumnType: "int", Extra: "VIRTUAL GENERATED"}}, columns...)
+	_, ok = renderCreateTable(tableScheme, generated, indexes, foreignKeys)
+	verify(t, 3, "renderCreateTable", "generated column", ok, false)
+	generated[0].GenerationExpression = "(`id` + 1)"
+	sql, ok = renderCreateTable(tableScheme, generated, indexes, foreignKeys)
+	verify(t, 3, "renderCreateTable", "generation expression", ok && strings.Contains(sql, "`total` int GENERATED ALWAYS AS ((`id` + 1)) VIRTUAL,\n"), true)
+	tableScheme.CreateOptions = "partitioned"
+	_, ok = renderCreateTable(tableScheme, columns, indexes, foreignKeys)
+	verify(t, 4, "renderCreateTable", "partitioned", ok, false)
+	_, ok = renderCreateTable(TableScheme{TableName: "expr"}, columns, []IndexScheme{{KeyName: "idx_expr", NonUnique: 1}}, nil)
+	verify(t, 5, "renderCreateTable", "functional index", ok, false)
+}
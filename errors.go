@@ -1,6 +1,36 @@
 package dbdiff
 
-import "fmt"
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// The kinds of failure, errors.Is tells them apart in the errors of dbdiff:
+//
+//	if errors.Is(err, dbdiff.ErrPermissionDenied) {
+//		//skip the table
+//	}
+var (
+	ErrConnection       = errors.New("connection failed")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrUnsupported      = errors.New("unsupported feature")
+	ErrNotFound         = errors.New("object not found")
+	ErrParse            = errors.New("parse error")
+	ErrQueryTimeout     = errors.New("query timeout")
+)
+
+// The sides of a diff, as set on errors by WithSide.
+const (
+	SideOld = "old"
+	SideNew = "new"
+)
 
 type DBNotSupportError struct {
 	DriverName string
@@ -10,11 +40,302 @@ func (err *DBNotSupportError) Error() string {
 	return fmt.Sprintf("%s not support", err.DriverName)
 }
 
-type DataAccessError struct {
+func (err *DBNotSupportError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// DBConnError is a connection that could not be set up, Side is the side of
+// the diff it was for, if any.
+type DBConnError struct {
 	Message string
+	Side    string
 	Err     error
 }
 
+func (err *DBConnError) Error() string {
+	message := "connection error:" + err.Message + sideText(err.Side)
+	if err.Err != nil {
+		return message + " with " + err.Err.Error()
+	}
+	return message
+}
+
+func (err *DBConnError) Is(target error) bool {
+	return target == ErrConnection
+}
+
+func (err *DBConnError) Unwrap() error {
+	return err.Err
+}
+
+// DataAccessError is a failed query. Kind is the ErrXxx it matches with
+// errors.Is, nil when the failure was not recognized.
+type DataAccessError struct {
+	Message   string
+	Sql       string
+	TableName string
+	Side      string
+	Kind      error
+	Err       error
+}
+
 func (dae *DataAccessError) Error() string {
-	return fmt.Sprintf("access data error:%s with %s", dae.Message, dae.Err.Error())
+	message := "access data error:" + dae.Message
+	if dae.TableName != "" {
+		message += " on table " + dae.TableName
+	}
+	message += sideText(dae.Side)
+	if dae.Err != nil {
+		return message + " with " + dae.Err.Error()
+	}
+	return message
+}
+
+func (dae *DataAccessError) Is(target error) bool {
+	return dae.Kind != nil && target == dae.Kind
+}
+
+func (dae *DataAccessError) Unwrap() error {
+	return dae.Err
+}
+
+func sideText(side string) string {
+	if side == "" {
+		return ""
+	}
+	return " in the " + side + " database"
+}
+
+type DataSyncError struct {
+	TableName string
+	Message   string
+}
+
+func (err *DataSyncError) Error() string {
+	return fmt.Sprintf("data sync error:%s on table %s", err.Message, err.TableName)
+}
+
+// RollbackError is a transaction that failed with Err and could not be
+// rolled back. Both errors are seen by errors.Is and errors.As.
+type RollbackError struct {
+	Err         error
+	RollbackErr error
+}
+
+func (err *RollbackError) Error() string {
+	message := "transaction error"
+	if err.Err != nil {
+		message = err.Err.Error()
+	}
+	if err.RollbackErr != nil {
+		return message + ", rollback error:" + err.RollbackErr.Error()
+	}
+	return message + ", rollback error"
+}
+
+func (err *RollbackError) Unwrap() []error {
+	errs := []error{}
+	for _, wrapped := range []error{err.Err, err.RollbackErr} {
+		if wrapped != nil {
+			errs = append(errs, wrapped)
+		}
+	}
+	return errs
+}
+
+type UnsafeChangeError struct {
+	Changes []*Change
+}
+
+func (err *UnsafeChangeError) Error() string {
+	refused := make([]string, len(err.Changes))
+	for i, change := range err.Changes {
+		refused[i] = change.String()
+	}
+	return fmt.Sprintf("refused %d destructive changes: %s", len(err.Changes), strings.Join(refused, "; "))
+}
+
+type FilterError struct {
+	Pattern string
+	Err     error
+}
+
+func (err *FilterError) Error() string {
+	message := "invalid filter pattern " + err.Pattern
+	if err.Err != nil {
+		return message + ": " + err.Err.Error()
+	}
+	return message
+}
+
+func (err *FilterError) Is(target error) bool {
+	return target == ErrParse
+}
+
+func (err *FilterError) Unwrap() error {
+	return err.Err
+}
+
+type ConfigError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (err *ConfigError) Error() string {
+	if err.Line != 0 {
+		return fmt.Sprintf("config error:%s at %s line %d", err.Message, err.Path, err.Line)
+	}
+	return fmt.Sprintf("config error:%s in %s", err.Message, err.Path)
+}
+
+type TypeParseError struct {
+	ColumnType string
+	Message    string
+}
+
+func (err *TypeParseError) Error() string {
+	return fmt.Sprintf("parse column type %s error:%s", err.ColumnType, err.Message)
+}
+
+func (err *TypeParseError) Is(target error) bool {
+	return target == ErrParse
+}
+
+type SnapshotError struct {
+	Message string
+	Err     error
+}
+
+func (err *SnapshotError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("snapshot error:%s with %s", err.Message, err.Err.Error())
+	}
+	return fmt.Sprintf("snapshot error:%s", err.Message)
+}
+
+func (err *SnapshotError) Unwrap() error {
+	return err.Err
+}
+
+type ReportError struct {
+	Format  string
+	Message string
+}
+
+func (err *ReportError) Error() string {
+	return fmt.Sprintf("report %s error:%s", err.Format, err.Message)
+}
+
+// CanceledError is a query stopped by the cancellation or the deadline of its
+// context, Err is context.Canceled or context.DeadlineExceeded. A deadline
+// matches ErrQueryTimeout.
+type CanceledError struct {
+	TableName string
+	Sql       string
+	Side      string
+	Err       error
+}
+
+func (err *CanceledError) Error() string {
+	message := fmt.Sprintf("query %s%s canceled", err.Sql, sideText(err.Side))
+	if err.TableName != "" {
+		message = fmt.Sprintf("introspecting table %s%s canceled", err.TableName, sideText(err.Side))
+	}
+	if err.Err != nil {
+		return message + ":" + err.Err.Error()
+	}
+	return message
+}
+
+func (err *CanceledError) Is(target error) bool {
+	return target == ErrQueryTimeout && err.Err == context.DeadlineExceeded
+}
+
+func (err *CanceledError) Unwrap() error {
+	return err.Err
+}
+
+// RowError is a row of a streamed query that could not be mapped, Row counts
+// from 0.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (err *RowError) Error() string {
+	message := fmt.Sprintf("row %d error", err.Row)
+	if err.Err != nil {
+		return message + ":" + err.Err.Error()
+	}
+	return message
+}
+
+func (err *RowError) Unwrap() error {
+	return err.Err
+}
+
+// VetoError is a statement that a StatementHook refused to run.
+type VetoError struct {
+	Sql string
+	Err error
+}
+
+func (err *VetoError) Error() string {
+	message := "statement " + err.Sql + " vetoed"
+	if err.Err != nil {
+		return message + ":" + err.Err.Error()
+	}
+	return message
+}
+
+func (err *VetoError) Unwrap() error {
+	return err.Err
+}
+
+// WithSide records on err, and on the errors it wraps, the side of the diff
+// they happened on, and returns it.
+func WithSide(side string, err error) error {
+	for wrapped := err; wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		switch typed := wrapped.(type) {
+		case *DBConnError:
+			typed.Side = side
+		case *DataAccessError:
+			typed.Side = side
+		case *CanceledError:
+			typed.Side = side
+		}
+	}
+	return err
+}
+
+// errorKind recognizes the failure of a query from the error of the driver:
+// the server error numbers, the broken connections and the deadlines.
+func errorKind(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1044, 1045, 1142, 1143, 1227, 1370:
+			return ErrPermissionDenied
+		case 1049, 1051, 1054, 1091, 1109, 1146, 1305:
+			return ErrNotFound
+		case 1064, 1149:
+			return ErrParse
+		case 1193, 1235, 1286, 1289:
+			return ErrUnsupported
+		case 1205, 1317, 3024:
+			return ErrQueryTimeout
+		case 1040, 1053, 1129, 1130:
+			return ErrConnection
+		}
+		return nil
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrQueryTimeout
+	case errors.As(err, &netErr), errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrConnection
+	}
+	return nil
 }
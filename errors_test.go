@@ -0,0 +1,108 @@
+package dbdiff
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestErrorKind(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind error
+	}{
+		{&mysql.MySQLError{Number: 1142, Message: "SELECT command denied"}, ErrPermissionDenied},
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied"}, ErrPermissionDenied},
+		{&mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}, ErrNotFound},
+		{&mysql.MySQLError{Number: 1064, Message: "syntax error"}, ErrParse},
+		{&mysql.MySQLError{Number: 1193, Message: "Unknown system variable"}, ErrUnsupported},
+		{&mysql.MySQLError{Number: 3024, Message: "maximum statement execution time exceeded"}, ErrQueryTimeout},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, nil},
+		{driver.ErrBadConn, ErrConnection},
+		{mysql.ErrInvalidConn, ErrConnection},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrConnection},
+		{errors.New("exec failed"), nil},
+	}
+	for i, c := range cases {
+		verify(t, i+1, "errorKind", c.err.Error(), errorKind(c.err), c.kind)
+	}
+}
+
+func TestDataAccessError(t *testing.T) {
+	tpl := rowsTemplate(t, []string{"id", "name"})
+	err := tpl.QuerySingle("select", Student{})
+	verify(t, 1, "QuerySingle", "no cause", err.Error(), "access data error:out param must be a ptr of struct")
+
+	denied := &mysql.MySQLError{Number: 1142, Message: "SELECT command denied"}
+	err = queryError(context.Background(), "SHOW CREATE TABLE `student`", &DataAccessError{Message: "Db query error", Err: denied})
+	err = WithSide(SideOld, tableError("student", err))
+	verify(t, 2, "DataAccessError", "message", err.Error(), "access data error:Db query error on table student in the old database with Error 1142: SELECT command denied")
+	verify(t, 3, "DataAccessError", "is", errors.Is(err, ErrPermissionDenied), true)
+	verify(t, 4, "DataAccessError", "is not", errors.Is(err, ErrNotFound), false)
+	var mysqlErr *mysql.MySQLError
+	verify(t, 5, "DataAccessError", "as", errors.As(err, &mysqlErr) && mysqlErr.Number == 1142, true)
+	verify(t, 6, "DataAccessError", "sql", err.(*DataAccessError).Sql, "SHOW CREATE TABLE `student`")
+
+	//the kind is found through the errors that wrap it
+	batch := &DataAccessError{Message: "batch statement 1 of 1 error", Err: err}
+	verify(t, 7, "DataAccessError", "wrapped", errors.Is(&RowError{Err: batch}, ErrPermissionDenied), true)
+}
+
+func TestErrorTaxonomy(t *testing.T) {
+	canceled := WithSide(SideNew, &CanceledError{Sql: "SELECT 1", Err: context.DeadlineExceeded})
+	verify(t, 1, "CanceledError", "message", canceled.Error(), "query SELECT 1 in the new database canceled:context deadline exceeded")
+	verify(t, 2, "CanceledError", "timeout", errors.Is(canceled, ErrQueryTimeout), true)
+	verify(t, 3, "CanceledError", "deadline", errors.Is(canceled, context.DeadlineExceeded), true)
+	verify(t, 4, "CanceledError", "canceled", errors.Is(&CanceledError{Err: context.Canceled}, ErrQueryTimeout), false)
+
+	dbConn := &DBConn{Username: "app", Password: "secret"}
+	redacted := dbConn.redact(&DBConnError{Message: "login app:secret refused", Err: driver.ErrBadConn})
+	verify(t, 5, "DBConnError", "redacted", errors.Is(redacted, ErrConnection), true)
+	verify(t, 6, "DBConnError", "cause", errors.Is(redacted, driver.ErrBadConn), true)
+
+	//no error of the chain gives the password back
+	leaked := dbConn.redact(&DataAccessError{Message: "Db query error", Err: &DBConnError{Message: "dsn",
+		Err: fmt.Errorf("open app:secret@db: %w", driver.ErrBadConn)}})
+	for wrapped := leaked; wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		verify(t, 11, "redact", wrapped.Error(), strings.Contains(wrapped.Error(), "secret"), false)
+	}
+	var connErr *DBConnError
+	verify(t, 12, "redact", "as", errors.As(leaked, &connErr) && connErr.Err.Error() == "open app:xxxxx@db: driver: bad connection", true)
+	verify(t, 13, "redact", "kind", errors.Is(leaked, ErrConnection) && errors.Is(leaked, driver.ErrBadConn), true)
+
+	_, err := ParseDataType("geometry(3")
+	verify(t, 7, "TypeParseError", "is", errors.Is(err, ErrParse), true)
+	verify(t, 8, "DBNotSupportError", "is", errors.Is(&DBNotSupportError{DriverName: "oracle"}, ErrUnsupported), true)
+	verify(t, 9, "VetoError", "unwrap", errors.Is(&VetoError{Err: ErrPermissionDenied}, ErrPermissionDenied), true)
+	verify(t, 10, "WithSide", "nil", WithSide(SideOld, nil), nil)
+}
+
+// TestErrors_withoutCause formats every error without its cause, as a zero
+// value or an error built by hand would be.
+func TestErrors_withoutCause(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"DBConnError", &DBConnError{Message: "dial"}, "connection error:dial"},
+		{"DataAccessError", &DataAccessError{Message: "Db query error"}, "access data error:Db query error"},
+		{"CanceledError", &CanceledError{Sql: "SELECT 1"}, "query SELECT 1 canceled"},
+		{"CanceledError", &CanceledError{TableName: "student", Side: SideOld}, "introspecting table student in the old database canceled"},
+		{"RowError", &RowError{Row: 2}, "row 2 error"},
+		{"VetoError", &VetoError{Sql: "DROP TABLE `student`"}, "statement DROP TABLE `student` vetoed"},
+		{"RollbackError", &RollbackError{}, "transaction error, rollback error"},
+		{"RollbackError", &RollbackError{Err: ErrNotFound}, "object not found, rollback error"},
+		{"FilterError", &FilterError{Pattern: "[a"}, "invalid filter pattern [a"},
+	}
+	for i, c := range cases {
+		verify(t, i+1, c.name, "message", c.err.Error(), c.expected)
+	}
+	verify(t, len(cases)+1, "RollbackError", "unwrap", errors.Is(&RollbackError{RollbackErr: driver.ErrBadConn}, driver.ErrBadConn), true)
+}
@@ -0,0 +1,215 @@
+package dbdiff
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// TempTablePatterns matches the backup and temporary tables left behind by
+// manual copies and online schema change tools such as gh-ost.
+var TempTablePatterns = []string{"_*_gho", "_*_ghc", "_*_del", "_bak_*", "*_bak", "tmp_*", "_*_new", "_*_old"}
+
+// Filter limits what Scheme introspects. Patterns are globs as understood by
+// path.Match, or regular expressions when written as /expr/. Column and index
+// patterns containing a dot match "table.name", the others match the bare
+// name. A nil Filter includes everything. The patterns are read by Compile,
+// compile again after changing them.
+type Filter struct {
+	IncludeTables  []string
+	ExcludeTables  []string
+	ExcludeColumns []string
+	ExcludeIndexes []string
+	// ObjectTypes limits the diff to these kinds, empty means all of them.
+	// ObjectTable covers table options and SHOW CREATE TABLE, tables are
+	// always listed so that added and removed tables are reported.
+	ObjectTypes []ObjectType
+
+	mutex    sync.RWMutex
+	patterns *filterPatterns
+}
+
+type filterPatterns struct {
+	includeTables  []*namePattern
+	excludeTables  []*namePattern
+	excludeColumns []*namePattern
+	excludeIndexes []*namePattern
+}
+
+type namePattern struct {
+	expr string
+	re   *regexp.Regexp
+}
+
+func compileNamePatterns(exprs []string) ([]*namePattern, error) {
+	patterns := make([]*namePattern, len(exprs))
+	for i, expr := range exprs {
+		pattern := &namePattern{expr: expr}
+		if len(expr) > 1 && strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/") {
+			re, err := regexp.Compile(expr[1 : len(expr)-1])
+			if err != nil {
+				return nil, &FilterError{Pattern: expr, Err: err}
+			}
+			pattern.re = re
+		} else if _, err := path.Match(expr, ""); err != nil {
+			return nil, &FilterError{Pattern: expr, Err: err}
+		}
+		patterns[i] = pattern
+	}
+	return patterns, nil
+}
+
+func (pattern *namePattern) qualified() bool {
+	return pattern.re == nil && strings.Contains(pattern.expr, ".")
+}
+
+func (pattern *namePattern) match(name string) bool {
+	if pattern.re != nil {
+		return pattern.re.MatchString(name)
+	}
+	ok, _ := path.Match(pattern.expr, name)
+	return ok
+}
+
+func matchAny(patterns []*namePattern, tableName, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.qualified() {
+			if pattern.match(tableName + "." + name) {
+				return true
+			}
+		} else if pattern.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compile checks and compiles the patterns of the filter, Scheme calls it
+// before introspecting.
+func (filter *Filter) Compile() error {
+	if filter == nil {
+		return nil
+	}
+	patterns, err := filter.compile()
+	filter.mutex.Lock()
+	defer filter.mutex.Unlock()
+	filter.patterns = patterns
+	return err
+}
+
+func (filter *Filter) compile() (*filterPatterns, error) {
+	var (
+		patterns = &filterPatterns{}
+		err      error
+	)
+	if patterns.includeTables, err = compileNamePatterns(filter.IncludeTables); err != nil {
+		return nil, err
+	}
+	if patterns.excludeTables, err = compileNamePatterns(filter.ExcludeTables); err != nil {
+		return nil, err
+	}
+	if patterns.excludeColumns, err = compileNamePatterns(filter.ExcludeColumns); err != nil {
+		return nil, err
+	}
+	if patterns.excludeIndexes, err = compileNamePatterns(filter.ExcludeIndexes); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// compiled is what the last Compile compiled, the patterns are compiled for
+// the call when the filter was never compiled. It is nil when they are
+// invalid, the filter then includes everything.
+func (filter *Filter) compiled() *filterPatterns {
+	filter.mutex.RLock()
+	patterns := filter.patterns
+	filter.mutex.RUnlock()
+	if patterns == nil {
+		patterns, _ = filter.compile()
+	}
+	return patterns
+}
+
+func (filter *Filter) IncludesObject(objectType ObjectType) bool {
+	if filter == nil || len(filter.ObjectTypes) == 0 {
+		return true
+	}
+	for _, t := range filter.ObjectTypes {
+		if t == objectType {
+			return true
+		}
+	}
+	return false
+}
+
+func (filter *Filter) IncludesTable(tableName string) bool {
+	if filter == nil {
+		return true
+	}
+	patterns := filter.compiled()
+	if patterns == nil {
+		return true
+	}
+	if len(patterns.includeTables) != 0 && !matchAny(patterns.includeTables, "", tableName) {
+		return false
+	}
+	return !matchAny(patterns.excludeTables, "", tableName)
+}
+
+func (filter *Filter) IncludesColumn(tableName, columnName string) bool {
+	if filter == nil {
+		return true
+	}
+	patterns := filter.compiled()
+	return patterns == nil || !matchAny(patterns.excludeColumns, tableName, columnName)
+}
+
+func (filter *Filter) IncludesIndex(tableName, keyName string) bool {
+	if filter == nil {
+		return true
+	}
+	patterns := filter.compiled()
+	return patterns == nil || !matchAny(patterns.excludeIndexes, tableName, keyName)
+}
+
+// FilterDataBase applies the filter to a database read from a snapshot, Scheme
+// applies it while introspecting.
+func (filter *Filter) FilterDataBase(dataBase *DataBase) (*DataBase, error) {
+	if err := filter.Compile(); err != nil {
+		return nil, err
+	}
+	filtered := &DataBase{}
+	if filter.IncludesObject(ObjectOption) {
+		filtered.Options = dataBase.Options
+	}
+	for _, table := range dataBase.Tables {
+		if !filter.IncludesTable(table.TableName) {
+			continue
+		}
+		copied := &Table{TableScheme: TableScheme{TableName: table.TableName}, DropTableSql: table.DropTableSql}
+		if filter.IncludesObject(ObjectTable) {
+			copied.TableScheme = table.TableScheme
+			copied.CreateTableSql = table.CreateTableSql
+		}
+		if filter.IncludesObject(ObjectColumn) {
+			for _, column := range table.ColumnList {
+				if filter.IncludesColumn(table.TableName, column.ColumnName) {
+					copied.ColumnList = append(copied.ColumnList, column)
+				}
+			}
+		}
+		if filter.IncludesObject(ObjectIndex) {
+			for _, index := range table.IndexList {
+				if filter.IncludesIndex(table.TableName, index.KeyName) {
+					copied.IndexList = append(copied.IndexList, index)
+				}
+			}
+		}
+		if filter.IncludesObject(ObjectForeignKey) {
+			copied.ForeignKeyList = table.ForeignKeyList
+		}
+		filtered.Tables = append(filtered.Tables, copied)
+	}
+	return filtered, nil
+}
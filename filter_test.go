@@ -0,0 +1,55 @@
+package dbdiff
+
+import (
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	filter := &Filter{
+		ExcludeTables:  append([]string{"/^audit_\\d+$/"}, TempTablePatterns...),
+		ExcludeColumns: []string{"*.updated_at", "tmp_col"},
+		ExcludeIndexes: []string{"student.idx_tmp_*"},
+		ObjectTypes:    []ObjectType{ObjectColumn, ObjectIndex},
+	}
+	if err := filter.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		output   bool
+		expected bool
+	}{
+		{filter.IncludesTable("student"), true},
+		{filter.IncludesTable("_student_gho"), false},
+		{filter.IncludesTable("_student_del"), false},
+		{filter.IncludesTable("_bak_student"), false},
+		{filter.IncludesTable("tmp_student"), false},
+		{filter.IncludesTable("audit_2018"), false},
+		{filter.IncludesTable("audit_log"), true},
+		{filter.IncludesColumn("student", "updated_at"), false},
+		{filter.IncludesColumn("teacher", "tmp_col"), false},
+		{filter.IncludesColumn("student", "name"), true},
+		{filter.IncludesIndex("student", "idx_tmp_name"), false},
+		{filter.IncludesIndex("teacher", "idx_tmp_name"), true},
+		{filter.IncludesObject(ObjectIndex), true},
+		{filter.IncludesObject(ObjectOption), false},
+		{(*Filter)(nil).IncludesTable("tmp_student"), true},
+	}
+	for i, c := range cases {
+		verify(t, i+1, "Filter", i, c.output, c.expected)
+	}
+
+	include := &Filter{IncludeTables: []string{"student*"}}
+	verify(t, 20, "Filter include", "student_score", include.IncludesTable("student_score"), true)
+	verify(t, 21, "Filter include", "teacher", include.IncludesTable("teacher"), false)
+
+	_, ok := (&Filter{ExcludeTables: []string{"/(/"}}).Compile().(*FilterError)
+	verify(t, 22, "Filter compile", "/(/", ok, true)
+
+	//compiling again picks up the patterns changed since
+	filter.ExcludeTables = append(filter.ExcludeTables, "student")
+	verify(t, 23, "Filter compile", "before", filter.IncludesTable("student"), true)
+	if err := filter.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 24, "Filter compile", "after", filter.IncludesTable("student"), false)
+}
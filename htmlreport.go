@@ -0,0 +1,196 @@
+package dbdiff
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+type htmlReport struct {
+	Summary *htmlSummary
+	Tables  []*htmlTable
+	Options []*htmlItem
+}
+
+type htmlSummary struct {
+	Changes, Added, Removed, Modified, Safe, Lossy, Blocking, Suppressed int
+}
+
+type htmlTable struct {
+	Name     string
+	Severity Severity
+	Items    []*htmlItem
+	Old      []*htmlLine
+	New      []*htmlLine
+}
+
+type htmlItem struct {
+	*JSONChange
+	Members string
+}
+
+func newHTMLItems(items []*reportItem) []*htmlItem {
+	htmlItems := make([]*htmlItem, len(items))
+	for i, item := range items {
+		htmlItems[i] = &htmlItem{JSONChange: newJSONChange(item)}
+		if item.members != nil {
+			htmlItems[i].Members = item.members.String()
+		}
+	}
+	return htmlItems
+}
+
+// htmlLine is a line of CREATE TABLE, Changed when the other side does not
+// have it.
+type htmlLine struct {
+	Text    string
+	Changed bool
+}
+
+// writeHTMLReport renders diff as a single file with inline styles and
+// scripts: a summary header, filters by change kind and the CREATE TABLE
+// statements of both sides next to each other.
+func writeHTMLReport(w io.Writer, diff *DiffDataBase) error {
+	summary := summarize(diff)
+	report := &htmlReport{
+		Summary: &htmlSummary{
+			Changes:    summary.changes,
+			Added:      summary.kinds[ChangeAdded],
+			Removed:    summary.kinds[ChangeRemoved],
+			Modified:   summary.kinds[ChangeModified],
+			Safe:       summary.severities[SeveritySafe],
+			Lossy:      summary.severities[SeverityLossy],
+			Blocking:   summary.severities[SeverityBlocking],
+			Suppressed: summary.suppressed,
+		},
+		Options: newHTMLItems(reportOptions(diff)),
+	}
+	for _, table := range reportTables(diff) {
+		var (
+			items    = table.all()
+			old, new string
+		)
+		if table.diffTable.TableOld != nil {
+			old = table.diffTable.TableOld.CreateTableSql
+		}
+		if table.diffTable.TableNew != nil {
+			new = table.diffTable.TableNew.CreateTableSql
+		}
+		report.Tables = append(report.Tables, &htmlTable{
+			Name:     table.name,
+			Severity: table.severity(),
+			Items:    newHTMLItems(items),
+			Old:      htmlLines(old, new),
+			New:      htmlLines(new, old),
+		})
+	}
+	return htmlReportTpl.Execute(w, report)
+}
+
+func htmlLines(text, other string) []*htmlLine {
+	if text == "" {
+		return nil
+	}
+	others := map[string]bool{}
+	for _, line := range strings.Split(other, "\n") {
+		others[strings.TrimSuffix(strings.TrimSpace(line), ",")] = true
+	}
+	lines := []*htmlLine{}
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, &htmlLine{Text: line, Changed: !others[strings.TrimSuffix(strings.TrimSpace(line), ",")]})
+	}
+	return lines
+}
+
+var htmlReportTpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Schema diff</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+header { border-bottom: 1px solid #e1e4e8; margin-bottom: 1em; }
+.counts span { display: inline-block; margin-right: 1.5em; }
+.filters label { margin-right: 1em; }
+section.table { border: 1px solid #e1e4e8; border-radius: 4px; margin: 1em 0; padding: 0 1em 1em; }
+.safe { color: #22863a; }
+.lossy { color: #b08800; }
+.blocking { color: #cb2431; }
+.change { margin: .5em 0; }
+table.attrs { border-collapse: collapse; margin: .5em 0; }
+table.attrs td, table.attrs th { border: 1px solid #e1e4e8; padding: 2px 8px; font-family: monospace; text-align: left; }
+pre { background: #f6f8fa; padding: .5em; overflow-x: auto; margin: .5em 0; }
+.sides { display: flex; gap: 1em; }
+.sides > div { flex: 1; min-width: 0; }
+.old .changed { background: #e6ffed; }
+.new .changed { background: #ffeef0; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<header>
+<h1>Schema diff</h1>
+{{with .Summary}}<p class="counts">
+<span><b>{{.Changes}}</b> changes</span>
+<span>{{.Added}} added</span>
+<span>{{.Removed}} removed</span>
+<span>{{.Modified}} modified</span>
+<span class="safe">{{.Safe}} safe</span>
+<span class="lossy">{{.Lossy}} lossy</span>
+<span class="blocking">{{.Blocking}} blocking</span>
+<span>{{.Suppressed}} suppressed</span>
+</p>{{end}}
+<p class="filters">Show:
+<label><input type="checkbox" value="added" checked> added</label>
+<label><input type="checkbox" value="removed" checked> removed</label>
+<label><input type="checkbox" value="modified" checked> modified</label>
+</p>
+</header>
+{{if not .Summary.Changes}}<p>No differences.</p>{{end}}
+{{range .Tables}}<section class="table">
+<h2>{{.Name}} <small class="{{.Severity}}">{{.Severity}}</small></h2>
+{{range .Items}}{{template "item" .}}{{end}}
+{{if or .Old .New}}<div class="sides">
+<div class="old"><h3>old</h3><pre>{{range .Old}}<span{{if .Changed}} class="changed"{{end}}>{{.Text}}</span>
+{{end}}</pre></div>
+<div class="new"><h3>new</h3><pre>{{range .New}}<span{{if .Changed}} class="changed"{{end}}>{{.Text}}</span>
+{{end}}</pre></div>
+</div>{{end}}
+</section>
+{{end}}
+{{if .Options}}<section class="table">
+<h2>Options</h2>
+{{range .Options}}{{template "item" .}}{{end}}
+</section>{{end}}
+<script>
+(function() {
+  var boxes = document.querySelectorAll(".filters input");
+  function update() {
+    var shown = {};
+    boxes.forEach(function(box) { shown[box.value] = box.checked; });
+    document.querySelectorAll(".change").forEach(function(el) {
+      el.classList.toggle("hidden", !shown[el.getAttribute("data-kind")]);
+    });
+    document.querySelectorAll("section.table").forEach(function(el) {
+      var visible = el.querySelectorAll(".change:not(.hidden)").length > 0;
+      el.classList.toggle("hidden", !visible);
+    });
+  }
+  boxes.forEach(function(box) { box.addEventListener("change", update); });
+})();
+</script>
+</body>
+</html>
+{{define "item"}}<div class="change" data-kind="{{.Kind}}">
+<b>{{.Kind}} {{.ObjectType}}</b> <code>{{.QualifiedName}}</code>
+<span class="{{.Severity}}">{{.Severity}}</span>{{if .Reasons}}: {{join .Reasons ", "}}{{end}}
+{{if .Attributes}}<table class="attrs"><tr><th>attribute</th><th>old</th><th>new</th></tr>
+{{range .Attributes}}<tr><td>{{.Name}}</td><td>{{.Old}}</td><td>{{.New}}</td></tr>
+{{end}}</table>{{end}}
+{{with .Members}}<p>members: {{.}}</p>{{end}}
+{{if .Sql}}<pre>{{range .Sql}}{{.}}
+{{end}}</pre>{{end}}
+</div>{{end}}
+`))
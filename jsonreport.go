@@ -0,0 +1,165 @@
+package dbdiff
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONReportVersion is raised whenever a field of the JSON report is renamed,
+// removed or changes meaning. Adding fields keeps the version.
+const JSONReportVersion = 1
+
+// JSONReport is the document written by WriteReport in FormatJSON. Changes
+// are listed table by table, each table change before its columns and
+// indexes, followed by the option changes.
+//
+// Attribute values are named after the two databases: old is the value in
+// the old database, the source of truth, new the value in the new database
+// the migration runs against. Sql holds the statements that migrate the
+// change in the order a plan runs them, it is empty for options.
+//
+// CreateTableDiffs lists the unified diffs of the CREATE TABLE statements
+// when DBDiff.CreateTableDiff is enabled. A table there without changes
+// differs in something the scheme model does not compare.
+type JSONReport struct {
+	Version          int                    `json:"version"`
+	Summary          *JSONSummary           `json:"summary"`
+	Changes          []*JSONChange          `json:"changes"`
+	Suppressed       []*JSONSuppression     `json:"suppressed"`
+	CreateTableDiffs []*JSONCreateTableDiff `json:"create_table_diffs"`
+}
+
+type JSONCreateTableDiff struct {
+	Table string `json:"table"`
+	Diff  string `json:"diff"`
+}
+
+type JSONSummary struct {
+	Changes    int `json:"changes"`
+	Safe       int `json:"safe"`
+	Lossy      int `json:"lossy"`
+	Blocking   int `json:"blocking"`
+	Suppressed int `json:"suppressed"`
+}
+
+type JSONChange struct {
+	Kind          ChangeKind       `json:"kind"`
+	ObjectType    ObjectType       `json:"object_type"`
+	Table         string           `json:"table,omitempty"`
+	Name          string           `json:"name"`
+	QualifiedName string           `json:"qualified_name"`
+	Severity      string           `json:"severity"`
+	Reasons       []string         `json:"reasons"`
+	Attributes    []*JSONAttribute `json:"attributes"`
+	Members       *JSONMembers     `json:"members,omitempty"`
+	Sql           []string         `json:"sql"`
+}
+
+type JSONAttribute struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// JSONMembers is the member change of an ENUM or SET column, usage is only
+// set when the rows were counted.
+type JSONMembers struct {
+	Added     []string         `json:"added"`
+	Removed   []string         `json:"removed"`
+	Renamed   []*MemberRename  `json:"renamed"`
+	Reordered bool             `json:"reordered"`
+	InPlace   bool             `json:"in_place"`
+	Usage     map[string]int64 `json:"usage,omitempty"`
+}
+
+type JSONSuppression struct {
+	Rule       string     `json:"rule"`
+	ObjectType ObjectType `json:"object_type"`
+	Table      string     `json:"table,omitempty"`
+	Name       string     `json:"name"`
+	Attribute  string     `json:"attribute"`
+	Old        string     `json:"old"`
+	New        string     `json:"new"`
+}
+
+// NewJSONReport builds the JSON report of diff.
+func NewJSONReport(diff *DiffDataBase) *JSONReport {
+	report := &JSONReport{
+		Version:          JSONReportVersion,
+		Summary:          &JSONSummary{},
+		Changes:          []*JSONChange{},
+		Suppressed:       []*JSONSuppression{},
+		CreateTableDiffs: []*JSONCreateTableDiff{},
+	}
+	items := []*reportItem{}
+	for _, table := range reportTables(diff) {
+		items = append(items, table.all()...)
+		if table.diffTable.CreateTableDiff != "" {
+			report.CreateTableDiffs = append(report.CreateTableDiffs, &JSONCreateTableDiff{
+				Table: table.name,
+				Diff:  table.diffTable.CreateTableDiff,
+			})
+		}
+	}
+	items = append(items, reportOptions(diff)...)
+
+	for _, item := range items {
+		report.Changes = append(report.Changes, newJSONChange(item))
+		report.Summary.Changes++
+		switch item.change.Severity {
+		case SeveritySafe:
+			report.Summary.Safe++
+		case SeverityLossy:
+			report.Summary.Lossy++
+		case SeverityBlocking:
+			report.Summary.Blocking++
+		}
+	}
+	for _, suppression := range diff.Suppressed {
+		report.Suppressed = append(report.Suppressed, &JSONSuppression{
+			Rule:       suppression.Rule,
+			ObjectType: suppression.ObjectType,
+			Table:      suppression.TableName,
+			Name:       suppression.Name,
+			Attribute:  suppression.Attribute,
+			Old:        suppression.Old,
+			New:        suppression.New,
+		})
+	}
+	report.Summary.Suppressed = len(report.Suppressed)
+	return report
+}
+
+func newJSONChange(item *reportItem) *JSONChange {
+	change := &JSONChange{
+		Kind:          item.change.Kind,
+		ObjectType:    item.change.ObjectType,
+		Table:         item.change.TableName,
+		Name:          item.change.Name,
+		QualifiedName: item.change.QualifiedName(),
+		Severity:      item.change.Severity.String(),
+		Reasons:       append([]string{}, item.change.Reasons...),
+		Attributes:    []*JSONAttribute{},
+		Sql:           append([]string{}, item.sqls...),
+	}
+	for _, attr := range item.attrs {
+		change.Attributes = append(change.Attributes, &JSONAttribute{Name: attr.Name, Old: attr.Old, New: attr.New})
+	}
+	if members := item.members; members != nil {
+		change.Members = &JSONMembers{
+			Added:     append([]string{}, members.Added...),
+			Removed:   append([]string{}, members.Removed...),
+			Renamed:   append([]*MemberRename{}, members.Renamed...),
+			Reordered: members.Reordered,
+			InPlace:   members.InPlace,
+			Usage:     members.Usage,
+		}
+	}
+	return change
+}
+
+func writeJSONReport(w io.Writer, diff *DiffDataBase) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(NewJSONReport(diff))
+}
@@ -0,0 +1,134 @@
+package dbdiff
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// junitOptions names the test case of the option changes.
+const junitOptions = "options"
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// writeJUnitReport renders diff as a JUnit test suite with one test case per
+// compared table, failing when the table has changes, so that CI servers list
+// the drifted tables. Differences suppressed by the rules go to the output of
+// the test case and do not fail it.
+func writeJUnitReport(w io.Writer, diff *DiffDataBase) error {
+	var (
+		suite      = &junitTestSuite{Name: "dbdiff"}
+		tables     = map[string]*reportTable{}
+		suppressed = map[string]*bytes.Buffer{}
+	)
+	for _, table := range reportTables(diff) {
+		tables[table.name] = table
+	}
+	for _, suppression := range diff.Suppressed {
+		name := suppression.TableName
+		switch suppression.ObjectType {
+		case ObjectTable:
+			name = suppression.Name
+		case ObjectOption:
+			name = junitOptions
+		}
+		if suppressed[name] == nil {
+			suppressed[name] = &bytes.Buffer{}
+		}
+		fmt.Fprintln(suppressed[name], suppression)
+	}
+
+	for _, diffTable := range diff.DiffTables {
+		testCase := &junitTestCase{Name: diffTable.TableName, ClassName: "dbdiff.table"}
+		if table := tables[diffTable.TableName]; table != nil {
+			var text bytes.Buffer
+			items := table.all()
+			for _, item := range items {
+				writeTextItem(&text, "", item)
+			}
+			writeTextDiff(&text, "", table.diffTable.CreateTableDiff, false)
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d changes (%s)", len(items), table.severity()),
+				Type:    table.severity().String(),
+				Text:    text.String(),
+			}
+		}
+		if out := suppressed[diffTable.TableName]; out != nil {
+			testCase.SystemOut = out.String()
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	if options := reportOptions(diff); len(options) != 0 || suppressed[junitOptions] != nil {
+		testCase := &junitTestCase{Name: junitOptions, ClassName: "dbdiff.options"}
+		if len(options) != 0 {
+			var text bytes.Buffer
+			severity := SeveritySafe
+			for _, option := range options {
+				writeTextItem(&text, "", option)
+				if option.change.Severity > severity {
+					severity = option.change.Severity
+				}
+			}
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d changes (%s)", len(options), severity),
+				Type:    severity.String(),
+				Text:    text.String(),
+			}
+		}
+		if out := suppressed[junitOptions]; out != nil {
+			testCase.SystemOut = out.String()
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	for _, testCase := range suite.Cases {
+		suite.Tests++
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+	}
+	suites := &junitTestSuites{
+		Name:     "dbdiff",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []*junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
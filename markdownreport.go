@@ -0,0 +1,125 @@
+package dbdiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeMarkdownReport renders diff for pull request comments: a summary line,
+// then one collapsible section per table with its attribute changes and the
+// statements that migrate it.
+func writeMarkdownReport(w io.Writer, diff *DiffDataBase) error {
+	var (
+		out     = bufio.NewWriter(w)
+		summary = summarize(diff)
+		tables  = reportTables(diff)
+	)
+	fmt.Fprintln(out, "## Schema diff")
+	fmt.Fprintln(out)
+	if summary.changes == 0 && len(tables) == 0 {
+		fmt.Fprintln(out, "No differences.")
+	} else {
+		fmt.Fprintf(out, "**%d changes**: %d added, %d removed, %d modified; %d safe, %d lossy, %d blocking",
+			summary.changes, summary.kinds[ChangeAdded], summary.kinds[ChangeRemoved], summary.kinds[ChangeModified],
+			summary.severities[SeveritySafe], summary.severities[SeverityLossy], summary.severities[SeverityBlocking])
+		if summary.suppressed != 0 {
+			fmt.Fprintf(out, "; %d suppressed", summary.suppressed)
+		}
+		fmt.Fprintln(out)
+	}
+
+	for _, table := range tables {
+		items := table.all()
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "<details>")
+		fmt.Fprintf(out, "<summary><b>%s</b>: %d changes (%s)</summary>\n\n", htmlEscaper.Replace(table.name), len(items), table.severity())
+		for _, item := range items {
+			writeMarkdownItem(out, item)
+		}
+		if createTableDiff := table.diffTable.CreateTableDiff; createTableDiff != "" {
+			fence := markdownFence([]string{createTableDiff})
+			fmt.Fprintf(out, "%sdiff\n%s%s\n\n", fence, createTableDiff, fence)
+		}
+		fmt.Fprintln(out, "</details>")
+	}
+
+	if options := reportOptions(diff); len(options) != 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "### Options")
+		fmt.Fprintln(out)
+		for _, option := range options {
+			writeMarkdownItem(out, option)
+		}
+	}
+	return out.Flush()
+}
+
+func writeMarkdownItem(out io.Writer, item *reportItem) {
+	change := item.change
+	fmt.Fprintf(out, "- **%s %s** %s (%s)", change.Kind, change.ObjectType, markdownCode(change.QualifiedName()), change.Severity)
+	if len(change.Reasons) != 0 {
+		fmt.Fprintf(out, ": %s", markdownEscape(strings.Join(change.Reasons, ", ")))
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out)
+
+	if len(item.attrs) != 0 {
+		fmt.Fprintln(out, "  | Attribute | Old | New |")
+		fmt.Fprintln(out, "  |---|---|---|")
+		for _, attr := range item.attrs {
+			fmt.Fprintf(out, "  | %s | %s | %s |\n", attr.Name, markdownCode(attr.Old), markdownCode(attr.New))
+		}
+		fmt.Fprintln(out)
+	}
+	if item.members != nil {
+		fmt.Fprintf(out, "  Members: %s\n\n", markdownEscape(item.members.String()))
+	}
+	if len(item.sqls) != 0 {
+		fence := markdownFence(item.sqls)
+		fmt.Fprintln(out, "  "+fence+"sql")
+		for _, sql := range item.sqls {
+			fmt.Fprintln(out, "  "+strings.Replace(strings.TrimSuffix(sql, ";")+";", "\n", "\n  ", -1))
+		}
+		fmt.Fprintln(out, "  "+fence)
+		fmt.Fprintln(out)
+	}
+}
+
+var (
+	markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "|", `\|`, "<", "&lt;", ">", "&gt;")
+	htmlEscaper     = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// markdownCode renders s as inline code inside a table cell, where GitHub
+// still reads a pipe as the end of the cell unless it is escaped.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.Replace(strings.Replace(s, "\n", " ", -1), "|", `\|`, -1)
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
+
+// markdownFence is a code fence longer than any run of backticks in sqls.
+func markdownFence(sqls []string) string {
+	fence := "```"
+	for _, sql := range sqls {
+		for strings.Contains(sql, fence) {
+			fence += "`"
+		}
+	}
+	return fence
+}
@@ -0,0 +1,218 @@
+package dbdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MemberRename is a member replaced at the same position by a new name.
+type MemberRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MemberChange describes how the members of an ENUM or SET column change when
+// the column of the new database (From) is migrated to the definition of the
+// old database (To).
+type MemberChange struct {
+	Family    TypeFamily
+	From      []string
+	To        []string
+	Added     []string
+	Removed   []string
+	Renamed   []*MemberRename
+	Reordered bool
+	// InPlace is set when MySQL only rewrites the metadata: members are
+	// appended at the end and the storage size of the column does not change.
+	// Every other change rebuilds the table.
+	InPlace bool
+	// Usage counts, for each removed or renamed member, the rows that still
+	// use it. It is nil until CountMemberUsage is called.
+	Usage map[string]int64
+}
+
+// DiffMembers compares the members of two ENUM or SET types, it returns nil
+// when the types are not both ENUM or both SET.
+func DiffMembers(from, to string) *MemberChange {
+	dataTypeFrom, errFrom := ParseDataType(from)
+	dataTypeTo, errTo := ParseDataType(to)
+	if errFrom != nil || errTo != nil {
+		return nil
+	}
+	family := dataTypeFrom.Family()
+	if family != dataTypeTo.Family() || family != FamilyEnum && family != FamilySet {
+		return nil
+	}
+
+	change := &MemberChange{Family: family, From: dataTypeFrom.Members, To: dataTypeTo.Members}
+	for i, member := range change.From {
+		if containsStr(change.To, member) {
+			continue
+		}
+		if i < len(change.To) && !containsStr(change.From, change.To[i]) {
+			change.Renamed = append(change.Renamed, &MemberRename{From: member, To: change.To[i]})
+			continue
+		}
+		change.Removed = append(change.Removed, member)
+	}
+	for i, member := range change.To {
+		if containsStr(change.From, member) {
+			continue
+		}
+		if i < len(change.From) && !containsStr(change.To, change.From[i]) {
+			//counted as renamed
+			continue
+		}
+		change.Added = append(change.Added, member)
+	}
+
+	var kept []string
+	for _, member := range change.To {
+		if containsStr(change.From, member) {
+			kept = append(kept, member)
+		}
+	}
+	for i, member := range commonMembers(change.From, change.To) {
+		if kept[i] != member {
+			change.Reordered = true
+			break
+		}
+	}
+
+	change.InPlace = len(change.Removed) == 0 && len(change.Renamed) == 0 && !change.Reordered &&
+		isPrefix(change.From, change.To) && memberStorage(family, len(change.From)) == memberStorage(family, len(change.To))
+	return change
+}
+
+func commonMembers(members, others []string) []string {
+	common := []string{}
+	for _, member := range members {
+		if containsStr(others, member) {
+			common = append(common, member)
+		}
+	}
+	return common
+}
+
+func isPrefix(prefix, members []string) bool {
+	if len(prefix) > len(members) {
+		return false
+	}
+	for i, member := range prefix {
+		if members[i] != member {
+			return false
+		}
+	}
+	return true
+}
+
+// memberStorage is the number of bytes a value takes on disk.
+func memberStorage(family TypeFamily, members int) int {
+	if family == FamilyEnum {
+		if members > 255 {
+			return 2
+		}
+		return 1
+	}
+	size := (members + 7) / 8
+	if size > 4 {
+		return 8
+	}
+	return size
+}
+
+// Dropped lists the members whose values do not survive the change: the
+// removed members and the old names of renamed ones.
+func (change *MemberChange) Dropped() []string {
+	dropped := append([]string{}, change.Removed...)
+	for _, rename := range change.Renamed {
+		dropped = append(dropped, rename.From)
+	}
+	return dropped
+}
+
+// Unused reports whether CountMemberUsage found no row using a dropped member.
+func (change *MemberChange) Unused() bool {
+	if change.Usage == nil {
+		return false
+	}
+	for _, count := range change.Usage {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (change *MemberChange) String() string {
+	parts := []string{}
+	if len(change.Added) != 0 {
+		parts = append(parts, "adds "+quoteMembers(change.Added))
+	}
+	if len(change.Removed) != 0 {
+		parts = append(parts, "removes "+quoteMembers(change.Removed))
+	}
+	for _, rename := range change.Renamed {
+		parts = append(parts, fmt.Sprintf("renames '%s' to '%s'", rename.From, rename.To))
+	}
+	if change.Reordered {
+		parts = append(parts, "reorders members")
+	}
+	if change.InPlace {
+		parts = append(parts, "in place")
+	} else {
+		parts = append(parts, "rebuilds table")
+	}
+	for _, member := range change.Dropped() {
+		if count, ok := change.Usage[member]; ok {
+			parts = append(parts, fmt.Sprintf("%d rows use '%s'", count, member))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func quoteMembers(members []string) string {
+	quoted := make([]string, len(members))
+	for i, member := range members {
+		quoted[i] = "'" + member + "'"
+	}
+	return strings.Join(quoted, ",")
+}
+
+// CountMemberUsage counts the rows of the database the migration runs against
+// (the new one) that use a removed or renamed member, so that a narrowing
+// ENUM or SET change is only reported as lossy when it actually loses data.
+func (diff *DiffDataBase) CountMemberUsage(conn *DBConn) error {
+	db, err := conn.Conn()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		tpl       = NewDBTemplate(db)
+		schemeSql = &SchemeSql{}
+	)
+	for _, diffTable := range diff.DiffTables {
+		for _, diffColumn := range diffTable.DiffColumns {
+			change := diffColumn.Members
+			if change == nil {
+				continue
+			}
+			change.Usage = map[string]int64{}
+			for _, member := range change.Dropped() {
+				usage := &memberUsage{}
+				sql, args := schemeSql.MemberUsageSql(diffTable.TableName, diffColumn.ItemNew.ColumnName, member, change.Family == FamilySet)
+				if err := tpl.QuerySingle(sql, usage, args...); err != nil {
+					return err
+				}
+				change.Usage[member] = usage.RowCount
+			}
+		}
+	}
+	return nil
+}
+
+type memberUsage struct {
+	RowCount int64 `col:"ROW_COUNT"`
+}
@@ -0,0 +1,59 @@
+package dbdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffMembers(t *testing.T) {
+	cases := []struct {
+		from, to  string
+		added     string
+		removed   string
+		renamed   string
+		reordered bool
+		inPlace   bool
+	}{
+		{"enum('a','b')", "enum('a','b','c')", "c", "", "", false, true},
+		{"enum('a','b','c')", "enum('a','c')", "", "b", "", false, false},
+		{"enum('a','b','c')", "enum('a','x','c')", "", "", "b>x", false, false},
+		{"enum('a','b')", "enum('b','a')", "", "", "", true, false},
+		{"enum('a','b')", "enum('c','a','b')", "c", "", "", false, false},
+		{"set('a','b')", "set('a','b','c')", "c", "", "", false, true},
+		{"set('a','b','c','d','e','f','g','h')", "set('a','b','c','d','e','f','g','h','i')", "i", "", "", false, false},
+	}
+	for i, c := range cases {
+		change := DiffMembers(c.from, c.to)
+		renamed := []string{}
+		for _, rename := range change.Renamed {
+			renamed = append(renamed, rename.From+">"+rename.To)
+		}
+		input := c.from + " -> " + c.to
+		verify(t, i+1, "DiffMembers added", input, strings.Join(change.Added, ","), c.added)
+		verify(t, i+1, "DiffMembers removed", input, strings.Join(change.Removed, ","), c.removed)
+		verify(t, i+1, "DiffMembers renamed", input, strings.Join(renamed, ","), c.renamed)
+		verify(t, i+1, "DiffMembers reordered", input, change.Reordered, c.reordered)
+		verify(t, i+1, "DiffMembers inPlace", input, change.InPlace, c.inPlace)
+	}
+
+	verify(t, 10, "DiffMembers", "enum -> set", DiffMembers("enum('a')", "set('a')") == nil, true)
+	verify(t, 11, "DiffMembers", "varchar", DiffMembers("varchar(10)", "varchar(20)") == nil, true)
+}
+
+func TestMemberUsage(t *testing.T) {
+	var (
+		old        = classifyTestColumn("enum('a','c')", "YES", "utf8")
+		new        = classifyTestColumn("enum('a','b','c')", "YES", "utf8")
+		diffColumn = &DiffColumn{ItemOld: old, ItemNew: new, Changes: columnChanges(old, new, nil), Members: DiffMembers(new.ColumnType, old.ColumnType)}
+	)
+	verify(t, 1, "classifyColumn", "not counted", classifyColumn("student", diffColumn).Severity, SeverityLossy)
+	diffColumn.Members.Usage = map[string]int64{"b": 3}
+	verify(t, 2, "classifyColumn", "used", classifyColumn("student", diffColumn).Severity, SeverityLossy)
+	verify(t, 3, "MemberChange", "String", diffColumn.Members.String(), "removes 'b', rebuilds table, 3 rows use 'b'")
+	diffColumn.Members.Usage["b"] = 0
+	verify(t, 4, "classifyColumn", "unused", classifyColumn("student", diffColumn).Severity, SeveritySafe)
+
+	sql, args := s.MemberUsageSql("student", "tags", "it's", true)
+	verify(t, 5, "MemberUsageSql", "set", sql, "SELECT COUNT(*) AS ROW_COUNT FROM `student` WHERE FIND_IN_SET(?, `tags`) > 0")
+	verify(t, 6, "MemberUsageSql", "args", args[0], "it's")
+}
@@ -0,0 +1,180 @@
+package dbdiff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Normalizer canonicalizes the scheme values that MySQL 5.7, MySQL 8.0 and
+// MariaDB report differently for the same definition, so that comparing two
+// servers of different versions does not report false differences.
+type Normalizer struct {
+	// KeepDisplayWidth compares integer display widths, which MySQL 8.0.19
+	// and later no longer report.
+	KeepDisplayWidth bool
+}
+
+func NewNormalizer() *Normalizer {
+	return &Normalizer{}
+}
+
+var (
+	spacesRegexp           = regexp.MustCompile(`\s+`)
+	currentTimestampRegexp = regexp.MustCompile(`(?i)\b(current_timestamp|now|localtimestamp|localtime)(\(\s*(\d*)\s*\))?`)
+)
+
+func (normalizer *Normalizer) NormalizeColumn(scheme ColumnScheme) ColumnScheme {
+	if normalizer == nil {
+		return scheme
+	}
+	scheme.ColumnType = normalizer.NormalizeType(scheme.ColumnType)
+	scheme.ColumnDefault = normalizer.NormalizeDefault(scheme.ColumnDefault)
+	scheme.Extra = normalizer.NormalizeExtra(scheme.Extra)
+	scheme.CharacterSetName = NormalizeCharset(scheme.CharacterSetName)
+	scheme.CollationName = NormalizeCharset(scheme.CollationName)
+	return scheme
+}
+
+func (normalizer *Normalizer) NormalizeTable(scheme TableScheme) TableScheme {
+	if normalizer == nil {
+		return scheme
+	}
+	scheme.TableCollation = NormalizeCharset(scheme.TableCollation)
+	scheme.Engine = strings.ToUpper(scheme.Engine)
+	scheme.RowFormat = strings.ToUpper(scheme.RowFormat)
+	return scheme
+}
+
+func (normalizer *Normalizer) NormalizeType(columnType string) string {
+	dataType, err := ParseDataType(columnType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(spacesRegexp.ReplaceAllString(columnType, " ")))
+	}
+	//charset and collation are compared on their own
+	return dataType.render(normalizer.KeepDisplayWidth)
+}
+
+// NormalizeDefault spells the current timestamp functions as
+// CURRENT_TIMESTAMP. The quotes of MariaDB are removed when reading the
+// columns, see mariaDBColumn.
+func (normalizer *Normalizer) NormalizeDefault(columnDefault NullString) NullString {
+	if !columnDefault.Valid {
+		return columnDefault
+	}
+	return NewNullString(normalizeCurrentTimestamp(columnDefault.String))
+}
+
+func (normalizer *Normalizer) NormalizeExtra(extra string) string {
+	extra = strings.TrimSpace(spacesRegexp.ReplaceAllString(extra, " "))
+	extra = strings.TrimSpace(strings.Replace(strings.ToUpper(extra), "DEFAULT_GENERATED", "", -1))
+	return normalizeCurrentTimestamp(extra)
+}
+
+func normalizeCurrentTimestamp(s string) string {
+	return currentTimestampRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		parts := currentTimestampRegexp.FindStringSubmatch(match)
+		if parts[3] != "" && parts[3] != "0" {
+			return "CURRENT_TIMESTAMP(" + parts[3] + ")"
+		}
+		return "CURRENT_TIMESTAMP"
+	})
+}
+
+var (
+	serverVersionRegexp  = regexp.MustCompile(`^(?:5\.5\.5-)?(\d+)\.(\d+)\.(\d+)`)
+	numericDefaultRegexp = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+)
+
+// mariaDBDefaults reports whether the server of version, as VERSION() returns
+// it, quotes its literal defaults in information_schema and reports a NULL
+// default as the NULL keyword: MariaDB does since 10.2.7.
+func mariaDBDefaults(version string) bool {
+	if !strings.Contains(version, "MariaDB") {
+		return false
+	}
+	major, minor, patch, ok := serverVersion(version)
+	if !ok {
+		return true
+	}
+	return major > 10 || major == 10 && (minor > 2 || minor == 2 && patch >= 7)
+}
+
+// rowAlias reports whether the server of version names the inserted row with
+// an alias in INSERT ... ON DUPLICATE KEY UPDATE, deprecating VALUES(): MySQL
+// does since 8.0.20, MariaDB does not.
+func rowAlias(version string) bool {
+	if strings.Contains(version, "MariaDB") {
+		return false
+	}
+	major, minor, patch, ok := serverVersion(version)
+	return ok && (major > 8 || major == 8 && (minor > 0 || patch >= 20))
+}
+
+func serverVersion(version string) (major, minor, patch int, ok bool) {
+	parts := serverVersionRegexp.FindStringSubmatch(version)
+	if parts == nil {
+		return 0, 0, 0, false
+	}
+	major, _ = strconv.Atoi(parts[1])
+	minor, _ = strconv.Atoi(parts[2])
+	patch, _ = strconv.Atoi(parts[3])
+	return major, minor, patch, true
+}
+
+// mariaDBColumn is a column of MariaDB 10.2.7 or later as MySQL reports it:
+// the NULL keyword is no default, quoted literals are unquoted and the other
+// defaults, neither numbers, bit literals nor the current timestamp, are
+// expressions marked DEFAULT_GENERATED.
+func mariaDBColumn(scheme ColumnScheme) ColumnScheme {
+	value := scheme.ColumnDefault.String
+	switch {
+	case !scheme.ColumnDefault.Valid:
+	case "NULL" == value:
+		scheme.ColumnDefault = NullString{}
+	case len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'"):
+		scheme.ColumnDefault = NewNullString(unquoteLiteral(value[1 : len(value)-1]))
+	case numericDefaultRegexp.MatchString(value), bitLiteralRegexp.MatchString(value), timestampDefaultRegexp.MatchString(value):
+	default:
+		scheme.Extra = strings.TrimSpace("DEFAULT_GENERATED " + scheme.Extra)
+	}
+	return scheme
+}
+
+// unquoteLiteral reads the body of a quoted literal, escaped with doubled
+// quotes or backslashes.
+func unquoteLiteral(body string) string {
+	var buff strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\'' && i+1 < len(body) && body[i+1] == '\'':
+			i++
+		case c == '\\' && i+1 < len(body):
+			i++
+			switch c = body[i]; c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case '0':
+				c = 0
+			case 'Z':
+				c = 26
+			}
+		}
+		buff.WriteByte(c)
+	}
+	return buff.String()
+}
+
+// NormalizeCharset maps the utf8mb3 names of MySQL 8.0 back to utf8.
+func NormalizeCharset(name string) string {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "utf8mb3") {
+		return "utf8" + name[len("utf8mb3"):]
+	}
+	return name
+}
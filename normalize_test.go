@@ -0,0 +1,120 @@
+package dbdiff
+
+import (
+	"testing"
+)
+
+func TestNormalizer_NormalizeType(t *testing.T) {
+	normalizer := NewNormalizer()
+	cases := []struct {
+		input, expected string
+	}{
+		{"int(11)", "int"},
+		{"INT(10) UNSIGNED", "int unsigned"},
+		{"int(10) unsigned zerofill", "int(10) unsigned zerofill"},
+		{"bigint(20)", "bigint"},
+		{"tinyint(1)", "tinyint(1)"},
+		{"tinyint(4)", "tinyint"},
+		{"boolean", "tinyint(1)"},
+		{"integer", "int"},
+		{"numeric(12, 2)", "decimal(12,2)"},
+		{"decimal", "decimal(10,0)"},
+		{"decimal(8)", "decimal(8,0)"},
+		{"year(4)", "year"},
+		{"double precision", "double"},
+		{"varchar(64)", "varchar(64)"},
+	}
+	for i, c := range cases {
+		verify(t, i+1, "NormalizeType", c.input, normalizer.NormalizeType(c.input), c.expected)
+	}
+
+	keep := &Normalizer{KeepDisplayWidth: true}
+	verify(t, 20, "NormalizeType", "keep display width", keep.NormalizeType("int(11)"), "int(11)")
+}
+
+func TestNormalizer_NormalizeColumn(t *testing.T) {
+	var (
+		normalizer = NewNormalizer()
+		mysql57    = ColumnScheme{
+			ColumnType:       "timestamp",
+			ColumnDefault:    NewNullString("CURRENT_TIMESTAMP"),
+			Extra:            "on update CURRENT_TIMESTAMP",
+			CharacterSetName: "utf8",
+			CollationName:    "utf8_general_ci",
+		}
+		mysql80 = ColumnScheme{
+			ColumnType:       "timestamp",
+			ColumnDefault:    NewNullString("CURRENT_TIMESTAMP"),
+			Extra:            "DEFAULT_GENERATED on update CURRENT_TIMESTAMP",
+			CharacterSetName: "utf8mb3",
+			CollationName:    "utf8mb3_general_ci",
+		}
+		mariadb = ColumnScheme{
+			ColumnType:       "timestamp",
+			ColumnDefault:    NewNullString("current_timestamp()"),
+			Extra:            "on update current_timestamp()",
+			CharacterSetName: "utf8",
+			CollationName:    "utf8_general_ci",
+		}
+	)
+	verify(t, 1, "NormalizeColumn", "8.0", normalizer.NormalizeColumn(mysql80), normalizer.NormalizeColumn(mysql57))
+	verify(t, 2, "NormalizeColumn", "mariadb", normalizer.NormalizeColumn(mariadb), normalizer.NormalizeColumn(mysql57))
+
+	cases := []struct {
+		input, expected NullString
+	}{
+		{NewNullString("'it''s'"), NewNullString("'it''s'")},
+		{NewNullString("NULL"), NewNullString("NULL")},
+		{NewNullString("current_timestamp(3)"), NewNullString("CURRENT_TIMESTAMP(3)")},
+		{NewNullString("now()"), NewNullString("CURRENT_TIMESTAMP")},
+		{NewNullString("0.00"), NewNullString("0.00")},
+		{NewNullString(""), NewNullString("")},
+		{NullString{}, NullString{}},
+	}
+	for i, c := range cases {
+		verify(t, i+3, "NormalizeDefault", c.input, normalizer.NormalizeDefault(c.input), c.expected)
+	}
+
+	var (
+		left  = &Column{ColumnScheme: ColumnScheme{ColumnType: "int(11)", Extra: "auto_increment"}}
+		right = &Column{ColumnScheme: ColumnScheme{ColumnType: "int", Extra: "auto_increment"}}
+	)
+	verify(t, 10, "columnChanges", "normalized", len(columnChanges(left, right, normalizer)), 0)
+	verify(t, 11, "columnChanges", "raw", len(columnChanges(left, right, nil)), 1)
+}
+
+func TestMariaDBColumn(t *testing.T) {
+	versions := []struct {
+		version  string
+		expected bool
+	}{
+		{"8.0.36", false},
+		{"10.2.6-MariaDB-log", false},
+		{"10.2.7-MariaDB", true},
+		{"5.5.5-10.11.6-MariaDB-0+deb12u1", true},
+		{"11.4.2-MariaDB", true},
+	}
+	for i, c := range versions {
+		verify(t, i+1, "mariaDBDefaults", c.version, mariaDBDefaults(c.version), c.expected)
+	}
+
+	cases := []struct {
+		input, expected NullString
+		extra           string
+	}{
+		{NewNullString("'it''s'"), NewNullString("it's"), ""},
+		{NewNullString(`'C:\\tmp'`), NewNullString(`C:\tmp`), ""},
+		{NewNullString("NULL"), NullString{}, ""},
+		{NewNullString("'NULL'"), NewNullString("NULL"), ""},
+		{NewNullString("-1.5e3"), NewNullString("-1.5e3"), ""},
+		{NewNullString("b'101'"), NewNullString("b'101'"), ""},
+		{NewNullString("current_timestamp()"), NewNullString("current_timestamp()"), ""},
+		{NewNullString("uuid()"), NewNullString("uuid()"), "DEFAULT_GENERATED"},
+		{NullString{}, NullString{}, ""},
+	}
+	for i, c := range cases {
+		read := mariaDBColumn(ColumnScheme{ColumnDefault: c.input})
+		verify(t, i+10, "mariaDBColumn", c.input, read.ColumnDefault, c.expected)
+		verify(t, i+20, "mariaDBColumn", c.input, read.Extra, c.extra)
+	}
+}
@@ -0,0 +1,54 @@
+package dbdiff
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultParallelism is the number of tables NewDBDiff introspects at once on
+// each database, and so the number of connections it opens to each.
+const DefaultParallelism = 4
+
+// forEachParallel calls fn for the indexes 0 to n-1 on at most workers
+// goroutines. fn stores its result at its index, which keeps the order of the
+// results. The first error cancels the ctx given to the remaining calls,
+// which should then return early, and is returned once all of them finished.
+func forEachParallel(ctx context.Context, n, workers int, fn func(ctx context.Context, i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		indexes  = make(chan int)
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		firstErr error
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					mutex.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,56 @@
+package dbdiff
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEachParallel(t *testing.T) {
+	var (
+		results = make([]int, 100)
+		running int32
+		maxRun  int32
+	)
+	err := forEachParallel(context.Background(), len(results), 4, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRun)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRun, max, n) {
+				break
+			}
+		}
+		results[i] = i * i
+		return nil
+	})
+	verify(t, 1, "forEachParallel", "err", err, nil)
+	ordered := true
+	for i, result := range results {
+		ordered = ordered && result == i*i
+	}
+	verify(t, 2, "forEachParallel", "order", ordered, true)
+	verify(t, 3, "forEachParallel", "workers", maxRun <= 4, true)
+
+	//the first error cancels the calls still to come
+	failure := errors.New("table is corrupted")
+	var canceled int32
+	err = forEachParallel(context.Background(), 100, 2, func(ctx context.Context, i int) error {
+		if ctx.Err() != nil {
+			atomic.AddInt32(&canceled, 1)
+			return &CanceledError{Err: ctx.Err()}
+		}
+		if i == 3 {
+			return failure
+		}
+		return nil
+	})
+	verify(t, 4, "forEachParallel", "first error", err, failure)
+	verify(t, 5, "forEachParallel", "canceled", canceled > 0, true)
+
+	err = forEachParallel(context.Background(), 0, 4, func(ctx context.Context, i int) error {
+		return failure
+	})
+	verify(t, 6, "forEachParallel", "empty", err, nil)
+}
@@ -0,0 +1,269 @@
+package dbdiff
+
+import "strings"
+
+// Plan is an ordered list of statements to run against the new database.
+// Migration plans treat the old database as the source of truth, so applying
+// one makes the new database look like the old one.
+type Plan struct {
+	Statements []*PlanStatement
+	// Unsupported are the changes no statement applies, such as table options
+	// that are not migrated. Check refuses them like the others.
+	Unsupported []*Change
+}
+
+type PlanStatement struct {
+	Sql       string
+	TableName string
+	DDL       bool
+	// Change is the classified scheme change the statement applies, nil for
+	// data statements.
+	Change *Change
+}
+
+func NewPlan() *Plan {
+	return &Plan{}
+}
+
+func (plan *Plan) AddDDL(tableName string, sqls ...string) {
+	plan.add(tableName, true, nil, sqls)
+}
+
+func (plan *Plan) AddDML(tableName string, sqls ...string) {
+	plan.add(tableName, false, nil, sqls)
+}
+
+// addChange adds the statements applying change, without any the change is
+// unsupported.
+func (plan *Plan) addChange(change *Change, sqls ...string) {
+	if len(nonBlank(sqls...)) == 0 {
+		plan.Unsupported = append(plan.Unsupported, change)
+		return
+	}
+	plan.add(change.TableName, true, change, sqls)
+}
+
+func (plan *Plan) add(tableName string, ddl bool, change *Change, sqls []string) {
+	for _, sql := range nonBlank(sqls...) {
+		plan.Statements = append(plan.Statements, &PlanStatement{
+			Sql:       sql,
+			TableName: tableName,
+			DDL:       ddl,
+			Change:    change,
+		})
+	}
+}
+
+// append moves the statements and the unsupported changes of other after
+// those of plan.
+func (plan *Plan) append(other *Plan) {
+	plan.Statements = append(plan.Statements, other.Statements...)
+	plan.Unsupported = append(plan.Unsupported, other.Unsupported...)
+}
+
+func (plan *Plan) Sqls() []string {
+	sqls := make([]string, len(plan.Statements))
+	for i, statement := range plan.Statements {
+		sqls[i] = statement.Sql
+	}
+	return sqls
+}
+
+// Check refuses the plan when safety does not admit one of its changes.
+func (plan *Plan) Check(safety *SafetyOptions) error {
+	var (
+		changes = []*Change{}
+		seen    = make(map[*Change]bool)
+	)
+	for _, statement := range plan.Statements {
+		if statement.Change != nil && !seen[statement.Change] {
+			seen[statement.Change] = true
+			changes = append(changes, statement.Change)
+		}
+	}
+	for _, change := range plan.Unsupported {
+		if !seen[change] {
+			seen[change] = true
+			changes = append(changes, change)
+		}
+	}
+	return safety.Check(changes)
+}
+
+// NewMigrationPlan orders the DDL of a diff: tables are created first and
+// dropped last, and inside a table indexes are dropped before columns change
+// and added after. Lossy and blocking changes not admitted by safety fail the
+// plan with an UnsafeChangeError; a nil safety admits only safe changes.
+func NewMigrationPlan(diff *DiffDataBase, safety *SafetyOptions) (*Plan, error) {
+	var (
+		plan    = NewPlan()
+		creates = NewPlan()
+		drops   = NewPlan()
+		created = []*DiffTable{}
+	)
+	for _, diffTable := range diff.DiffTables {
+		switch {
+		case diffTable.TableNew == nil:
+			created = append(created, diffTable)
+		case diffTable.TableOld == nil:
+			drops.addChange(classifyTable(diffTable), diffTable.migrationSqls()...)
+		default:
+			plan.addAlterTable(diffTable)
+		}
+	}
+	creates.addCreateTables(created)
+
+	creates.append(plan)
+	creates.append(drops)
+	if err := creates.Check(safety); err != nil {
+		return nil, err
+	}
+	return creates, nil
+}
+
+// addCreateTables adds the tables created parents first, so that their
+// foreign keys reference existing tables. Tables referencing each other are
+// created with FOREIGN_KEY_CHECKS disabled.
+func (plan *Plan) addCreateTables(diffTables []*DiffTable) {
+	tables := make([]*Table, len(diffTables))
+	for i, diffTable := range diffTables {
+		tables[i] = diffTable.TableOld
+	}
+	order, cyclic := sortTablesByForeignKeys(tables)
+	if cyclic {
+		plan.AddDML("", "SET FOREIGN_KEY_CHECKS=0")
+	}
+	for _, index := range order {
+		plan.addChange(classifyTable(diffTables[index]), diffTables[index].migrationSqls()...)
+	}
+	if cyclic {
+		plan.AddDML("", "SET FOREIGN_KEY_CHECKS=1")
+	}
+}
+
+// addAlterTable adds the statements of a table in both databases: the table
+// options first, then the indexes are dropped, the columns added and
+// modified, the columns dropped and the indexes added.
+func (plan *Plan) addAlterTable(diffTable *DiffTable) {
+	var (
+		tableName   = diffTable.TableName
+		dropColumns = NewPlan()
+		addIndexes  = NewPlan()
+	)
+	if change := classifyTable(diffTable); change != nil {
+		plan.addChange(change, diffTable.migrationSqls()...)
+	}
+	for _, diffIndex := range diffTable.DiffIndex {
+		change := classifyIndex(tableName, diffIndex)
+		switch drop, add := diffIndex.dropSqls(), diffIndex.addSqls(); {
+		case len(add) == 0:
+			plan.addChange(change, drop...)
+		case len(drop) == 0:
+			addIndexes.addChange(change, add...)
+		default:
+			plan.addChange(change, drop...)
+			addIndexes.addChange(change, add...)
+		}
+	}
+	for _, diffColumn := range diffTable.DiffColumns {
+		change := classifyColumn(tableName, diffColumn)
+		if diffColumn.ItemOld == nil {
+			dropColumns.addChange(change, diffColumn.migrationSqls()...)
+		} else {
+			plan.addChange(change, diffColumn.migrationSqls()...)
+		}
+	}
+	plan.append(dropColumns)
+	plan.append(addIndexes)
+	plan.Unsupported = append(plan.Unsupported, classifyForeignKeys(diffTable)...)
+}
+
+// migrationSqls are the statements of a migration plan that create, drop or
+// alter the table, those of its columns and indexes are on their own.
+func (diffTable *DiffTable) migrationSqls() []string {
+	switch {
+	case diffTable.TableNew == nil:
+		return nonBlank(diffTable.TableOld.CreateTableSql)
+	case diffTable.TableOld == nil:
+		return nonBlank(diffTable.TableNew.DropTableSql)
+	}
+	return nonBlank(alterTableSql(diffTable.TableOld.TableScheme, diffTable.Changes))
+}
+
+func (diffColumn *DiffColumn) migrationSqls() []string {
+	switch {
+	case diffColumn.ItemNew == nil:
+		return nonBlank(diffColumn.ItemOld.AddColumnSql)
+	case diffColumn.ItemOld == nil:
+		return nonBlank(diffColumn.ItemNew.DropColumnSql)
+	}
+	//MODIFY COLUMN cannot make a column virtual nor turn a virtual one into
+	//another kind
+	from, to := columnGeneration(diffColumn.ItemNew.Extra), columnGeneration(diffColumn.ItemOld.Extra)
+	if from != to && (from == "VIRTUAL" || to == "VIRTUAL") {
+		return nil
+	}
+	return nonBlank(diffColumn.ItemOld.ModifyColumnSql)
+}
+
+// migrationSqls drop the index of the new database, then add the one of the
+// old database.
+func (diffIndex *DiffIndex) migrationSqls() []string {
+	return append(diffIndex.dropSqls(), diffIndex.addSqls()...)
+}
+
+func (diffIndex *DiffIndex) dropSqls() []string {
+	if diffIndex.ItemNew == nil {
+		return []string{}
+	}
+	return nonBlank(diffIndex.ItemNew.DropIndexSql)
+}
+
+func (diffIndex *DiffIndex) addSqls() []string {
+	if diffIndex.ItemOld == nil {
+		return []string{}
+	}
+	return nonBlank(diffIndex.ItemOld.AddIndexSql)
+}
+
+// alterTableSql changes the options of a table to those of tableScheme, for
+// the engine, row format, collation and comment among changes. The other
+// options are not migrated, see classifyTable.
+func alterTableSql(tableScheme TableScheme, changes []*AttrChange) string {
+	options := []string{}
+	for _, change := range changes {
+		switch change.Name {
+		case AttrEngine:
+			options = append(options, "ENGINE="+tableScheme.Engine)
+		case AttrRowFormat:
+			options = append(options, "ROW_FORMAT="+strings.ToUpper(tableScheme.RowFormat))
+		case AttrCollation:
+			collation := tableScheme.TableCollation
+			options = append(options, "DEFAULT CHARSET="+collationCharset(collation)+" COLLATE="+collation)
+		case AttrComment:
+			options = append(options, "COMMENT="+quoteText(tableScheme.TableComment))
+		}
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return "ALTER TABLE " + quoteIdent(tableScheme.TableName) + " " + strings.Join(options, ", ")
+}
+
+func nonBlank(sqls ...string) []string {
+	kept := []string{}
+	for _, sql := range sqls {
+		if !AssertStrBlank(sql) {
+			kept = append(kept, sql)
+		}
+	}
+	return kept
+}
+
+func NewDataSyncPlan(diffs []*DiffTableData, opts *DataSyncOptions) *Plan {
+	plan := NewPlan()
+	for _, statement := range generateDataSync(diffs, opts) {
+		plan.AddDML(statement.tableName, statement.sql)
+	}
+	return plan
+}
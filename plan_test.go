@@ -0,0 +1,161 @@
+package dbdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewMigrationPlan(t *testing.T) {
+	var (
+		columnOld = &Column{AddColumnSql: "add age", ModifyColumnSql: "modify name"}
+		columnNew = &Column{DropColumnSql: "drop name", ModifyColumnSql: "modify name new"}
+		indexOld  = &Index{AddIndexSql: "add idx"}
+		indexNew  = &Index{DropIndexSql: "drop idx"}
+		diff      = &DiffDataBase{
+			DiffTables: []*DiffTable{
+				{TableName: "gone", TableNew: &Table{DropTableSql: "drop gone"}},
+				{
+					TableName: "student",
+					TableOld:  &Table{},
+					TableNew:  &Table{},
+					DiffColumns: []*DiffColumn{
+						{ItemNew: columnNew},
+						{ItemOld: columnOld},
+						{ItemOld: columnOld, ItemNew: columnNew},
+					},
+					DiffIndex: []*DiffIndex{{ItemOld: indexOld, ItemNew: indexNew}},
+				},
+				{TableName: "created", TableOld: &Table{CreateTableSql: "create created"}},
+			},
+		}
+	)
+	plan, err := NewMigrationPlan(diff, &SafetyOptions{AllowDestructive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"create created", "drop idx", "add age", "modify name", "drop name", "add idx", "drop gone"}
+	sqls := plan.Sqls()
+	verify(t, 1, "NewMigrationPlan", "size", len(sqls), len(expected))
+	for i := 0; i < len(expected) && i < len(sqls); i++ {
+		verify(t, i+2, "NewMigrationPlan", i, sqls[i], expected[i])
+	}
+	verify(t, 10, "NewMigrationPlan", "ddl", plan.Statements[0].DDL, true)
+}
+
+func TestNewMigrationPlan_tableOptions(t *testing.T) {
+	var (
+		tableOld = &Table{TableScheme: TableScheme{TableName: "student", Engine: "InnoDB", TableCollation: "utf8mb4_general_ci",
+			TableComment: "who's enrolled", CreateOptions: "row_format=DYNAMIC stats_persistent=0"}}
+		tableNew = &Table{TableScheme: TableScheme{TableName: "student", Engine: "MyISAM", TableCollation: "utf8mb4_bin",
+			CreateOptions: "row_format=COMPACT"}}
+		diffTable = &DiffTable{TableName: "student", TableOld: tableOld, TableNew: tableNew, Changes: tableChanges(tableOld, tableNew, nil)}
+		diff      = &DiffDataBase{DiffTables: []*DiffTable{diffTable}}
+	)
+	_, err := NewMigrationPlan(diff, nil)
+	verify(t, 1, "NewMigrationPlan", "create options", err.Error(),
+		"refused 1 destructive changes: modified table student (blocking): changes create options from \"row_format=COMPACT\" to \"row_format=DYNAMIC stats_persistent=0\", not migrated")
+
+	plan, err := NewMigrationPlan(diff, &SafetyOptions{AllowList: []string{"student"}})
+	verify(t, 2, "NewMigrationPlan", "err", err, nil)
+	verify(t, 3, "NewMigrationPlan", "alter table", strings.Join(plan.Sqls(), ";"),
+		"ALTER TABLE `student` ENGINE=InnoDB, DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci, COMMENT='who''s enrolled'")
+	verify(t, 4, "NewMigrationPlan", "unsupported", len(plan.Unsupported), 0)
+
+	//the row format alone is migrated
+	tableOld.CreateOptions = "row_format=DYNAMIC"
+	tableOld.RowFormat, tableNew.RowFormat = "Dynamic", "Compact"
+	diffTable.Changes = tableChanges(tableOld, tableNew, nil)
+	plan, err = NewMigrationPlan(diff, nil)
+	verify(t, 5, "NewMigrationPlan", "err", err, nil)
+	verify(t, 6, "NewMigrationPlan", "row format", plan.Statements[0].Sql,
+		"ALTER TABLE `student` ENGINE=InnoDB, ROW_FORMAT=DYNAMIC, DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci, COMMENT='who''s enrolled'")
+
+	//auto increment counters are left out, and not applied when a rule compares them
+	tableOld.AutoIncrement, tableNew.AutoIncrement = "10", "20"
+	verify(t, 7, "tableChanges", "auto increment", tableChanges(tableOld, tableNew, nil)[0].Name != AttrAutoIncrement, true)
+	diffTable.Changes = []*AttrChange{{Name: AttrAutoIncrement, Old: "10", New: "20"}}
+	plan, _ = NewMigrationPlan(diff, nil)
+	verify(t, 8, "NewMigrationPlan", "auto increment", len(plan.Statements), 0)
+	verify(t, 9, "NewMigrationPlan", "auto increment", len(plan.Unsupported), 1)
+}
+
+func TestNewMigrationPlan_foreignKeys(t *testing.T) {
+	var (
+		table = func(name, createSql string, foreignKeys ...*ForeignKey) *Table {
+			return &Table{TableScheme: TableScheme{TableName: name}, CreateTableSql: createSql, ForeignKeyList: foreignKeys}
+		}
+		references = func(tableName, referenced string) *ForeignKey {
+			return &ForeignKey{TableName: tableName, ConstraintName: "fk_" + referenced, Columns: []string{referenced + "_id"},
+				ReferencedTable: referenced, ReferencedColumns: []string{"id"}}
+		}
+		diff = &DiffDataBase{
+			DiffTables: []*DiffTable{
+				{TableName: "enrollment", TableOld: table("enrollment", "create enrollment",
+					references("enrollment", "course"), references("enrollment", "student"))},
+				{TableName: "course", TableOld: table("course", "create course", references("course", "course"))},
+				{TableName: "student", TableOld: table("student", "create student")},
+			},
+		}
+	)
+	plan, err := NewMigrationPlan(diff, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "NewMigrationPlan", "parents first", strings.Join(plan.Sqls(), ";"), "create course;create student;create enrollment")
+
+	diff.DiffTables = []*DiffTable{
+		{TableName: "a", TableOld: table("a", "create a", references("a", "b"))},
+		{TableName: "b", TableOld: table("b", "create b", references("b", "a"))},
+	}
+	plan, _ = NewMigrationPlan(diff, nil)
+	verify(t, 2, "NewMigrationPlan", "cycle", strings.Join(plan.Sqls(), ";"),
+		"SET FOREIGN_KEY_CHECKS=0;create a;create b;SET FOREIGN_KEY_CHECKS=1")
+
+	changed := references("enrollment", "student")
+	changed.ReferencedColumns = []string{"code"}
+	diff.DiffTables = []*DiffTable{{
+		TableName: "enrollment",
+		TableOld:  table("enrollment", "", references("enrollment", "course"), changed),
+		TableNew:  table("enrollment", "", references("enrollment", "student"), references("enrollment", "term")),
+	}}
+	_, err = NewMigrationPlan(diff, nil)
+	_, ok := err.(*UnsafeChangeError)
+	verify(t, 3, "NewMigrationPlan", "foreign keys refused", ok, true)
+	plan, _ = NewMigrationPlan(diff, &SafetyOptions{AllowList: []string{"enrollment.fk_*"}})
+	changes := []string{}
+	for _, change := range plan.Unsupported {
+		changes = append(changes, string(change.Kind)+" "+change.QualifiedName())
+	}
+	verify(t, 4, "NewMigrationPlan", "foreign keys", strings.Join(changes, ","),
+		"added enrollment.fk_course,modified enrollment.fk_student,removed enrollment.fk_term")
+}
+
+func TestNewMigrationPlan_generatedColumns(t *testing.T) {
+	column := func(extra, expression string) *Column {
+		column := &Column{ColumnScheme: ColumnScheme{TableName: "line", ColumnName: "total", ColumnType: "int", NullAble: "YES",
+			Extra: extra, GenerationExpression: expression}}
+		column.fillModifyColumnSql()
+		return column
+	}
+	diffColumn := func(old, new *Column) *DiffDataBase {
+		diff := &DiffColumn{ItemOld: old, ItemNew: new}
+		diff.Changes = columnChanges(old, new, NewNormalizer())
+		return &DiffDataBase{DiffTables: []*DiffTable{{TableName: "line", TableOld: &Table{}, TableNew: &Table{}, DiffColumns: []*DiffColumn{diff}}}}
+	}
+
+	plan, err := NewMigrationPlan(diffColumn(column("VIRTUAL GENERATED", "(`price` * 2)"), column("VIRTUAL GENERATED", "(`price` * 3)")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "NewMigrationPlan", "expression", strings.Join(plan.Sqls(), ";"),
+		"ALTER TABLE `line` MODIFY COLUMN `total` int GENERATED ALWAYS AS ((`price` * 2)) VIRTUAL")
+
+	_, err = NewMigrationPlan(diffColumn(column("STORED GENERATED", "(`price` * 2)"), column("STORED GENERATED", "(`price` * 3)")), nil)
+	_, ok := err.(*UnsafeChangeError)
+	verify(t, 2, "NewMigrationPlan", "stored expression", ok, true)
+
+	for i, old := range []*Column{column("VIRTUAL GENERATED", "(`price` * 2)"), column("VIRTUAL GENERATED", "")} {
+		plan, _ = NewMigrationPlan(diffColumn(old, column("", "")), &SafetyOptions{AllowList: []string{"line.total"}})
+		verify(t, 3+i, "NewMigrationPlan", "unsupported", len(plan.Statements) == 0 && len(plan.Unsupported) == 1, true)
+	}
+}
@@ -0,0 +1,228 @@
+package dbdiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type ReportFormat string
+
+const (
+	FormatText     ReportFormat = "text"
+	FormatJSON     ReportFormat = "json"
+	FormatMarkdown ReportFormat = "markdown"
+	FormatHTML     ReportFormat = "html"
+	FormatJUnit    ReportFormat = "junit"
+	FormatSARIF    ReportFormat = "sarif"
+)
+
+// ReportFormats lists the formats WriteReport renders.
+var ReportFormats = []ReportFormat{FormatText, FormatJSON, FormatMarkdown, FormatHTML, FormatJUnit, FormatSARIF}
+
+type ReportOptions struct {
+	Format ReportFormat
+	// Color highlights the text report with ANSI escapes, for terminals.
+	Color bool
+	// SARIFArtifact is the file SARIF results point at, required by
+	// FormatSARIF since code scanning tools drop results without one.
+	SARIFArtifact string
+}
+
+// WriteReport renders the differences of diff in format.
+func WriteReport(w io.Writer, diff *DiffDataBase, format ReportFormat) error {
+	return WriteReportWith(w, diff, &ReportOptions{Format: format})
+}
+
+func WriteReportWith(w io.Writer, diff *DiffDataBase, opts *ReportOptions) error {
+	switch format := opts.Format; format {
+	case FormatText, "":
+		return writeTextReport(w, diff, opts.Color)
+	case FormatJSON:
+		return writeJSONReport(w, diff)
+	case FormatMarkdown:
+		return writeMarkdownReport(w, diff)
+	case FormatHTML:
+		return writeHTMLReport(w, diff)
+	case FormatJUnit:
+		return writeJUnitReport(w, diff)
+	case FormatSARIF:
+		return writeSARIFReport(w, diff, opts.SARIFArtifact)
+	}
+	return &ReportError{Format: string(opts.Format), Message: "unknown format"}
+}
+
+// reportItem is a change with the attribute changes that caused it and the
+// statements that migrate it.
+type reportItem struct {
+	change  *Change
+	attrs   []*AttrChange
+	members *MemberChange
+	sqls    []string
+}
+
+// reportTable groups the changes of one table, table is nil when the table
+// itself did not change.
+type reportTable struct {
+	name      string
+	diffTable *DiffTable
+	table     *reportItem
+	items     []*reportItem
+}
+
+// all lists the table change first, then the changes of its columns and
+// indexes.
+func (table *reportTable) all() []*reportItem {
+	if table.table == nil {
+		return table.items
+	}
+	return append([]*reportItem{table.table}, table.items...)
+}
+
+func (table *reportTable) severity() Severity {
+	severity := SeveritySafe
+	for _, item := range table.all() {
+		if item.change.Severity > severity {
+			severity = item.change.Severity
+		}
+	}
+	return severity
+}
+
+func reportTables(diff *DiffDataBase) []*reportTable {
+	tables := []*reportTable{}
+	for _, diffTable := range diff.DiffTables {
+		table := &reportTable{name: diffTable.TableName, diffTable: diffTable}
+		if change := classifyTable(diffTable); change != nil {
+			table.table = &reportItem{change: change, attrs: diffTable.Changes, sqls: diffTable.migrationSqls()}
+		}
+		if diffTable.TableOld != nil && diffTable.TableNew != nil {
+			for _, diffColumn := range diffTable.DiffColumns {
+				table.items = append(table.items, &reportItem{
+					change:  classifyColumn(diffTable.TableName, diffColumn),
+					attrs:   diffColumn.Changes,
+					members: diffColumn.Members,
+					sqls:    diffColumn.migrationSqls(),
+				})
+			}
+			for _, diffIndex := range diffTable.DiffIndex {
+				table.items = append(table.items, &reportItem{
+					change: classifyIndex(diffTable.TableName, diffIndex),
+					attrs:  diffIndex.Changes,
+					sqls:   diffIndex.migrationSqls(),
+				})
+			}
+		}
+		if table.table != nil || len(table.items) != 0 || diffTable.CreateTableDiff != "" {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+func reportOptions(diff *DiffDataBase) []*reportItem {
+	options := []*reportItem{}
+	for _, diffOption := range diff.DiffOptions {
+		options = append(options, &reportItem{change: classifyOption(diffOption), attrs: diffOption.Changes})
+	}
+	return options
+}
+
+func writeTextReport(w io.Writer, diff *DiffDataBase, color bool) error {
+	var (
+		out     = bufio.NewWriter(w)
+		summary = summarize(diff)
+		tables  = reportTables(diff)
+	)
+	if summary.changes == 0 && len(tables) == 0 {
+		fmt.Fprintln(out, "no differences")
+	}
+	for _, table := range tables {
+		if table.table != nil && len(table.items) == 0 && table.diffTable.CreateTableDiff == "" {
+			writeTextItem(out, "", table.table)
+			continue
+		}
+		fmt.Fprintf(out, "table %s\n", table.name)
+		for _, item := range table.all() {
+			writeTextItem(out, "  ", item)
+		}
+		writeTextDiff(out, "    ", table.diffTable.CreateTableDiff, color)
+	}
+	for _, option := range reportOptions(diff) {
+		writeTextItem(out, "", option)
+	}
+
+	if summary.changes != 0 {
+		fmt.Fprintf(out, "%d changes: %d safe, %d lossy, %d blocking", summary.changes,
+			summary.severities[SeveritySafe], summary.severities[SeverityLossy], summary.severities[SeverityBlocking])
+		if summary.suppressed != 0 {
+			fmt.Fprintf(out, "; %d suppressed", summary.suppressed)
+		}
+		fmt.Fprintln(out)
+	}
+	return out.Flush()
+}
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+	ansiBold  = "\x1b[1m"
+	ansiReset = "\x1b[0m"
+)
+
+// writeTextDiff prints a unified diff, colored as git does when color is set.
+func writeTextDiff(out io.Writer, indent, unifiedDiff string, color bool) {
+	for _, line := range splitLines(unifiedDiff) {
+		prefix := ""
+		if color {
+			switch {
+			case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+				prefix = ansiBold
+			case strings.HasPrefix(line, "@@"):
+				prefix = ansiCyan
+			case strings.HasPrefix(line, "-"):
+				prefix = ansiRed
+			case strings.HasPrefix(line, "+"):
+				prefix = ansiGreen
+			}
+		}
+		if prefix != "" {
+			fmt.Fprintf(out, "%s%s%s%s\n", indent, prefix, line, ansiReset)
+		} else {
+			fmt.Fprintf(out, "%s%s\n", indent, line)
+		}
+	}
+}
+
+func writeTextItem(out io.Writer, indent string, item *reportItem) {
+	fmt.Fprintf(out, "%s%s\n", indent, item.change)
+	for _, attr := range item.attrs {
+		fmt.Fprintf(out, "%s    %s: old %q, new %q\n", indent, attr.Name, attr.Old, attr.New)
+	}
+	if item.members != nil {
+		fmt.Fprintf(out, "%s    members: %s\n", indent, item.members)
+	}
+}
+
+type reportSummary struct {
+	changes    int
+	suppressed int
+	severities map[Severity]int
+	kinds      map[ChangeKind]int
+}
+
+func summarize(diff *DiffDataBase) *reportSummary {
+	summary := &reportSummary{
+		suppressed: len(diff.Suppressed),
+		severities: map[Severity]int{},
+		kinds:      map[ChangeKind]int{},
+	}
+	for _, change := range Classify(diff) {
+		summary.changes++
+		summary.severities[change.Severity]++
+		summary.kinds[change.Kind]++
+	}
+	return summary
+}
@@ -0,0 +1,238 @@
+package dbdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteTextReport(t *testing.T) {
+	var (
+		old  = classifyTestColumn("varchar(64)", "YES", "utf8")
+		new  = classifyTestColumn("varchar(255)", "YES", "utf8")
+		diff = &DiffDataBase{DiffTables: []*DiffTable{
+			{TableName: "gone", TableNew: &Table{}},
+			{
+				TableName: "student",
+				TableOld:  &Table{},
+				TableNew:  &Table{},
+				DiffColumns: []*DiffColumn{
+					{ItemOld: old, ItemNew: new, Changes: columnChanges(old, new, nil)},
+				},
+			},
+		}}
+		buff bytes.Buffer
+	)
+	if err := WriteReport(&buff, diff, FormatText); err != nil {
+		t.Fatal(err)
+	}
+	expected := `removed table gone (lossy): drops table
+table student
+  modified column student.name (lossy): narrows varchar(255) to varchar(64)
+      type: old "varchar(64)", new "varchar(255)"
+2 changes: 0 safe, 2 lossy, 0 blocking
+`
+	verify(t, 1, "WriteReport", "text", buff.String(), expected)
+
+	buff.Reset()
+	WriteReport(&buff, &DiffDataBase{}, FormatText)
+	verify(t, 2, "WriteReport", "empty", buff.String(), "no differences\n")
+
+	_, ok := WriteReport(&buff, diff, "xml").(*ReportError)
+	verify(t, 3, "WriteReport", "xml", ok, true)
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	var (
+		old  = classifyTestColumn("enum('a','c')", "YES", "utf8")
+		new  = classifyTestColumn("enum('a','b','c')", "YES", "utf8")
+		diff = &DiffDataBase{
+			DiffTables: []*DiffTable{{
+				TableName: "student",
+				TableOld:  &Table{},
+				TableNew:  &Table{},
+				DiffColumns: []*DiffColumn{{
+					ItemOld: &Column{ColumnScheme: old.ColumnScheme, ModifyColumnSql: "ALTER TABLE student MODIFY COLUMN name enum('a','c')"},
+					ItemNew: new,
+					Changes: columnChanges(old, new, nil),
+					Members: DiffMembers(new.ColumnType, old.ColumnType),
+				}},
+			}},
+			DiffOptions: []*DiffOption{{
+				ItemOld: &Variable{VariableScheme{VariableName: "sql_mode", Value: "STRICT_TRANS_TABLES"}},
+				ItemNew: &Variable{VariableScheme{VariableName: "sql_mode", Value: ""}},
+				Changes: []*AttrChange{{Name: AttrValue, Old: "STRICT_TRANS_TABLES"}},
+			}},
+			Suppressed: []*Suppression{{Rule: "rule-1", ObjectType: ObjectTable, TableName: "student", Name: "student", Attribute: AttrAutoIncrement, Old: "10", New: "20"}},
+		}
+		buff bytes.Buffer
+	)
+	if err := WriteReport(&buff, diff, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	report := &JSONReport{}
+	if err := json.Unmarshal(buff.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "JSONReport", "version", report.Version, JSONReportVersion)
+	verify(t, 2, "JSONReport", "summary", *report.Summary, JSONSummary{Changes: 2, Safe: 1, Lossy: 1, Suppressed: 1})
+	verify(t, 3, "JSONReport", "changes", len(report.Changes), 2)
+
+	column := report.Changes[0]
+	verify(t, 4, "JSONReport", "kind", column.Kind, ChangeModified)
+	verify(t, 5, "JSONReport", "qualified_name", column.QualifiedName, "student.name")
+	verify(t, 6, "JSONReport", "severity", column.Severity, "lossy")
+	verify(t, 7, "JSONReport", "attribute", *column.Attributes[0], JSONAttribute{Name: AttrType, Old: "enum('a','c')", New: "enum('a','b','c')"})
+	verify(t, 8, "JSONReport", "members", strings.Join(column.Members.Removed, ","), "b")
+	verify(t, 9, "JSONReport", "sql", strings.Join(column.Sql, ";"), "ALTER TABLE student MODIFY COLUMN name enum('a','c')")
+
+	option := report.Changes[1]
+	verify(t, 10, "JSONReport", "option", option.QualifiedName, "sql_mode")
+	verify(t, 11, "JSONReport", "option table", option.Table, "")
+	verify(t, 12, "JSONReport", "option sql", len(option.Sql), 0)
+	verify(t, 13, "JSONReport", "suppressed", report.Suppressed[0].Attribute, AttrAutoIncrement)
+
+	//empty lists are written as [] rather than null
+	buff.Reset()
+	WriteReport(&buff, &DiffDataBase{}, FormatJSON)
+	verify(t, 14, "JSONReport", "empty", strings.Contains(buff.String(), `"changes": []`), true)
+}
+
+func reportTestDiff() *DiffDataBase {
+	var (
+		old = classifyTestColumn("varchar(64)", "YES", "utf8")
+		new = classifyTestColumn("varchar(255)", "YES", "utf8")
+	)
+	old.ColumnComment = "a|b <c>"
+	return &DiffDataBase{DiffTables: []*DiffTable{{
+		TableName: "student",
+		TableOld:  &Table{CreateTableSql: "CREATE TABLE `student` (\n  `name` varchar(64)\n)"},
+		TableNew:  &Table{CreateTableSql: "CREATE TABLE `student` (\n  `name` varchar(255)\n)"},
+		DiffColumns: []*DiffColumn{{
+			ItemOld: &Column{ColumnScheme: old.ColumnScheme, ModifyColumnSql: "ALTER TABLE student MODIFY COLUMN name varchar(64)"},
+			ItemNew: new,
+			Changes: columnChanges(old, new, nil),
+		}},
+	}}}
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	var buff bytes.Buffer
+	if err := WriteReport(&buff, reportTestDiff(), FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	report := buff.String()
+	for i, expected := range []string{
+		"**1 changes**: 0 added, 0 removed, 1 modified; 0 safe, 1 lossy, 0 blocking",
+		"<summary><b>student</b>: 1 changes (lossy)</summary>",
+		"- **modified column** `student.name` (lossy): narrows varchar(255) to varchar(64)",
+		"  | type | `varchar(64)` | `varchar(255)` |",
+		"  | comment | `a\\|b <c>` |  |",
+		"  ```sql\n  ALTER TABLE student MODIFY COLUMN name varchar(64);\n  ```",
+		"</details>",
+	} {
+		verify(t, i+1, "WriteReport", "markdown", strings.Contains(report, expected), true)
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	var buff bytes.Buffer
+	if err := WriteReport(&buff, reportTestDiff(), FormatHTML); err != nil {
+		t.Fatal(err)
+	}
+	report := buff.String()
+	for i, expected := range []string{
+		"<span><b>1</b> changes</span>",
+		`<span class="lossy">1 lossy</span>`,
+		`<div class="change" data-kind="modified">`,
+		"<td>comment</td><td>a|b &lt;c&gt;</td>",
+		`<span class="changed">  ` + "`name`" + ` varchar(64)</span>`,
+		"<span>CREATE TABLE `student` (</span>",
+	} {
+		verify(t, i+1, "WriteReport", "html", strings.Contains(report, expected), true)
+	}
+}
+
+func TestWriteTextReportCreateTableDiff(t *testing.T) {
+	var (
+		diff = &DiffDataBase{DiffTables: []*DiffTable{{
+			TableName:       "student",
+			TableOld:        &Table{},
+			TableNew:        &Table{},
+			CreateTableDiff: "--- old/student\n+++ new/student\n@@ -1 +1 @@\n-a\n+b\n",
+		}}}
+		buff bytes.Buffer
+	)
+	WriteReportWith(&buff, diff, &ReportOptions{Format: FormatText, Color: true})
+	expected := "table student\n" +
+		"    \x1b[1m--- old/student\x1b[0m\n" +
+		"    \x1b[1m+++ new/student\x1b[0m\n" +
+		"    \x1b[36m@@ -1 +1 @@\x1b[0m\n" +
+		"    \x1b[31m-a\x1b[0m\n" +
+		"    \x1b[32m+b\x1b[0m\n"
+	verify(t, 1, "WriteReport", "color", buff.String(), expected)
+
+	buff.Reset()
+	WriteReport(&buff, diff, FormatJSON)
+	report := &JSONReport{}
+	json.Unmarshal(buff.Bytes(), report)
+	verify(t, 2, "WriteReport", "json", report.CreateTableDiffs[0].Diff, diff.DiffTables[0].CreateTableDiff)
+	verify(t, 3, "WriteReport", "json", report.Summary.Changes, 0)
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	diff := reportTestDiff()
+	diff.DiffTables = append(diff.DiffTables, &DiffTable{TableName: "teacher", TableOld: &Table{}, TableNew: &Table{}})
+	diff.Suppressed = []*Suppression{{Rule: "rule-1", ObjectType: ObjectTable, TableName: "teacher", Name: "teacher", Attribute: AttrAutoIncrement, Old: "10", New: "20"}}
+	var buff bytes.Buffer
+	if err := WriteReport(&buff, diff, FormatJUnit); err != nil {
+		t.Fatal(err)
+	}
+	suites := &junitTestSuites{}
+	if err := xml.Unmarshal(buff.Bytes(), suites); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "JUnitReport", "tests", suites.Tests, 2)
+	verify(t, 2, "JUnitReport", "failures", suites.Failures, 1)
+
+	cases := suites.Suites[0].Cases
+	verify(t, 3, "JUnitReport", "student", cases[0].Name, "student")
+	verify(t, 4, "JUnitReport", "message", cases[0].Failure.Message, "1 changes (lossy)")
+	verify(t, 5, "JUnitReport", "text", strings.HasPrefix(cases[0].Failure.Text, "modified column student.name (lossy)"), true)
+	verify(t, 6, "JUnitReport", "teacher", cases[1].Failure == nil, true)
+	verify(t, 7, "JUnitReport", "suppressed", cases[1].SystemOut, diff.Suppressed[0].String()+"\n")
+}
+
+func TestWriteSARIFReport(t *testing.T) {
+	diff := reportTestDiff()
+	diff.Suppressed = []*Suppression{{Rule: "rule-1", ObjectType: ObjectTable, TableName: "student", Name: "student", Attribute: AttrAutoIncrement, Old: "10", New: "20"}}
+	var buff bytes.Buffer
+	if err := WriteReportWith(&buff, diff, &ReportOptions{Format: FormatSARIF, SARIFArtifact: "migrations/001.sql"}); err != nil {
+		t.Fatal(err)
+	}
+	log := &sarifLog{}
+	if err := json.Unmarshal(buff.Bytes(), log); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "SARIFReport", "version", log.Version, "2.1.0")
+	run := log.Runs[0]
+	verify(t, 2, "SARIFReport", "rules", len(run.Tool.Driver.Rules), 2)
+	verify(t, 3, "SARIFReport", "results", len(run.Results), 2)
+
+	result := run.Results[0]
+	verify(t, 4, "SARIFReport", "ruleId", result.RuleId, "column-modified")
+	verify(t, 5, "SARIFReport", "level", result.Level, "warning")
+	verify(t, 6, "SARIFReport", "location", result.Locations[0].LogicalLocations[0].FullyQualifiedName, "student.name")
+	verify(t, 7, "SARIFReport", "artifact", result.Locations[0].PhysicalLocation.ArtifactLocation.Uri, "migrations/001.sql")
+
+	suppressed := run.Results[1]
+	verify(t, 8, "SARIFReport", "suppressed", suppressed.RuleId, "table-modified")
+	verify(t, 9, "SARIFReport", "suppression", suppressed.Suppressions[0].Justification, "ignored by rule rule-1")
+	verify(t, 10, "SARIFReport", "suppressed artifact", suppressed.Locations[0].PhysicalLocation.ArtifactLocation.Uri, "migrations/001.sql")
+
+	err := WriteReport(&buff, diff, FormatSARIF)
+	_, ok := err.(*ReportError)
+	verify(t, 11, "SARIFReport", "missing artifact", ok, true)
+}
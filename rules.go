@@ -0,0 +1,188 @@
+package dbdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const AttrAll = "*"
+
+// Rule suppresses differences. A rule matches an item when every non-empty
+// selector matches: ObjectTypes, Tables and Names (patterns as in Filter) and
+// Environments. Ignore lists the attributes it suppresses, "*" suppresses the
+// whole item including its addition or removal. EquivalentTypes lists column
+// types that compare equal, such as "int(11)" and "int". Compare lists the
+// attributes left out of the diff unless a rule asks for them, the
+// AUTO_INCREMENT counter of tables.
+type Rule struct {
+	Name            string
+	ObjectTypes     []ObjectType
+	Tables          []string
+	Names           []string
+	Environments    []string
+	Ignore          []string
+	EquivalentTypes []string
+	Compare         []string
+
+	tables []*namePattern
+	names  []*namePattern
+}
+
+type RuleSet struct {
+	// Environment selects the rules limited to environments, rules without
+	// environments always apply.
+	Environment string
+	Rules       []*Rule
+}
+
+// Suppression records a difference a rule hid from the diff. Attribute is
+// "*" when the addition or removal of the whole item was suppressed.
+type Suppression struct {
+	Rule       string
+	ObjectType ObjectType
+	TableName  string
+	Name       string
+	Attribute  string
+	Old        string
+	New        string
+}
+
+func (suppression *Suppression) String() string {
+	name := suppression.Name
+	if suppression.ObjectType != ObjectTable && suppression.ObjectType != ObjectOption {
+		name = suppression.TableName + "." + name
+	}
+	if suppression.Attribute == AttrAll {
+		return fmt.Sprintf("%s %s suppressed by rule %s", suppression.ObjectType, name, suppression.Rule)
+	}
+	return fmt.Sprintf("%s %s %s %q -> %q suppressed by rule %s", suppression.ObjectType, name,
+		suppression.Attribute, suppression.Old, suppression.New, suppression.Rule)
+}
+
+func (ruleSet *RuleSet) Compile() error {
+	if ruleSet == nil {
+		return nil
+	}
+	for i, rule := range ruleSet.Rules {
+		if AssertStrBlank(rule.Name) {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		tables, err := compileNamePatterns(rule.Tables)
+		if err != nil {
+			return err
+		}
+		names, err := compileNamePatterns(rule.Names)
+		if err != nil {
+			return err
+		}
+		rule.tables, rule.names = tables, names
+	}
+	return nil
+}
+
+func (rule *Rule) matches(environment string, objectType ObjectType, tableName, name string) bool {
+	if len(rule.Environments) != 0 && !containsStr(rule.Environments, environment) {
+		return false
+	}
+	if len(rule.ObjectTypes) != 0 {
+		found := false
+		for _, t := range rule.ObjectTypes {
+			found = found || t == objectType
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.tables) != 0 && !matchAny(rule.tables, "", tableName) {
+		return false
+	}
+	return len(rule.names) == 0 || matchAny(rule.names, tableName, name)
+}
+
+func (rule *Rule) ignores(attribute string) bool {
+	return containsStr(rule.Ignore, AttrAll) || containsStr(rule.Ignore, attribute)
+}
+
+func (rule *Rule) equivalentTypes(left, right string) bool {
+	var leftOk, rightOk bool
+	for _, columnType := range rule.EquivalentTypes {
+		leftOk = leftOk || strings.EqualFold(columnType, left)
+		rightOk = rightOk || strings.EqualFold(columnType, right)
+	}
+	return leftOk && rightOk
+}
+
+// compares reports whether a rule matching the item asks for attribute, which
+// is otherwise not compared.
+func (ruleSet *RuleSet) compares(objectType ObjectType, tableName, name, attribute string) bool {
+	if ruleSet == nil {
+		return false
+	}
+	for _, rule := range ruleSet.Rules {
+		if rule.matches(ruleSet.Environment, objectType, tableName, name) && containsStr(rule.Compare, attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterChanges drops the attribute changes suppressed by a rule and records
+// them in suppressed.
+func (ruleSet *RuleSet) filterChanges(objectType ObjectType, tableName, name string, changes []*AttrChange, suppressed *[]*Suppression) []*AttrChange {
+	if ruleSet == nil || len(changes) == 0 {
+		return changes
+	}
+	kept := []*AttrChange{}
+	for _, change := range changes {
+		if rule := ruleSet.suppressingRule(objectType, tableName, name, change); rule != nil {
+			*suppressed = append(*suppressed, &Suppression{
+				Rule:       rule.Name,
+				ObjectType: objectType,
+				TableName:  tableName,
+				Name:       name,
+				Attribute:  change.Name,
+				Old:        change.Old,
+				New:        change.New,
+			})
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return kept
+}
+
+func (ruleSet *RuleSet) suppressingRule(objectType ObjectType, tableName, name string, change *AttrChange) *Rule {
+	for _, rule := range ruleSet.Rules {
+		if !rule.matches(ruleSet.Environment, objectType, tableName, name) {
+			continue
+		}
+		if rule.ignores(change.Name) {
+			return rule
+		}
+		if change.Name == AttrType && rule.equivalentTypes(change.Old, change.New) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// ignoresObject reports whether a rule suppresses an item that exists on one
+// side only, and records it in suppressed.
+func (ruleSet *RuleSet) ignoresObject(objectType ObjectType, tableName, name string, suppressed *[]*Suppression) bool {
+	if ruleSet == nil {
+		return false
+	}
+	for _, rule := range ruleSet.Rules {
+		if rule.matches(ruleSet.Environment, objectType, tableName, name) && containsStr(rule.Ignore, AttrAll) {
+			*suppressed = append(*suppressed, &Suppression{
+				Rule:       rule.Name,
+				ObjectType: objectType,
+				TableName:  tableName,
+				Name:       name,
+				Attribute:  AttrAll,
+			})
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package dbdiff
+
+import (
+	"testing"
+)
+
+const rulesTestConfig = `
+# dbdiff rules
+environment = "staging"
+
+[filter]
+exclude_tables = ["tmp_*", "/^_.*_(gho|del)$/"]
+
+[[rule]]
+name = "sequence-counters"
+tables = ["seq_*"]
+compare = ["auto_increment"]
+
+[[rule]]
+name = "audit-comments"
+tables = ['audit_*']
+ignore = ["comment"]
+
+[[rule]]
+name = "int-display-width"
+objects = ["column"]
+equivalent_types = [
+    "int(11)", # 5.7
+    "int",     # 8.0
+]
+
+[[rule]]
+name = "staging-tmp-indexes"
+objects = ["index"]
+names = ["idx_tmp_*"]
+environments = ["staging"]
+ignore = ["*"]
+`
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(rulesTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "ParseConfig", "environment", config.Rules.Environment, "staging")
+	verify(t, 2, "ParseConfig", "rules", len(config.Rules.Rules), 4)
+	verify(t, 3, "ParseConfig", "filter", config.Filter.IncludesTable("_student_gho"), false)
+	verify(t, 4, "ParseConfig", "equivalent types", len(config.Rules.Rules[2].EquivalentTypes), 2)
+	verify(t, 7, "ParseConfig", "compare", config.Rules.Rules[0].Compare[0], AttrAutoIncrement)
+
+	_, err = ParseConfig([]byte("[[rule]]\nignored = [\"comment\"]\n"))
+	_, ok := err.(*ConfigError)
+	verify(t, 5, "ParseConfig", "unknown key", ok, true)
+	_, err = ParseConfig([]byte("environment = \"staging\n"))
+	verify(t, 6, "ParseConfig", "unterminated", err != nil, true)
+}
+
+func TestRuleSet_Suppress(t *testing.T) {
+	config, err := ParseConfig([]byte(rulesTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		column = func(columnType string) *Column {
+			return &Column{ColumnScheme: ColumnScheme{TableName: "audit_log", ColumnName: "id", ColumnType: columnType}}
+		}
+		index = func(keyName string) *Index {
+			return &Index{TableName: "audit_log", KeyName: keyName, Columns: []string{"id"}}
+		}
+		tableOld = &Table{
+			TableScheme: TableScheme{TableName: "audit_log", AutoIncrement: "10", TableComment: "a"},
+			ColumnList:  []*Column{column("int(11)")},
+			IndexList:   []*Index{index("idx_tmp_id")},
+		}
+		tableNew = &Table{
+			TableScheme: TableScheme{TableName: "audit_log", AutoIncrement: "20", TableComment: "b", Engine: "MyISAM"},
+			ColumnList:  []*Column{column("int")},
+		}
+	)
+	diff, err := (&DBDiff{Rules: config.Rules}).parseDatabaseDiff(
+		&DataBase{Tables: []*Table{tableOld}}, &DataBase{Tables: []*Table{tableNew}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffTable := diff.DiffTables[0]
+	verify(t, 1, "RuleSet", "table changes", len(diffTable.Changes), 1)
+	verify(t, 2, "RuleSet", "table engine", diffTable.Changes[0].Name, AttrEngine)
+	verify(t, 3, "RuleSet", "columns", len(diffTable.DiffColumns), 0)
+	verify(t, 4, "RuleSet", "indexes", len(diffTable.DiffIndex), 0)
+
+	//auto increment counters are only compared when a rule asks for them
+	expected := []string{"audit-comments", "int-display-width", "staging-tmp-indexes"}
+	verify(t, 5, "RuleSet", "suppressed", len(diff.Suppressed), len(expected))
+	for i := 0; i < len(expected) && i < len(diff.Suppressed); i++ {
+		verify(t, i+6, "RuleSet", diff.Suppressed[i], diff.Suppressed[i].Rule, expected[i])
+	}
+
+	tableOld.TableName, tableNew.TableName = "seq_order", "seq_order"
+	diff, _ = (&DBDiff{Rules: config.Rules}).parseDatabaseDiff(
+		&DataBase{Tables: []*Table{tableOld}}, &DataBase{Tables: []*Table{tableNew}})
+	verify(t, 11, "RuleSet", "auto increment", diff.DiffTables[0].Change(AttrAutoIncrement) != nil, true)
+	tableOld.TableName, tableNew.TableName = "audit_log", "audit_log"
+
+	config.Rules.Environment = "production"
+	diff, _ = (&DBDiff{Rules: config.Rules}).parseDatabaseDiff(
+		&DataBase{Tables: []*Table{tableOld}}, &DataBase{Tables: []*Table{tableNew}})
+	verify(t, 10, "RuleSet", "production indexes", len(diff.DiffTables[0].DiffIndex), 1)
+}
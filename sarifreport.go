@@ -0,0 +1,187 @@
+package dbdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string      `json:"version"`
+	Schema  string      `json:"$schema"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    *sarifTool     `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver *sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationUri string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId       string              `json:"ruleId"`
+	Level        string              `json:"level"`
+	Message      *sarifMessage       `json:"message"`
+	Locations    []*sarifLocation    `json:"locations"`
+	Suppressions []*sarifSuppression `json:"suppressions,omitempty"`
+	Properties   *sarifProperties    `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation  `json:"physicalLocation,omitempty"`
+	LogicalLocations []*sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation *sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification"`
+}
+
+type sarifProperties struct {
+	Severity string   `json:"severity"`
+	Reasons  []string `json:"reasons,omitempty"`
+	Sql      []string `json:"sql,omitempty"`
+}
+
+// sarifLevel maps a severity to the level code scanning tools show.
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityLossy:
+		return "warning"
+	case SeverityBlocking:
+		return "error"
+	}
+	return "note"
+}
+
+// sarifRuleId names the kind of change, such as column-removed. Suppressed
+// differences use the modified rule of their object type.
+func sarifRuleId(objectType ObjectType, kind ChangeKind) string {
+	return string(objectType) + "-" + string(kind)
+}
+
+// writeSARIFReport renders diff as a SARIF 2.1.0 log with one result per
+// change, so that code scanning tools annotate the migration under review.
+// SARIF locations point at files, artifact is the file results are attached
+// to, such as the migration script, and is required: code scanning drops the
+// results without a physical location. Suppressed differences are listed as
+// suppressed results.
+func writeSARIFReport(w io.Writer, diff *DiffDataBase, artifact string) error {
+	if artifact == "" {
+		return &ReportError{Format: string(FormatSARIF), Message: "missing artifact, results must point at a file such as the migration"}
+	}
+	var (
+		driver = &sarifDriver{Name: "dbdiff", InformationUri: "https://github.com/atuowgo/dbdiff"}
+		run    = &sarifRun{Tool: &sarifTool{Driver: driver}, Results: []*sarifResult{}}
+		rules  = map[string]bool{}
+	)
+	addRule := func(objectType ObjectType, kind ChangeKind) string {
+		id := sarifRuleId(objectType, kind)
+		if !rules[id] {
+			rules[id] = true
+			driver.Rules = append(driver.Rules, &sarifRule{
+				Id:               id,
+				ShortDescription: &sarifMessage{Text: fmt.Sprintf("%s %s", strings.Title(string(objectType)), kind)},
+			})
+		}
+		return id
+	}
+	location := func(objectType ObjectType, tableName, name string) []*sarifLocation {
+		fullName := name
+		if objectType != ObjectTable && objectType != ObjectOption {
+			fullName = tableName + "." + name
+		}
+		return []*sarifLocation{{
+			PhysicalLocation: &sarifPhysicalLocation{ArtifactLocation: &sarifArtifactLocation{Uri: artifact}},
+			LogicalLocations: []*sarifLogicalLocation{{
+				Name:               name,
+				FullyQualifiedName: fullName,
+				Kind:               string(objectType),
+			}},
+		}}
+	}
+
+	items := []*reportItem{}
+	for _, table := range reportTables(diff) {
+		items = append(items, table.all()...)
+	}
+	items = append(items, reportOptions(diff)...)
+	for _, item := range items {
+		change := item.change
+		message := change.String()
+		for _, attr := range item.attrs {
+			message += fmt.Sprintf("\n%s: old %q, new %q", attr.Name, attr.Old, attr.New)
+		}
+		if item.members != nil {
+			message += "\nmembers: " + item.members.String()
+		}
+		run.Results = append(run.Results, &sarifResult{
+			RuleId:    addRule(change.ObjectType, change.Kind),
+			Level:     sarifLevel(change.Severity),
+			Message:   &sarifMessage{Text: message},
+			Locations: location(change.ObjectType, change.TableName, change.Name),
+			Properties: &sarifProperties{
+				Severity: change.Severity.String(),
+				Reasons:  change.Reasons,
+				Sql:      item.sqls,
+			},
+		})
+	}
+	for _, suppression := range diff.Suppressed {
+		run.Results = append(run.Results, &sarifResult{
+			RuleId:    addRule(suppression.ObjectType, ChangeModified),
+			Level:     "note",
+			Message:   &sarifMessage{Text: suppression.String()},
+			Locations: location(suppression.ObjectType, suppression.TableName, suppression.Name),
+			Suppressions: []*sarifSuppression{{
+				Kind:          "external",
+				Justification: "ignored by rule " + suppression.Rule,
+			}},
+		})
+	}
+	if driver.Rules == nil {
+		driver.Rules = []*sarifRule{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []*sarifRun{run}})
+}
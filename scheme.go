@@ -2,15 +2,34 @@ package dbdiff
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type Scheme struct {
-	DbConn    *DBConn
-	Db        *sql.DB
+	DbConn *DBConn
+	Db     *sql.DB
+	Filter *Filter
+	// QueryTimeout bounds each introspection query, zero means no limit.
+	QueryTimeout time.Duration
+	// Parallelism is the number of tables introspected at once, at most 1
+	// introspects them one after the other.
+	Parallelism int
+	// Bulk loads the columns, indexes and foreign keys of all tables with one
+	// query each instead of one query per table, and renders the CREATE TABLE
+	// statements from them.
+	Bulk bool
+	// ShowCreateTable takes the CREATE TABLE statements from SHOW CREATE
+	// TABLE in Bulk mode too, one query per table, for the diff of them.
+	ShowCreateTable bool
+	// Version is the VERSION() of the server, queried first when empty. The
+	// column defaults of MariaDB 10.2.7 and later are read as MySQL reports
+	// them, see mariaDBColumn.
+	Version   string
 	schemeSql *SchemeSql
 	tpl       *DBTemplate
 }
@@ -28,18 +47,40 @@ func NewScheme(dbConn *DBConn, db *sql.DB) *Scheme {
 }
 
 func (scheme *Scheme) Parse() (*DataBase, error) {
-	return scheme.parseDataBase(scheme.DbConn.DBName)
+	return scheme.ParseContext(context.Background())
 }
 
-func (scheme *Scheme) parseDataBase(dbName string) (*DataBase, error) {
-	dataBase := &DataBase{}
-	options, err := scheme.parseOptions()
-	if err != nil {
+// ParseContext introspects the database, stopping at the first query once
+// ctx is canceled or past its deadline.
+func (scheme *Scheme) ParseContext(ctx context.Context) (*DataBase, error) {
+	return scheme.parseDataBase(ctx, scheme.DbConn.DBName)
+}
+
+func (scheme *Scheme) parseDataBase(ctx context.Context, dbName string) (*DataBase, error) {
+	if err := scheme.Filter.Compile(); err != nil {
 		return nil, err
 	}
+	scheme.tpl.QueryTimeout = scheme.QueryTimeout
+	if scheme.Version == "" {
+		version := &struct {
+			Version string `col:"VERSION"`
+		}{}
+		if err := scheme.tpl.QuerySingleContext(ctx, scheme.schemeSql.VersionSql(), version); err != nil {
+			return nil, err
+		}
+		scheme.Version = version.Version
+	}
 
-	dataBase.Options = options
-	tables, err := scheme.parseTables()
+	dataBase := &DataBase{}
+	if scheme.Filter.IncludesObject(ObjectOption) {
+		options, err := scheme.parseOptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		dataBase.Options = options
+	}
+
+	tables, err := scheme.parseTables(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -48,9 +89,9 @@ func (scheme *Scheme) parseDataBase(dbName string) (*DataBase, error) {
 	return dataBase, nil
 }
 
-func (scheme *Scheme) parseOptions() ([]*Variable, error) {
+func (scheme *Scheme) parseOptions(ctx context.Context) ([]*Variable, error) {
 	variableSchemes := []VariableScheme{}
-	err := scheme.tpl.QueryList(scheme.schemeSql.VariablesSchemeSql(Session), &variableSchemes)
+	err := scheme.tpl.QueryListContext(ctx, scheme.schemeSql.VariablesSchemeSql(Session), &variableSchemes)
 	if err != nil {
 		return nil, err
 	}
@@ -62,85 +103,189 @@ func (scheme *Scheme) parseOptions() ([]*Variable, error) {
 	return options, nil
 }
 
-func (scheme *Scheme) parseTables() ([]*Table, error) {
+func (scheme *Scheme) parseTables(ctx context.Context) ([]*Table, error) {
 	tableSchemes := []TableScheme{}
-	err := scheme.tpl.QueryList(scheme.schemeSql.TableSchemeSql(scheme.DbConn.DBName), &tableSchemes)
+	sql, args := scheme.schemeSql.TableSchemeSql(scheme.DbConn.DBName)
+	err := scheme.tpl.QueryListContext(ctx, sql, &tableSchemes, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	tables := make([]*Table, len(tableSchemes))
-	for i, tableScheme := range tableSchemes {
-		var (
-			table     = &Table{TableScheme: tableScheme}
-			tableName = tableScheme.TableName
-		)
+	//excluded tables are never queried
+	included := []TableScheme{}
+	for _, tableScheme := range tableSchemes {
+		if scheme.Filter.IncludesTable(tableScheme.TableName) {
+			included = append(included, tableScheme)
+		}
+	}
+
+	var bulk *bulkSchemes
+	if scheme.Bulk {
+		if bulk, err = scheme.loadBulkSchemes(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	tables := make([]*Table, len(included))
+	err = forEachParallel(ctx, len(included), scheme.Parallelism, func(ctx context.Context, i int) error {
+		table, err := scheme.parseTable(ctx, included[i], bulk)
+		if err != nil {
+			return tableError(included[i].TableName, err)
+		}
+		tables[i] = table
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// parseTable introspects one table, taking its columns, indexes and foreign
+// keys from bulk when it is not nil.
+func (scheme *Scheme) parseTable(ctx context.Context, tableScheme TableScheme, bulk *bulkSchemes) (*Table, error) {
+	var (
+		table     = &Table{TableScheme: tableScheme}
+		tableName = tableScheme.TableName
+	)
+	if err := ctx.Err(); err != nil {
+		return nil, &CanceledError{TableName: tableName, Err: err}
+	}
+
+	//bulk may hold the objects excluded by the filter, to render CREATE TABLE
+	if bulk != nil && scheme.Filter.IncludesObject(ObjectColumn) {
+		table.ColumnList = scheme.buildColumns(tableName, bulk.columns[tableName])
+	}
+	if bulk != nil && scheme.Filter.IncludesObject(ObjectIndex) {
+		table.IndexList = scheme.buildIndexes(tableName, bulk.indexes[tableName])
+	}
+	if bulk != nil && scheme.Filter.IncludesObject(ObjectForeignKey) {
+		table.ForeignKeyList = buildForeignKeys(tableName, bulk.foreignKeys[tableName])
+	}
 
-		columns, err := scheme.parseColumns(tableName)
+	if bulk == nil && scheme.Filter.IncludesObject(ObjectColumn) {
+		columns, err := scheme.parseColumns(ctx, tableName)
 		if err != nil {
 			return nil, err
 		}
 		table.ColumnList = columns
+	}
 
-		indexes, err := scheme.parseIndexes(tableName)
+	if bulk == nil && scheme.Filter.IncludesObject(ObjectIndex) {
+		indexes, err := scheme.parseIndexes(ctx, tableName)
 		if err != nil {
 			return nil, err
 		}
 		table.IndexList = indexes
+	}
 
-		createTableScheme := CreateTableScheme{}
-		err = scheme.tpl.QuerySingle(scheme.schemeSql.ShowCreateTableSql(tableName), &createTableScheme)
+	if bulk == nil && scheme.Filter.IncludesObject(ObjectForeignKey) {
+		foreignKeys, err := scheme.parseForeignKeys(ctx, tableName)
 		if err != nil {
 			return nil, err
 		}
-		table.CreateTableSql = createTableScheme.CreateTable
-		table.DropTableSql = scheme.schemeSql.DropTableSql(tableName)
+		table.ForeignKeyList = foreignKeys
+	}
 
-		tables[i] = table
+	if scheme.Filter.IncludesObject(ObjectTable) {
+		createTableSql, rendered := "", false
+		if bulk != nil && !scheme.ShowCreateTable {
+			createTableSql, rendered = renderCreateTable(tableScheme, bulk.columns[tableName], bulk.indexes[tableName], bulk.foreignKeys[tableName])
+		}
+		if !rendered {
+			createTableScheme := CreateTableScheme{}
+			err := scheme.tpl.QuerySingleContext(ctx, scheme.schemeSql.ShowCreateTableSql(tableName), &createTableScheme)
+			if err != nil {
+				return nil, err
+			}
+			createTableSql = createTableScheme.CreateTable
+		}
+		table.CreateTableSql = createTableSql
+	} else {
+		table.TableScheme = TableScheme{TableName: tableName}
 	}
+	table.DropTableSql = scheme.schemeSql.DropTableSql(tableName)
+	return table, nil
+}
 
-	return tables, nil
+// tableError names the table being introspected in a failed query.
+func tableError(tableName string, err error) error {
+	switch typed := err.(type) {
+	case *CanceledError:
+		typed.TableName = tableName
+	case *DataAccessError:
+		typed.TableName = tableName
+	}
+	return err
 }
 
-func (scheme *Scheme) parseColumns(tableName string) ([]*Column, error) {
+func (scheme *Scheme) parseColumns(ctx context.Context, tableName string) ([]*Column, error) {
 	columnSchemes := []ColumnScheme{}
-	err := scheme.tpl.QueryList(scheme.schemeSql.ColumnSchemeSql(scheme.DbConn.DBName, tableName), &columnSchemes)
+	sql, args := scheme.schemeSql.ColumnSchemeSql(scheme.DbConn.DBName, tableName)
+	err := scheme.tpl.QueryListContext(ctx, sql, &columnSchemes, args...)
 	if err != nil {
 		return nil, err
 	}
+	scheme.readColumns(columnSchemes)
+	return scheme.buildColumns(tableName, columnSchemes), nil
+}
 
-	columns := make([]*Column, len(columnSchemes))
-	for i, columnScheme := range columnSchemes {
+// readColumns rewrites the columns as MySQL reports them when the server is
+// a MariaDB one quoting its defaults.
+func (scheme *Scheme) readColumns(columnSchemes []ColumnScheme) {
+	if !mariaDBDefaults(scheme.Version) {
+		return
+	}
+	for i := range columnSchemes {
+		columnSchemes[i] = mariaDBColumn(columnSchemes[i])
+	}
+}
+
+func (scheme *Scheme) buildColumns(tableName string, columnSchemes []ColumnScheme) []*Column {
+	columns := []*Column{}
+	for _, columnScheme := range columnSchemes {
+		if !scheme.Filter.IncludesColumn(tableName, columnScheme.ColumnName) {
+			continue
+		}
 		column := &Column{ColumnScheme: columnScheme}
 		column.fillAddColumnSql()
 		column.fillModifyColumnSql()
 		column.fillDropColumnSql()
 
-		columns[i] = column
+		columns = append(columns, column)
 	}
-
-	return columns, nil
+	return columns
 }
 
-func (scheme *Scheme) parseIndexes(tableName string) ([]*Index, error) {
+func (scheme *Scheme) parseIndexes(ctx context.Context, tableName string) ([]*Index, error) {
 	indexSchemes := []IndexScheme{}
-	err := scheme.tpl.QueryList(scheme.schemeSql.IndexSchemeSql(tableName), &indexSchemes)
+	err := scheme.tpl.QueryListContext(ctx, scheme.schemeSql.IndexSchemeSql(tableName), &indexSchemes)
 	if err != nil {
 		return nil, err
 	}
+	return scheme.buildIndexes(tableName, indexSchemes), nil
+}
+
+func (scheme *Scheme) buildIndexes(tableName string, indexSchemes []IndexScheme) []*Index {
 	indexMap := make(map[string]*Index)
+	//indexes keep the order of SHOW INDEX, which lists the primary key first
+	indexes := []*Index{}
 	for i, _ := range indexSchemes {
 		var (
 			indexScheme = indexSchemes[i]
 			keyName     = indexScheme.KeyName
 			index       *Index
 		)
+		if !scheme.Filter.IncludesIndex(tableName, keyName) {
+			continue
+		}
 		if _, ok := indexMap[keyName]; !ok {
 			inner := &Index{
 				TableName: tableName,
 				KeyName:   keyName,
 			}
 			indexMap[keyName] = inner
+			indexes = append(indexes, inner)
 			index = inner
 		} else {
 			index = indexMap[keyName]
@@ -149,15 +294,41 @@ func (scheme *Scheme) parseIndexes(tableName string) ([]*Index, error) {
 		index.ColumnIndex = append(index.ColumnIndex, &indexScheme)
 	}
 
-	indexes := make([]*Index, len(indexMap))
-	var i = 0
-	for _, index := range indexMap {
+	for _, index := range indexes {
 		index.fillAddIndexSql()
 		index.fillDropIndexSql()
-		indexes[i] = index
-		i++
 	}
-	return indexes, nil
+	return indexes
+}
+
+func (scheme *Scheme) parseForeignKeys(ctx context.Context, tableName string) ([]*ForeignKey, error) {
+	foreignKeySchemes := []ForeignKeyScheme{}
+	sql, args := scheme.schemeSql.ForeignKeySchemeSql(scheme.DbConn.DBName, tableName)
+	err := scheme.tpl.QueryListContext(ctx, sql, &foreignKeySchemes, args...)
+	if err != nil {
+		return nil, err
+	}
+	return buildForeignKeys(tableName, foreignKeySchemes), nil
+}
+
+func buildForeignKeys(tableName string, foreignKeySchemes []ForeignKeyScheme) []*ForeignKey {
+	foreignKeys := []*ForeignKey{}
+	foreignKeyMap := make(map[string]*ForeignKey)
+	for _, foreignKeyScheme := range foreignKeySchemes {
+		foreignKey, ok := foreignKeyMap[foreignKeyScheme.ConstraintName]
+		if !ok {
+			foreignKey = &ForeignKey{
+				TableName:       tableName,
+				ConstraintName:  foreignKeyScheme.ConstraintName,
+				ReferencedTable: foreignKeyScheme.ReferencedTableName,
+			}
+			foreignKeyMap[foreignKeyScheme.ConstraintName] = foreignKey
+			foreignKeys = append(foreignKeys, foreignKey)
+		}
+		foreignKey.Columns = append(foreignKey.Columns, foreignKeyScheme.ColumnName)
+		foreignKey.ReferencedColumns = append(foreignKey.ReferencedColumns, foreignKeyScheme.ReferencedColumnName)
+	}
+	return foreignKeys
 }
 
 const SCHEME_KEY_COMPARATOR_TAG_NAME = "comp"
@@ -207,6 +378,15 @@ type DataBase struct {
 	Options []*Variable
 }
 
+func (dataBase *DataBase) Table(tableName string) *Table {
+	for _, table := range dataBase.Tables {
+		if table.TableName == tableName {
+			return table
+		}
+	}
+	return nil
+}
+
 type VariableScheme struct {
 	VariableName string `col:"Variable_name" comp:"_"`
 	Value        string `col:"Value"`
@@ -237,23 +417,52 @@ type Table struct {
 	CreateTableSql string
 	DropTableSql   string
 
-	ColumnList []*Column
-	IndexList  []*Index
+	ColumnList     []*Column
+	IndexList      []*Index
+	ForeignKeyList []*ForeignKey
+}
+
+func (table *Table) Column(columnName string) *Column {
+	for _, column := range table.ColumnList {
+		if column.ColumnName == columnName {
+			return column
+		}
+	}
+	return nil
+}
+
+func (table *Table) foreignKey(constraintName string) *ForeignKey {
+	for _, foreignKey := range table.ForeignKeyList {
+		if foreignKey.ConstraintName == constraintName {
+			return foreignKey
+		}
+	}
+	return nil
+}
+
+func (table *Table) PrimaryKey() []string {
+	for _, index := range table.IndexList {
+		if "PRIMARY" == strings.ToUpper(index.KeyName) {
+			return index.Columns
+		}
+	}
+	return nil
 }
 
 type ColumnScheme struct {
-	TableName              string `col:"TABLE_NAME" comp:"_"`
-	ColumnName             string `col:"COLUMN_NAME" comp:"_"`
-	OrdinalPosition        int    `col:"ORDINAL_POSITION"`
-	ColumnDefault          string `col:"COLUMN_DEFAULT"`
-	NullAble               string `col:"IS_NULLABLE"`
-	ColumnType             string `col:"COLUMN_TYPE"`
-	ColumnKey              string `col:"COLUMN_KEY"`
-	CharacterMaximumLength int    `col:"CHARACTER_MAXIMUM_LENGTH"`
-	CharacterSetName       string `col:"CHARACTER_SET_NAME"`
-	CollationName          string `col:"COLLATION_NAME"`
-	Extra                  string `col:"EXTRA"`
-	ColumnComment          string `col:"COLUMN_COMMENT"`
+	TableName              string     `col:"TABLE_NAME" comp:"_"`
+	ColumnName             string     `col:"COLUMN_NAME" comp:"_"`
+	OrdinalPosition        int        `col:"ORDINAL_POSITION"`
+	ColumnDefault          NullString `col:"COLUMN_DEFAULT"`
+	NullAble               string     `col:"IS_NULLABLE"`
+	ColumnType             string     `col:"COLUMN_TYPE"`
+	ColumnKey              string     `col:"COLUMN_KEY"`
+	CharacterMaximumLength int        `col:"CHARACTER_MAXIMUM_LENGTH"`
+	CharacterSetName       string     `col:"CHARACTER_SET_NAME"`
+	CollationName          string     `col:"COLLATION_NAME"`
+	Extra                  string     `col:"EXTRA"`
+	GenerationExpression   string     `col:"GENERATION_EXPRESSION"`
+	ColumnComment          string     `col:"COLUMN_COMMENT"`
 }
 
 type Column struct {
@@ -264,74 +473,24 @@ type Column struct {
 	ModifyColumnSql string
 }
 
+// fillAddColumnSql and fillModifyColumnSql leave the statements empty for a
+// generated column without its expression, as read from old snapshots.
 func (column *Column) fillAddColumnSql() {
-	var buff bytes.Buffer
-	buff.WriteString("ALTER TABLE ")
-	buff.WriteString(column.TableName)
-	buff.WriteString(" ADD COLUMN ")
-	buff.WriteString(column.ColumnName)
-	buff.WriteString(" ")
-	buff.WriteString(column.ColumnType)
-	if "NO" == column.NullAble {
-		buff.WriteString(" NOT NULL ")
-	}
-	if !AssertStrEmpty(column.ColumnDefault) {
-		buff.WriteString(" DEFAULT ")
-		if "CURRENT_TIMESTAMP" != strings.ToUpper(column.ColumnDefault) && !AssertStrEmpty(column.CollationName) {
-			buff.WriteString(fmt.Sprintf("'%s'", column.ColumnDefault))
-		} else {
-			buff.WriteString(column.ColumnDefault)
-		}
-	}
-	if !AssertStrEmpty(column.Extra) {
-		buff.WriteString("")
-		buff.WriteString(column.Extra)
-	}
-
-	if !AssertStrBlank(column.ColumnComment) {
-		buff.WriteString(" COMMENT \"")
-		buff.WriteString(column.ColumnComment)
-		buff.WriteString("\"")
+	if columnGeneration(column.Extra) != "" && column.GenerationExpression == "" {
+		return
 	}
-
-	column.AddColumnSql = buff.String()
+	column.AddColumnSql = "ALTER TABLE " + quoteIdent(column.TableName) + " ADD COLUMN " + ColumnDefinition(column.ColumnScheme)
 }
 
 func (column *Column) fillModifyColumnSql() {
-	var buff bytes.Buffer
-	buff.WriteString("ALTER TABLE ")
-	buff.WriteString(column.TableName)
-	buff.WriteString(" MODIFY COLUMN ")
-	buff.WriteString(column.ColumnName)
-	buff.WriteString(" ")
-	buff.WriteString(column.ColumnType)
-	if "NO" == column.NullAble {
-		buff.WriteString(" NOT NULL ")
-	}
-	if !AssertStrEmpty(column.ColumnDefault) {
-		buff.WriteString(" DEFAULT ")
-		if "CURRENT_TIMESTAMP" != strings.ToUpper(column.ColumnDefault) && !AssertStrEmpty(column.CollationName) {
-			buff.WriteString(fmt.Sprintf("'%s'", column.ColumnDefault))
-		} else {
-			buff.WriteString(column.ColumnDefault)
-		}
-	}
-	if !AssertStrEmpty(column.Extra) {
-		buff.WriteString("")
-		buff.WriteString(column.Extra)
+	if columnGeneration(column.Extra) != "" && column.GenerationExpression == "" {
+		return
 	}
-
-	if !AssertStrBlank(column.ColumnComment) {
-		buff.WriteString(" COMMENT \"")
-		buff.WriteString(column.ColumnComment)
-		buff.WriteString("\"")
-	}
-
-	column.AddColumnSql = buff.String()
+	column.ModifyColumnSql = "ALTER TABLE " + quoteIdent(column.TableName) + " MODIFY COLUMN " + ColumnDefinition(column.ColumnScheme)
 }
 
 func (column *Column) fillDropColumnSql() {
-	column.DropColumnSql = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", column.TableName, column.ColumnName)
+	column.DropColumnSql = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quoteIdent(column.TableName), quoteIdent(column.ColumnName))
 }
 
 type IndexScheme struct {
@@ -361,24 +520,39 @@ type Index struct {
 	ModifyIndexSql string
 }
 
+func (index *Index) Primary() bool {
+	return "PRIMARY" == strings.ToUpper(index.KeyName)
+}
+
+func (index *Index) Unique() bool {
+	return len(index.ColumnIndex) != 0 && index.ColumnIndex[0].NonUnique == 0
+}
+
+func (index *Index) IndexType() string {
+	if len(index.ColumnIndex) == 0 {
+		return ""
+	}
+	return index.ColumnIndex[0].IndexType
+}
+
 func (index *Index) fillAddIndexSql() {
 	var buff bytes.Buffer
 	buff.WriteString("ALTER TABLE ")
-	buff.WriteString(index.TableName)
+	buff.WriteString(quoteIdent(index.TableName))
 
 	if "PRIMARY" == strings.ToUpper(index.KeyName) {
 		buff.WriteString(" ADD PRIMARY KEY ")
 	} else if len(index.ColumnIndex) != 0 && index.ColumnIndex[0].NonUnique == 0 {
-		buff.WriteString(fmt.Sprintf(" ADD UNIQUE INDEX `%s`", index.KeyName))
+		buff.WriteString(" ADD UNIQUE INDEX " + quoteIdent(index.KeyName))
 	} else {
-		buff.WriteString(fmt.Sprintf(" ADD INDEX `%s`", index.KeyName))
+		buff.WriteString(" ADD INDEX " + quoteIdent(index.KeyName))
 	}
 	buff.WriteString(" (")
 	for i := 0; i < len(index.ColumnIndex); i++ {
 		if i != 0 {
 			buff.WriteString(" , ")
 		}
-		buff.WriteString(fmt.Sprintf(`%s`, index.ColumnIndex[i].ColumnName))
+		buff.WriteString(quoteIdent(index.ColumnIndex[i].ColumnName))
 	}
 	buff.WriteString(")")
 
@@ -388,11 +562,11 @@ func (index *Index) fillAddIndexSql() {
 func (index *Index) fillDropIndexSql() {
 	var buff bytes.Buffer
 	buff.WriteString("ALTER TABLE ")
-	buff.WriteString(index.TableName)
+	buff.WriteString(quoteIdent(index.TableName))
 	if "PRIMARY" == strings.ToUpper(index.KeyName) {
 		buff.WriteString(" DROP PRIMARY KEY")
 	} else {
-		buff.WriteString(fmt.Sprintf(" DROP INDEX `%s`", index.KeyName))
+		buff.WriteString(" DROP INDEX " + quoteIdent(index.KeyName))
 	}
 
 	index.DropIndexSql = buff.String()
@@ -400,3 +574,23 @@ func (index *Index) fillDropIndexSql() {
 
 func (index *Index) fillModifyIndexSql() {
 }
+
+type ForeignKeyScheme struct {
+	TableName            string `col:"TABLE_NAME" comp:"_"`
+	ConstraintName       string `col:"CONSTRAINT_NAME" comp:"_"`
+	ColumnName           string `col:"COLUMN_NAME"`
+	OrdinalPosition      int    `col:"ORDINAL_POSITION"`
+	ReferencedTableName  string `col:"REFERENCED_TABLE_NAME"`
+	ReferencedColumnName string `col:"REFERENCED_COLUMN_NAME"`
+	// the rules are only loaded by the bulk query, to render CREATE TABLE
+	UpdateRule string `col:"UPDATE_RULE"`
+	DeleteRule string `col:"DELETE_RULE"`
+}
+
+type ForeignKey struct {
+	TableName         string
+	ConstraintName    string
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+}
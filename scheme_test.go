@@ -1,9 +1,12 @@
 package dbdiff
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSchemeSqlResult(t *testing.T) {
@@ -16,7 +19,8 @@ func TestSchemeSqlResult(t *testing.T) {
 
 	tpl := NewDBTemplate(db)
 	table := []TableScheme{}
-	err := tpl.QueryList(s.TableSchemeSql(dbName), &table)
+	sql, args := s.TableSchemeSql(dbName)
+	err := tpl.QueryList(sql, &table, args...)
 	if err != nil {
 		log.Fatal(err)
 		return
@@ -24,7 +28,8 @@ func TestSchemeSqlResult(t *testing.T) {
 	fmt.Println(table)
 
 	columns := []ColumnScheme{}
-	err = tpl.QueryList(s.ColumnSchemeSql(dbName, tableName), &columns)
+	sql, args = s.ColumnSchemeSql(dbName, tableName)
+	err = tpl.QueryList(sql, &columns, args...)
 	if err != nil {
 		log.Fatal(err)
 		return
@@ -57,3 +62,79 @@ func TestScheme_Parse(t *testing.T) {
 	fmt.Println(cols[0])
 	fmt.Println(cols[1])
 }
+
+func TestScheme_ParseContext(t *testing.T) {
+	var (
+		dbConn = getDBConn()
+		db     = getDB()
+		scheme = NewScheme(dbConn, db)
+	)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	_, err := scheme.ParseContext(ctx)
+	canceled, ok := err.(*CanceledError)
+	verify(t, 1, "ParseContext", "canceled", ok, true)
+	verify(t, 2, "ParseContext", "err", canceled.Err, context.DeadlineExceeded)
+
+	err = tableError("student", &CanceledError{Sql: "SHOW CREATE TABLE student", Err: context.Canceled})
+	verify(t, 3, "tableError", "message", err.Error(), "introspecting table student canceled:context canceled")
+}
+
+func TestScheme_buildBulk(t *testing.T) {
+	var (
+		scheme = &Scheme{Filter: &Filter{ExcludeIndexes: []string{"student.idx_tmp"}}}
+		bulk   = &bulkSchemes{
+			columns: map[string][]ColumnScheme{
+				"student": {
+					{TableName: "student", ColumnName: "id", OrdinalPosition: 1, ColumnType: "int"},
+					{TableName: "student", ColumnName: "name", OrdinalPosition: 2, ColumnType: "varchar(64)"},
+				},
+			},
+			indexes: map[string][]IndexScheme{
+				"student": {
+					{TableName: "student", KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id"},
+					{TableName: "student", KeyName: "idx_name", NonUnique: 1, SeqInIndex: 1, ColumnName: "name"},
+					{TableName: "student", KeyName: "idx_name", NonUnique: 1, SeqInIndex: 2, ColumnName: "id"},
+					{TableName: "student", KeyName: "idx_tmp", NonUnique: 1, SeqInIndex: 1, ColumnName: "name"},
+				},
+			},
+		}
+	)
+	scheme.Filter.Compile()
+	columns := scheme.buildColumns("student", bulk.columns["student"])
+	verify(t, 1, "buildColumns", "student", len(columns), 2)
+	verify(t, 2, "buildColumns", "sql", columns[1].DropColumnSql, "ALTER TABLE `student` DROP COLUMN `name`")
+
+	indexes := scheme.buildIndexes("student", bulk.indexes["student"])
+	verify(t, 3, "buildIndexes", "student", len(indexes), 2)
+	verify(t, 4, "buildIndexes", "primary", indexes[0].Primary(), true)
+	verify(t, 5, "buildIndexes", "columns", strings.Join(indexes[1].Columns, ","), "name,id")
+
+	verify(t, 6, "buildColumns", "unknown", len(scheme.buildColumns("teacher", bulk.columns["teacher"])), 0)
+}
+
+func TestScheme_parseTableBulk(t *testing.T) {
+	var (
+		tpl    = rowsTemplate(t, nil)
+		result = rowsResults[t.Name()]
+		scheme = &Scheme{Filter: &Filter{}, schemeSql: &SchemeSql{}, tpl: tpl}
+		bulk   = &bulkSchemes{
+			columns:     map[string][]ColumnScheme{"student": {{TableName: "student", ColumnName: "id", ColumnType: "int", NullAble: "NO"}}},
+			indexes:     map[string][]IndexScheme{"student": {{TableName: "student", KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id"}}},
+			foreignKeys: map[string][]ForeignKeyScheme{},
+		}
+	)
+	scheme.Filter.Compile()
+	table, err := scheme.parseTable(context.Background(), TableScheme{TableName: "student", Engine: "InnoDB"}, bulk)
+	verify(t, 1, "parseTable", "err", err, nil)
+	verify(t, 2, "parseTable", "queries", len(result.log), 0)
+	verify(t, 3, "parseTable", "create table", table.CreateTableSql, "CREATE TABLE `student` (\n  `id` int NOT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB")
+
+	//the diff of CREATE TABLE statements needs those of the server
+	scheme.ShowCreateTable = true
+	scheme.parseTable(context.Background(), TableScheme{TableName: "student"}, bulk)
+	verify(t, 4, "parseTable", "show create table", strings.Join(result.log, ";"), "show CREATE TABLE `student`")
+}
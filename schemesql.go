@@ -1,26 +1,58 @@
 package dbdiff
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	tableSchemeTpl = "SELECT TABLE_NAME, ENGINE, ROW_FORMAT, AUTO_INCREMENT,CREATE_OPTIONS, TABLE_COLLATION, " +
-		"TABLE_COMMENT FROM information_schema.TABLES WHERE TABLE_SCHEMA='%s' " +
+		"TABLE_COMMENT FROM information_schema.TABLES WHERE TABLE_SCHEMA=? " +
 		"AND not isnull(ENGINE) order by TABLE_NAME"
 
 	columnSchemeTpl = "SELECT TABLE_NAME, COLUMN_NAME, ORDINAL_POSITION, COLUMN_DEFAULT, IS_NULLABLE," +
 		" COLUMN_TYPE, COLUMN_KEY, CHARACTER_MAXIMUM_LENGTH,CHARACTER_SET_NAME, COLLATION_NAME, EXTRA, " +
-		"COLUMN_COMMENT  FROM information_schema.COLUMNS  WHERE TABLE_SCHEMA='%s'  AND TABLE_NAME='%s'  " +
+		"GENERATION_EXPRESSION, COLUMN_COMMENT  FROM information_schema.COLUMNS  WHERE TABLE_SCHEMA=?  AND TABLE_NAME=?  " +
 		"ORDER BY ORDINAL_POSITION"
 
 	indexSchemeTpl = "SHOW INDEX FROM %s"
 
+	allColumnSchemeTpl = "SELECT TABLE_NAME, COLUMN_NAME, ORDINAL_POSITION, COLUMN_DEFAULT, IS_NULLABLE," +
+		" COLUMN_TYPE, COLUMN_KEY, CHARACTER_MAXIMUM_LENGTH,CHARACTER_SET_NAME, COLLATION_NAME, EXTRA, " +
+		"GENERATION_EXPRESSION, COLUMN_COMMENT  FROM information_schema.COLUMNS  WHERE TABLE_SCHEMA=?  " +
+		"ORDER BY TABLE_NAME, ORDINAL_POSITION"
+
+	//the columns are named as in SHOW INDEX, the primary key comes first
+	allIndexSchemeTpl = "SELECT TABLE_NAME AS `Table`, NON_UNIQUE AS `Non_unique`, INDEX_NAME AS `Key_name`, " +
+		"SEQ_IN_INDEX AS `Seq_in_index`, COLUMN_NAME AS `Column_name`, COLLATION AS `Collation`, " +
+		"CARDINALITY AS `Cardinality`, SUB_PART AS `Sub_part`, PACKED AS `Packed`, NULLABLE AS `Null`, " +
+		"INDEX_TYPE AS `Index_type`, COMMENT AS `Comment`, INDEX_COMMENT AS `Index_comment` " +
+		"FROM information_schema.STATISTICS WHERE TABLE_SCHEMA=? " +
+		"ORDER BY TABLE_NAME, INDEX_NAME <> 'PRIMARY', INDEX_NAME, SEQ_IN_INDEX"
+
+	foreignKeySchemeTpl = "SELECT TABLE_NAME, CONSTRAINT_NAME, COLUMN_NAME, ORDINAL_POSITION, REFERENCED_TABLE_NAME, " +
+		"REFERENCED_COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA=? AND TABLE_NAME=? " +
+		"AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION"
+
+	allForeignKeySchemeTpl = "SELECT k.TABLE_NAME, k.CONSTRAINT_NAME, k.COLUMN_NAME, k.ORDINAL_POSITION, k.REFERENCED_TABLE_NAME, " +
+		"k.REFERENCED_COLUMN_NAME, r.UPDATE_RULE, r.DELETE_RULE FROM information_schema.KEY_COLUMN_USAGE k " +
+		"JOIN information_schema.REFERENTIAL_CONSTRAINTS r ON r.CONSTRAINT_SCHEMA=k.CONSTRAINT_SCHEMA " +
+		"AND r.TABLE_NAME=k.TABLE_NAME AND r.CONSTRAINT_NAME=k.CONSTRAINT_NAME WHERE k.TABLE_SCHEMA=? " +
+		"AND k.REFERENCED_TABLE_NAME IS NOT NULL ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION"
+
 	sessionVariablesSchemeTpl = "show variables"
 
 	globalVariablesSchemeTpl = "show GLOBAL variables"
 
 	showCreateTableTpl = "show CREATE TABLE %s"
 
-	dropTableTpl = "drop TABLE %s IF EXISTS"
+	dropTableTpl = "DROP TABLE IF EXISTS %s"
+
+	selectTableDataTpl = "SELECT %s FROM %s ORDER BY %s"
+
+	memberUsageTpl = "SELECT COUNT(*) AS ROW_COUNT FROM %s WHERE %s"
+
+	versionTpl = "SELECT VERSION() AS VERSION"
 )
 
 type VariableScope int
@@ -31,19 +63,37 @@ const (
 	Global
 )
 
+// SchemeSql builds the introspection and DDL statements. Values are returned
+// as bound parameters next to the statement, names are quoted identifiers.
 type SchemeSql struct {
 }
 
-func (this *SchemeSql) TableSchemeSql(dbName string) string {
-	return fmt.Sprintf(tableSchemeTpl, dbName)
+func (this *SchemeSql) TableSchemeSql(dbName string) (string, []interface{}) {
+	return tableSchemeTpl, []interface{}{dbName}
 }
 
-func (this *SchemeSql) ColumnSchemeSql(dbName, tableName string) string {
-	return fmt.Sprintf(columnSchemeTpl, dbName, tableName)
+func (this *SchemeSql) ColumnSchemeSql(dbName, tableName string) (string, []interface{}) {
+	return columnSchemeTpl, []interface{}{dbName, tableName}
 }
 
 func (this *SchemeSql) IndexSchemeSql(tableName string) string {
-	return fmt.Sprintf(indexSchemeTpl, tableName)
+	return fmt.Sprintf(indexSchemeTpl, quoteIdent(tableName))
+}
+
+func (this *SchemeSql) ForeignKeySchemeSql(dbName, tableName string) (string, []interface{}) {
+	return foreignKeySchemeTpl, []interface{}{dbName, tableName}
+}
+
+func (this *SchemeSql) AllColumnSchemeSql(dbName string) (string, []interface{}) {
+	return allColumnSchemeTpl, []interface{}{dbName}
+}
+
+func (this *SchemeSql) AllIndexSchemeSql(dbName string) (string, []interface{}) {
+	return allIndexSchemeTpl, []interface{}{dbName}
+}
+
+func (this *SchemeSql) AllForeignKeySchemeSql(dbName string) (string, []interface{}) {
+	return allForeignKeySchemeTpl, []interface{}{dbName}
 }
 
 func (this *SchemeSql) VariablesSchemeSql(scope VariableScope) string {
@@ -56,10 +106,28 @@ func (this *SchemeSql) VariablesSchemeSql(scope VariableScope) string {
 	return ""
 }
 
+func (this *SchemeSql) VersionSql() string {
+	return versionTpl
+}
+
 func (this *SchemeSql) ShowCreateTableSql(tableName string) string {
-	return fmt.Sprintf(showCreateTableTpl, tableName)
+	return fmt.Sprintf(showCreateTableTpl, quoteIdent(tableName))
 }
 
 func (this *SchemeSql) DropTableSql(tableName string) string {
-	return fmt.Sprintf(dropTableTpl, tableName)
+	return fmt.Sprintf(dropTableTpl, quoteIdent(tableName))
+}
+
+// SelectTableDataSql selects the expressions of columns from a table sorted by
+// orderBy, both already quoted.
+func (this *SchemeSql) SelectTableDataSql(tableName string, columns, orderBy []string) string {
+	return fmt.Sprintf(selectTableDataTpl, strings.Join(columns, ", "), quoteIdent(tableName), strings.Join(orderBy, ", "))
+}
+
+func (this *SchemeSql) MemberUsageSql(tableName, columnName, member string, set bool) (string, []interface{}) {
+	where := quoteIdent(columnName) + " = ?"
+	if set {
+		where = "FIND_IN_SET(?, " + quoteIdent(columnName) + ") > 0"
+	}
+	return fmt.Sprintf(memberUsageTpl, quoteIdent(tableName), where), []interface{}{member}
 }
@@ -2,6 +2,7 @@ package dbdiff
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -20,3 +21,32 @@ func TestSchemeSql_ColumnSchemeSql(t *testing.T) {
 func TestSchemeSql_IndexSchemeSql(t *testing.T) {
 	fmt.Println(s.IndexSchemeSql(tableName))
 }
+
+func TestSchemeSql_AllSchemeSql(t *testing.T) {
+	fmt.Println(s.AllColumnSchemeSql(dbName))
+	fmt.Println(s.AllIndexSchemeSql(dbName))
+	fmt.Println(s.AllForeignKeySchemeSql(dbName))
+}
+
+func TestSchemeSql_Quoting(t *testing.T) {
+	sql, args := s.ColumnSchemeSql("tenant'; DROP DATABASE app; --", "user-events")
+	verify(t, 1, "ColumnSchemeSql", "bound", strings.Contains(sql, "TABLE_SCHEMA=?  AND TABLE_NAME=?"), true)
+	verify(t, 2, "ColumnSchemeSql", "args", args[0], "tenant'; DROP DATABASE app; --")
+	verify(t, 3, "IndexSchemeSql", "reserved", s.IndexSchemeSql("order"), "SHOW INDEX FROM `order`")
+	verify(t, 4, "ShowCreateTableSql", "backtick", s.ShowCreateTableSql("a`b"), "show CREATE TABLE `a``b`")
+	verify(t, 5, "DropTableSql", "hyphen", s.DropTableSql("user-events"), "DROP TABLE IF EXISTS `user-events`")
+
+	column := &Column{ColumnScheme: ColumnScheme{TableName: "order", ColumnName: "group", ColumnType: "varchar(8)",
+		NullAble: "YES", ColumnDefault: NewNullString("it's"), CollationName: "utf8_general_ci", ColumnComment: `say "hi"`}}
+	column.fillAddColumnSql()
+	column.fillDropColumnSql()
+	verify(t, 6, "fillAddColumnSql", "quoted", column.AddColumnSql,
+		"ALTER TABLE `order` ADD COLUMN `group` varchar(8) COLLATE utf8_general_ci NULL DEFAULT 'it''s' COMMENT 'say \"hi\"'")
+	verify(t, 7, "fillDropColumnSql", "quoted", column.DropColumnSql, "ALTER TABLE `order` DROP COLUMN `group`")
+
+	index := &Index{TableName: "user-events", KeyName: "idx`x", ColumnIndex: []*IndexScheme{{ColumnName: "key", NonUnique: 1}}}
+	index.fillAddIndexSql()
+	index.fillDropIndexSql()
+	verify(t, 8, "fillAddIndexSql", "quoted", index.AddIndexSql, "ALTER TABLE `user-events` ADD INDEX `idx``x` (`key`)")
+	verify(t, 9, "fillDropIndexSql", "quoted", index.DropIndexSql, "ALTER TABLE `user-events` DROP INDEX `idx``x`")
+}
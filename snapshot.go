@@ -0,0 +1,53 @@
+package dbdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SnapshotVersion 2 writes NULL column defaults as null, version 1 wrote
+// them as empty strings and is still read. Version 3 writes the defaults of
+// MariaDB as MySQL reports them, older snapshots of MariaDB keep its quotes.
+const SnapshotVersion = 3
+
+// Snapshot is the exported scheme of a database, written as JSON so that a
+// later diff can compare against it without connecting to the database.
+type Snapshot struct {
+	Version  int
+	DBName   string
+	DataBase *DataBase
+}
+
+func WriteSnapshot(w io.Writer, dbName string, dataBase *DataBase) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(&Snapshot{Version: SnapshotVersion, DBName: dbName, DataBase: dataBase}); err != nil {
+		return &SnapshotError{Message: "write snapshot", Err: err}
+	}
+	return nil
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(snapshot); err != nil {
+		return nil, &SnapshotError{Message: "read snapshot", Err: err}
+	}
+	if snapshot.Version < 1 || snapshot.Version > SnapshotVersion {
+		return nil, &SnapshotError{Message: fmt.Sprintf("unsupported snapshot version %d", snapshot.Version)}
+	}
+	if snapshot.DataBase == nil {
+		snapshot.DataBase = &DataBase{}
+	}
+	if snapshot.Version == 1 {
+		//an empty default was most likely a NULL one
+		for _, table := range snapshot.DataBase.Tables {
+			for _, column := range table.ColumnList {
+				if column.ColumnDefault == NewNullString("") {
+					column.ColumnDefault = NullString{}
+				}
+			}
+		}
+	}
+	return snapshot, nil
+}
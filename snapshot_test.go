@@ -0,0 +1,88 @@
+package dbdiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func snapshotTestDataBase() *DataBase {
+	return &DataBase{
+		Tables: []*Table{
+			{
+				TableScheme: TableScheme{TableName: "student", Engine: "InnoDB"},
+				ColumnList: []*Column{
+					classifyTestColumn("varchar(64)", "YES", "utf8"),
+					{ColumnScheme: ColumnScheme{TableName: "student", ColumnName: "updated_at", ColumnType: "datetime"}},
+				},
+				IndexList: []*Index{{TableName: "student", KeyName: "PRIMARY", Columns: []string{"id"}}},
+			},
+			{TableScheme: TableScheme{TableName: "tmp_student"}},
+		},
+		Options: []*Variable{{VariableScheme{VariableName: "sql_mode", Value: "STRICT_TRANS_TABLES"}}},
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	var buff bytes.Buffer
+	if err := WriteSnapshot(&buff, "dbdiff", snapshotTestDataBase()); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ReadSnapshot(&buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "ReadSnapshot", "DBName", snapshot.DBName, "dbdiff")
+	verify(t, 2, "ReadSnapshot", "Tables", len(snapshot.DataBase.Tables), 2)
+	verify(t, 3, "ReadSnapshot", "Column", snapshot.DataBase.Table("student").Column("name").ColumnType, "varchar(64)")
+	verify(t, 4, "ReadSnapshot", "Index", snapshot.DataBase.Table("student").PrimaryKey()[0], "id")
+
+	_, err = ReadSnapshot(strings.NewReader(`{"Version": 99}`))
+	_, ok := err.(*SnapshotError)
+	verify(t, 5, "ReadSnapshot", "version", ok, true)
+}
+
+func TestSnapshot_nullDefaults(t *testing.T) {
+	dataBase := &DataBase{Tables: []*Table{{
+		TableScheme: TableScheme{TableName: "student"},
+		ColumnList: []*Column{
+			{ColumnScheme: ColumnScheme{ColumnName: "nickname", ColumnDefault: NewNullString("")}},
+			{ColumnScheme: ColumnScheme{ColumnName: "note"}},
+		},
+	}}}
+	var buff bytes.Buffer
+	WriteSnapshot(&buff, "dbdiff", dataBase)
+	verify(t, 1, "WriteSnapshot", "null", strings.Contains(buff.String(), `"ColumnDefault": null`), true)
+	snapshot, err := ReadSnapshot(&buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 2, "ReadSnapshot", "empty", snapshot.DataBase.Table("student").Column("nickname").ColumnDefault, NewNullString(""))
+	verify(t, 3, "ReadSnapshot", "null", snapshot.DataBase.Table("student").Column("note").ColumnDefault, NullString{})
+
+	//version 1 wrote NULL defaults as empty strings
+	snapshot, err = ReadSnapshot(strings.NewReader(`{"Version": 1, "DataBase": {"Tables": [{"TableName": "student",
+		"ColumnList": [{"ColumnName": "note", "ColumnDefault": ""}, {"ColumnName": "age", "ColumnDefault": "0"}]}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 4, "ReadSnapshot", "v1 null", snapshot.DataBase.Table("student").Column("note").ColumnDefault, NullString{})
+	verify(t, 5, "ReadSnapshot", "v1 value", snapshot.DataBase.Table("student").Column("age").ColumnDefault, NewNullString("0"))
+}
+
+func TestFilterDataBase(t *testing.T) {
+	filter := &Filter{
+		ExcludeTables:  TempTablePatterns,
+		ExcludeColumns: []string{"*.updated_at"},
+		ObjectTypes:    []ObjectType{ObjectTable, ObjectColumn},
+	}
+	dataBase, err := filter.FilterDataBase(snapshotTestDataBase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "FilterDataBase", "Tables", len(dataBase.Tables), 1)
+	verify(t, 2, "FilterDataBase", "Columns", len(dataBase.Tables[0].ColumnList), 1)
+	verify(t, 3, "FilterDataBase", "Indexes", len(dataBase.Tables[0].IndexList), 0)
+	verify(t, 4, "FilterDataBase", "Options", len(dataBase.Options), 0)
+	verify(t, 5, "FilterDataBase", "Engine", dataBase.Tables[0].Engine, "InnoDB")
+}
@@ -0,0 +1,71 @@
+package dbdiff
+
+// DiffStatus sums up a diff for CI: no differences, only differences the
+// rules suppressed, differences, or a failure to compute the diff.
+type DiffStatus int
+
+const (
+	StatusEmpty DiffStatus = iota
+	StatusIgnorable
+	StatusChanged
+	StatusError
+)
+
+// Exit codes of the dbdiff command for each DiffStatus. Ignorable diffs get a
+// code of their own so that pipelines can choose to let them pass.
+const (
+	ExitEmpty     = 0
+	ExitChanged   = 1
+	ExitError     = 2
+	ExitIgnorable = 3
+)
+
+func (status DiffStatus) String() string {
+	switch status {
+	case StatusEmpty:
+		return "empty"
+	case StatusIgnorable:
+		return "ignorable"
+	case StatusChanged:
+		return "changed"
+	case StatusError:
+		return "error"
+	}
+	return "unknown"
+}
+
+func (status DiffStatus) ExitCode() int {
+	switch status {
+	case StatusEmpty:
+		return ExitEmpty
+	case StatusIgnorable:
+		return ExitIgnorable
+	case StatusChanged:
+		return ExitChanged
+	}
+	return ExitError
+}
+
+// Status tells whether diff has changes, a CREATE TABLE diff counts as one.
+func (diff *DiffDataBase) Status() DiffStatus {
+	if len(Classify(diff)) != 0 {
+		return StatusChanged
+	}
+	for _, diffTable := range diff.DiffTables {
+		if diffTable.CreateTableDiff != "" {
+			return StatusChanged
+		}
+	}
+	if len(diff.Suppressed) != 0 {
+		return StatusIgnorable
+	}
+	return StatusEmpty
+}
+
+// StatusOf is the status of the result of ParseDiff.
+func StatusOf(diff *DiffDataBase, err error) DiffStatus {
+	if err != nil || diff == nil {
+		return StatusError
+	}
+	return diff.Status()
+}
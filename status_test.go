@@ -0,0 +1,33 @@
+package dbdiff
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiffStatus(t *testing.T) {
+	var (
+		suppressed = []*Suppression{{Rule: "rule-1", ObjectType: ObjectTable, Name: "student", Attribute: AttrAutoIncrement}}
+		textual    = &DiffDataBase{DiffTables: []*DiffTable{{TableName: "student", TableOld: &Table{}, TableNew: &Table{}, CreateTableDiff: "-a\n+b\n"}}}
+	)
+	for i, test := range []struct {
+		name     string
+		diff     *DiffDataBase
+		err      error
+		expected DiffStatus
+	}{
+		{"empty", &DiffDataBase{}, nil, StatusEmpty},
+		{"ignorable", &DiffDataBase{Suppressed: suppressed}, nil, StatusIgnorable},
+		{"changed", reportTestDiff(), nil, StatusChanged},
+		{"create table diff", textual, nil, StatusChanged},
+		{"error", nil, errors.New("connection refused"), StatusError},
+	} {
+		status := StatusOf(test.diff, test.err)
+		verify(t, i+1, "StatusOf", test.name, status, test.expected)
+	}
+
+	verify(t, 6, "ExitCode", "empty", StatusEmpty.ExitCode(), 0)
+	verify(t, 7, "ExitCode", "changed", StatusChanged.ExitCode(), 1)
+	verify(t, 8, "ExitCode", "error", StatusError.ExitCode(), 2)
+	verify(t, 9, "ExitCode", "ignorable", StatusIgnorable.ExitCode(), 3)
+}
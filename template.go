@@ -1,20 +1,49 @@
 package dbdiff
 
 import (
+	"context"
 	"database/sql"
 	"reflect"
+	"strings"
+	"time"
 )
 
 type Operations interface {
-	queryListByRowMapper(sql string, rowMapper RowMapper, out interface{}) error
+	queryListByRowMapper(ctx context.Context, sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error
 
-	QuerySingle(sql string, out interface{}) error
+	QuerySingle(sql string, out interface{}, args ...interface{}) error
 
-	QuerySingleByMapper(sql string, rowMapper RowMapper, out interface{}) error
+	QuerySingleContext(ctx context.Context, sql string, out interface{}, args ...interface{}) error
 
-	QueryList(sql string, out interface{}) error
+	QuerySingleByMapper(sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error
 
-	QueryListByMapper(sql string, rowMapper RowMapper, out interface{}) error
+	QuerySingleByMapperContext(ctx context.Context, sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error
+
+	QueryList(sql string, out interface{}, args ...interface{}) error
+
+	QueryListContext(ctx context.Context, sql string, out interface{}, args ...interface{}) error
+
+	QueryListByMapper(sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error
+
+	QueryListByMapperContext(ctx context.Context, sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error
+
+	QueryEach(sql string, fn interface{}, args ...interface{}) error
+
+	QueryEachContext(ctx context.Context, sql string, fn interface{}, args ...interface{}) error
+
+	QueryCursor(ctx context.Context, sql string, args ...interface{}) (*Cursor, error)
+
+	Exec(sql string, args ...interface{}) (sql.Result, error)
+
+	ExecContext(ctx context.Context, sql string, args ...interface{}) (sql.Result, error)
+
+	ExecBatch(sqls []string) ([]sql.Result, error)
+
+	ExecBatchContext(ctx context.Context, sqls []string) ([]sql.Result, error)
+
+	InTransaction(fn func(tx Operations) error) error
+
+	InTransactionContext(ctx context.Context, fn func(tx Operations) error) error
 }
 
 type RowMapperResultSetExtractor struct {
@@ -23,7 +52,7 @@ type RowMapperResultSetExtractor struct {
 
 func (extractor *RowMapperResultSetExtractor) ExtractData(rs *sql.Rows, out interface{}) error {
 	if !AssertTypePtrOfSlice(out) {
-		return &DataAccessError{Message: "input param out must be a slice", Err: nil}
+		return &DataAccessError{Message: "input param out must be a slice"}
 	}
 
 	var (
@@ -45,43 +74,92 @@ func (extractor *RowMapperResultSetExtractor) ExtractData(rs *sql.Rows, out inte
 
 type DBTemplate struct {
 	db *sql.DB
+	// conn is set on the templates bound to one connection of the pool.
+	conn *sql.Conn
+	// tx is set on the templates passed to InTransaction callbacks.
+	tx        *sql.Tx
+	savepoint int
+	// QueryTimeout bounds every query, zero waits for the context only.
+	QueryTimeout time.Duration
+	// StrictMapping fails on result columns without a struct field, instead
+	// of ignoring them.
+	StrictMapping bool
+	// Hooks see every query and exec before and after it runs.
+	Hooks []StatementHook
 }
 
 func NewDBTemplate(db *sql.DB) *DBTemplate {
 	return &DBTemplate{db: db}
 }
 
-func (tpl *DBTemplate) queryListByRowMapper(sql string, rowMapper RowMapper, out interface{}) error {
-	rs, err := tpl.db.Query(sql)
-	if err != nil {
-		return &DataAccessError{Message: "Db query error", Err: err}
+// NewConnTemplate binds a template to one connection, for statements that
+// change the session, such as SET, which must hold for the statements after.
+func NewConnTemplate(conn *sql.Conn) *DBTemplate {
+	return &DBTemplate{conn: conn}
+}
+
+func (tpl *DBTemplate) queryListByRowMapper(ctx context.Context, sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error {
+	if tpl.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tpl.QueryTimeout)
+		defer cancel()
 	}
-	extractor := RowMapperResultSetExtractor{rowMapper: rowMapper}
-	defer rs.Close()
-	err = extractor.ExtractData(rs, out)
-	if err != nil {
-		return &DataAccessError{Message: "row mapper result set extractor error", Err: err}
+	return tpl.hooked(ctx, &Statement{Sql: sql, Args: args}, func() error {
+		rs, err := tpl.executor().QueryContext(ctx, sql, args...)
+		if err != nil {
+			return queryError(ctx, sql, &DataAccessError{Message: "Db query error", Err: err})
+		}
+		extractor := RowMapperResultSetExtractor{rowMapper: rowMapper}
+		defer rs.Close()
+		err = extractor.ExtractData(rs, out)
+		if err == nil {
+			err = rs.Err()
+		}
+		if err != nil {
+			return queryError(ctx, sql, &DataAccessError{Message: "row mapper result set extractor error", Err: err})
+		}
+		return nil
+	})
+}
+
+// queryError reports a query that failed because ctx was canceled or timed
+// out as a CanceledError, and records the query and the kind of failure on
+// the other DataAccessErrors.
+func queryError(ctx context.Context, sql string, err error) error {
+	if ctx.Err() != nil {
+		return &CanceledError{Sql: sql, Err: ctx.Err()}
 	}
-	return nil
+	if dae, ok := err.(*DataAccessError); ok {
+		dae.Sql = sql
+		dae.Kind = errorKind(dae.Err)
+	}
+	return err
 }
 
 const COL_TAG_NAME = "col"
 
+// defaultRowMapper4Struct maps the result columns to the struct fields with
+// the same col tag, exactly or else ignoring case, including the fields of
+// embedded structs. Pointer fields stay nil on NULL, sql.Scanner fields scan
+// NULL themselves and other fields keep their zero value. Columns without a
+// field are ignored, unless strict.
 type defaultRowMapper4Struct struct {
-	fieldMap map[string]string
-	init     bool
+	strict bool
+	fields map[string][]int
+	folded map[string][]int
+	init   bool
 }
 
 func (drm *defaultRowMapper4Struct) MapRow(rs *sql.Rows, rowNum int, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return &DataAccessError{Message: "out param must be a ptr of struct"}
+	}
 	if !drm.init {
-		drm.fieldMap = make(map[string]string)
-		t := reflect.ValueOf(out).Type().Elem()
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			name := field.Tag.Get(COL_TAG_NAME)
-			if len(name) != 0 {
-				drm.fieldMap[name] = field.Name
-			}
+		drm.fields, drm.folded = map[string][]int{}, map[string][]int{}
+		collectColumnFields(v.Elem().Type(), nil, drm.fields)
+		for name, index := range drm.fields {
+			drm.folded[strings.ToLower(name)] = index
 		}
 		drm.init = true
 	}
@@ -91,24 +169,31 @@ func (drm *defaultRowMapper4Struct) MapRow(rs *sql.Rows, rowNum int, out interfa
 		return &DataAccessError{Message: "get columns from result set error", Err: err}
 	}
 	var (
-		//point on point
-		values      = make([]interface{}, len(drm.fieldMap))
-		v           = reflect.ValueOf(out)
-		resetFields = make([]reflect.Value, len(drm.fieldMap))
+		values      = make([]interface{}, len(colNames))
+		resetFields = make([]reflect.Value, len(colNames))
 	)
 	for i, name := range colNames {
-		fileName := drm.fieldMap[name]
-		fieldV := v.Elem().FieldByName(fileName)
-		if fieldV.Type().Kind() == reflect.Ptr {
-			if fieldV.IsNil() {
-				fieldV.Set(reflect.New(fieldV.Type().Elem()))
+		index, ok := drm.fields[name]
+		if !ok {
+			index, ok = drm.folded[strings.ToLower(name)]
+		}
+		if !ok {
+			if drm.strict {
+				return &DataAccessError{Message: "no field of " + v.Elem().Type().String() + " for column " + name}
 			}
-			//field is point,use it's addr
+			values[i] = new(sql.RawBytes)
+			continue
+		}
+		fieldV := fieldByIndexAlloc(v.Elem(), index)
+		switch {
+		case fieldV.Kind() == reflect.Ptr:
+			//NULL sets the field back to nil
 			values[i] = fieldV.Addr().Interface()
-		} else {
-			//new point on point wiht field
+		case reflect.PtrTo(fieldV.Type()).Implements(scannerType):
+			values[i] = fieldV.Addr().Interface()
+		default:
+			//scan on a new point on point, NULL leaves it nil
 			reflectValue := reflect.New(reflect.PtrTo(fieldV.Type()))
-			reflectValue.Elem().Set(fieldV.Addr())
 			values[i] = reflectValue.Interface()
 			resetFields[i] = fieldV
 		}
@@ -119,32 +204,97 @@ func (drm *defaultRowMapper4Struct) MapRow(rs *sql.Rows, rowNum int, out interfa
 	}
 
 	for i, field := range resetFields {
+		if !field.IsValid() {
+			continue
+		}
 		if v := reflect.ValueOf(values[i]).Elem().Elem(); v.IsValid() {
 			//reset value on field which is not point
 			field.Set(v)
+		} else {
+			field.Set(reflect.Zero(field.Type()))
 		}
 	}
+	return nil
+}
 
-	if err != nil {
-		return &DataAccessError{Message: "scan result set error", Err: err}
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// collectColumnFields indexes the fields of t by their col tag. The fields of
+// t win over those of its embedded structs.
+func collectColumnFields(t reflect.Type, prefix []int, fields map[string][]int) {
+	embedded := []int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get(COL_TAG_NAME)
+		if field.PkgPath != "" && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			//unexported, only the fields of an embedded struct value can be set
+			continue
+		}
+		if len(name) != 0 && name != "-" {
+			if _, ok := fields[name]; !ok {
+				fields[name] = append(append([]int{}, prefix...), i)
+			}
+			continue
+		}
+		if field.Anonymous && structType(field.Type) != nil {
+			embedded = append(embedded, i)
+		}
 	}
-	return nil
+	for _, i := range embedded {
+		collectColumnFields(structType(t.Field(i).Type), append(append([]int{}, prefix...), i), fields)
+	}
+}
+
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// fieldByIndexAlloc is FieldByIndex allocating the nil embedded pointers on
+// the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func (tpl *DBTemplate) QuerySingle(sql string, out interface{}, args ...interface{}) error {
+	return tpl.QuerySingleContext(context.Background(), sql, out, args...)
 }
 
-func (tpl *DBTemplate) QuerySingle(sql string, out interface{}) error {
+func (tpl *DBTemplate) QuerySingleContext(ctx context.Context, sql string, out interface{}, args ...interface{}) error {
 	if !AssertTypePtrOfStruct(out) {
 		return &DataAccessError{Message: "out param must be a ptr of struct"}
 	}
-	return tpl.QuerySingleByMapper(sql, &defaultRowMapper4Struct{}, out)
+	return tpl.QuerySingleByMapperContext(ctx, sql, &defaultRowMapper4Struct{strict: tpl.StrictMapping}, out, args...)
+}
+
+func (tpl *DBTemplate) QuerySingleByMapper(sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error {
+	return tpl.QuerySingleByMapperContext(context.Background(), sql, rowMapper, out, args...)
 }
 
-func (tpl *DBTemplate) QuerySingleByMapper(sql string, rowMapper RowMapper, out interface{}) error {
+func (tpl *DBTemplate) QuerySingleByMapperContext(ctx context.Context, sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error {
 	var (
 		v        = reflect.ValueOf(out)
 		slv      = sliceByType(v.Type().Elem())
 		outSlice = slv.Interface()
-		err      = tpl.queryListByRowMapper(sql, rowMapper, outSlice)
+		err      = tpl.queryListByRowMapper(ctx, sql, rowMapper, outSlice, args...)
 	)
+	if canceled, ok := err.(*CanceledError); ok {
+		return canceled
+	}
 	if err != nil {
 		return &DataAccessError{Message: "query by mapper error", Err: err}
 	}
@@ -165,13 +315,21 @@ func sliceByType(tp reflect.Type) reflect.Value {
 	return reflect.New(reflect.SliceOf(tp))
 }
 
-func (tpl *DBTemplate) QueryList(sql string, out interface{}) error {
+func (tpl *DBTemplate) QueryList(sql string, out interface{}, args ...interface{}) error {
+	return tpl.QueryListContext(context.Background(), sql, out, args...)
+}
+
+func (tpl *DBTemplate) QueryListContext(ctx context.Context, sql string, out interface{}, args ...interface{}) error {
 	if !AssertTypePtrOfSliceWithStruct(out) {
 		return &DataAccessError{Message: "out param must be a ptr of slice with struct"}
 	}
-	return tpl.queryListByRowMapper(sql, &defaultRowMapper4Struct{}, out)
+	return tpl.queryListByRowMapper(ctx, sql, &defaultRowMapper4Struct{strict: tpl.StrictMapping}, out, args...)
+}
+
+func (tpl *DBTemplate) QueryListByMapper(sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error {
+	return tpl.QueryListByMapperContext(context.Background(), sql, rowMapper, out, args...)
 }
 
-func (tpl *DBTemplate) QueryListByMapper(sql string, rowMapper RowMapper, out interface{}) error {
-	return tpl.queryListByRowMapper(sql, rowMapper, out)
+func (tpl *DBTemplate) QueryListByMapperContext(ctx context.Context, sql string, rowMapper RowMapper, out interface{}, args ...interface{}) error {
+	return tpl.queryListByRowMapper(ctx, sql, rowMapper, out, args...)
 }
@@ -1,8 +1,13 @@
 package dbdiff
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -82,3 +87,159 @@ func TestDBTemplate_QuerySingle(t *testing.T) {
 	}
 	fmt.Println("out by mapper", outByMapper)
 }
+
+func TestDBTemplate_QueryListContext(t *testing.T) {
+	db := getDB()
+	defer db.Close()
+
+	//a canceled context fails before connecting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tpl := NewDBTemplate(db)
+	out := []Student{}
+	err := tpl.QueryListContext(ctx, "select * from student", &out)
+	canceled, ok := err.(*CanceledError)
+	verify(t, 1, "QueryListContext", "canceled", ok, true)
+	verify(t, 2, "QueryListContext", "err", canceled.Err, context.Canceled)
+
+	single := Student{}
+	_, ok = tpl.QuerySingleContext(ctx, "select * from student", &single).(*CanceledError)
+	verify(t, 3, "QuerySingleContext", "canceled", ok, true)
+}
+
+// rowsDriver answers every query of a connection with the rows registered
+// under its name, to test the row mapper without a database.
+type rowsDriver struct{}
+
+type rowsResult struct {
+	columns []string
+	rows    [][]driver.Value
+	// log lists the statements run, execs containing "fail" fail
+	log []string
+	// opened counts the connections opened
+	opened       int
+	failRollback bool
+}
+
+var rowsResults = map[string]*rowsResult{}
+
+func init() {
+	sql.Register("dbdiff-rows", rowsDriver{})
+}
+
+func (rowsDriver) Open(name string) (driver.Conn, error) {
+	rowsResults[name].opened++
+	return &rowsConn{rowsResults[name]}, nil
+}
+
+type rowsConn struct{ result *rowsResult }
+
+func (conn *rowsConn) Prepare(query string) (driver.Stmt, error) {
+	return &rowsStmt{conn: conn, query: query}, nil
+}
+func (conn *rowsConn) Close() error { return nil }
+func (conn *rowsConn) Begin() (driver.Tx, error) {
+	conn.result.log = append(conn.result.log, "BEGIN")
+	return conn, nil
+}
+func (conn *rowsConn) Commit() error {
+	conn.result.log = append(conn.result.log, "COMMIT")
+	return nil
+}
+func (conn *rowsConn) Rollback() error {
+	if conn.result.failRollback {
+		return errors.New("rollback failed")
+	}
+	conn.result.log = append(conn.result.log, "ROLLBACK")
+	return nil
+}
+
+type rowsStmt struct {
+	conn  *rowsConn
+	query string
+}
+
+func (stmt *rowsStmt) Close() error  { return nil }
+func (stmt *rowsStmt) NumInput() int { return -1 }
+func (stmt *rowsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	stmt.conn.result.log = append(stmt.conn.result.log, stmt.query)
+	if strings.Contains(stmt.query, "fail") {
+		return nil, errors.New("exec failed")
+	}
+	return driver.RowsAffected(1), nil
+}
+func (stmt *rowsStmt) Query(args []driver.Value) (driver.Rows, error) {
+	stmt.conn.result.log = append(stmt.conn.result.log, stmt.query)
+	return &rowsCursor{result: stmt.conn.result}, nil
+}
+
+type rowsCursor struct {
+	result *rowsResult
+	next   int
+}
+
+func (rows *rowsCursor) Columns() []string { return rows.result.columns }
+func (rows *rowsCursor) Close() error      { return nil }
+func (rows *rowsCursor) Next(dest []driver.Value) error {
+	if rows.next == len(rows.result.rows) {
+		return io.EOF
+	}
+	for i, value := range rows.result.rows[rows.next] {
+		dest[i] = value
+	}
+	rows.next++
+	return nil
+}
+
+func rowsTemplate(t *testing.T, columns []string, rows ...[]driver.Value) *DBTemplate {
+	rowsResults[t.Name()] = &rowsResult{columns: columns, rows: rows}
+	db, err := sql.Open("dbdiff-rows", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDBTemplate(db)
+}
+
+type MappedAudit struct {
+	CreatedBy string `col:"created_by"`
+}
+
+type mappedColumn struct {
+	ColumnScheme
+	*MappedAudit
+	Note     *string        `col:"NOTE"`
+	Comment  sql.NullString `col:"COLUMN_COMMENT"`
+	Position int            `col:"position"`
+}
+
+func TestDefaultRowMapper(t *testing.T) {
+	columns := []string{"TABLE_NAME", "COLUMN_NAME", "COLUMN_DEFAULT", "created_by", "note", "COLUMN_COMMENT", "position", "SRS_ID"}
+	tpl := rowsTemplate(t, columns,
+		[]driver.Value{"student", "name", nil, "admin", nil, nil, nil, nil},
+		[]driver.Value{"student", "nick", []byte(""), nil, []byte("kept"), []byte("a comment"), int64(2), int64(4326)},
+	)
+	out := []mappedColumn{}
+	if err := tpl.QueryList("select", &out); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "MapRow", "rows", len(out), 2)
+	verify(t, 2, "MapRow", "embedded", out[0].ColumnName, "name")
+	verify(t, 3, "MapRow", "null scanner", out[0].ColumnDefault, NullString{})
+	verify(t, 4, "MapRow", "empty scanner", out[1].ColumnDefault, NewNullString(""))
+	verify(t, 5, "MapRow", "embedded pointer", out[0].CreatedBy, "admin")
+	verify(t, 6, "MapRow", "null pointer", out[0].Note == nil, true)
+	verify(t, 7, "MapRow", "pointer", *out[1].Note, "kept")
+	verify(t, 8, "MapRow", "outer field wins", out[1].Comment.String, "a comment")
+	verify(t, 9, "MapRow", "shadowed", out[1].ColumnComment, "")
+	verify(t, 10, "MapRow", "null int", out[0].Position, 0)
+	verify(t, 11, "MapRow", "int", out[1].Position, 2)
+
+	//SRS_ID has no field
+	tpl.StrictMapping = true
+	_, ok := tpl.QueryList("select", &out).(*DataAccessError)
+	verify(t, 12, "MapRow", "strict", ok, true)
+
+	tpl = rowsTemplate(t, []string{"position"}, []driver.Value{"second"})
+	_, ok = tpl.QueryList("select", &[]mappedColumn{}).(*DataAccessError)
+	verify(t, 13, "MapRow", "mismatch", ok, true)
+}
@@ -0,0 +1,169 @@
+package dbdiff
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Statement is a query or an exec about to run, as seen by the hooks.
+type Statement struct {
+	Sql  string
+	Args []interface{}
+	// Exec is set for statements that do not return rows.
+	Exec bool
+	// InTransaction is set for statements of an InTransaction callback.
+	InTransaction bool
+}
+
+// StatementHook is called around every statement of a DBTemplate, to log,
+// time or refuse them. An error of Before vetoes the statement, which is not
+// run and fails with a VetoError.
+type StatementHook interface {
+	Before(ctx context.Context, statement *Statement) error
+	After(ctx context.Context, statement *Statement, duration time.Duration, err error)
+}
+
+// executor is the transaction of the template, its connection or its
+// database.
+type executor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (tpl *DBTemplate) executor() executor {
+	switch {
+	case tpl.tx != nil:
+		return tpl.tx
+	case tpl.conn != nil:
+		return tpl.conn
+	}
+	return tpl.db
+}
+
+func (tpl *DBTemplate) beginTx(ctx context.Context) (*sql.Tx, error) {
+	if tpl.conn != nil {
+		return tpl.conn.BeginTx(ctx, nil)
+	}
+	return tpl.db.BeginTx(ctx, nil)
+}
+
+func (tpl *DBTemplate) hooked(ctx context.Context, statement *Statement, run func() error) error {
+	statement.InTransaction = tpl.tx != nil
+	for _, hook := range tpl.Hooks {
+		if err := hook.Before(ctx, statement); err != nil {
+			return &VetoError{Sql: statement.Sql, Err: err}
+		}
+	}
+	start := time.Now()
+	err := run()
+	duration := time.Since(start)
+	for _, hook := range tpl.Hooks {
+		hook.After(ctx, statement, duration, err)
+	}
+	return err
+}
+
+func (tpl *DBTemplate) Exec(sql string, args ...interface{}) (sql.Result, error) {
+	return tpl.ExecContext(context.Background(), sql, args...)
+}
+
+func (tpl *DBTemplate) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := tpl.hooked(ctx, &Statement{Sql: query, Args: args, Exec: true}, func() (err error) {
+		if res, err = tpl.executor().ExecContext(ctx, query, args...); err != nil {
+			return queryError(ctx, query, &DataAccessError{Message: "Db exec error", Err: err})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (tpl *DBTemplate) ExecBatch(sqls []string) ([]sql.Result, error) {
+	return tpl.ExecBatchContext(context.Background(), sqls)
+}
+
+// ExecBatchContext runs sqls in order and stops at the first failure. It
+// returns the results of the statements that ran, wrap it in InTransaction
+// to apply all of them or none.
+func (tpl *DBTemplate) ExecBatchContext(ctx context.Context, sqls []string) ([]sql.Result, error) {
+	results := make([]sql.Result, 0, len(sqls))
+	for i, query := range sqls {
+		res, err := tpl.ExecContext(ctx, query)
+		if err != nil {
+			return results, &DataAccessError{Message: fmt.Sprintf("batch statement %d of %d error", i+1, len(sqls)), Err: err}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (tpl *DBTemplate) InTransaction(fn func(tx Operations) error) error {
+	return tpl.InTransactionContext(context.Background(), fn)
+}
+
+// InTransactionContext runs fn with a template bound to a new transaction,
+// with the options and hooks of tpl. The transaction is committed when fn
+// returns nil and rolled back when it fails or panics, the panic going on.
+// Called inside a transaction, it runs fn within a savepoint instead, so that
+// a failure only rolls back what fn did.
+func (tpl *DBTemplate) InTransactionContext(ctx context.Context, fn func(tx Operations) error) (err error) {
+	if tpl.tx != nil {
+		return tpl.inSavepoint(ctx, fn)
+	}
+	tx, err := tpl.beginTx(ctx)
+	if err != nil {
+		return queryError(ctx, "BEGIN", &DataAccessError{Message: "begin transaction error", Err: err})
+	}
+	txTpl := *tpl
+	txTpl.tx = tx
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err = fn(&txTpl); err != nil {
+		//a canceled context already rolled the transaction back
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			return &RollbackError{Err: err, RollbackErr: rollbackErr}
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return &DataAccessError{Message: "commit transaction error", Err: err}
+	}
+	return nil
+}
+
+func (tpl *DBTemplate) inSavepoint(ctx context.Context, fn func(tx Operations) error) error {
+	spTpl := *tpl
+	spTpl.savepoint++
+	name := fmt.Sprintf("dbdiff_sp_%d", spTpl.savepoint)
+	//savepoints are not statements of the caller, hooks do not see them
+	if _, err := tpl.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return &DataAccessError{Message: "savepoint error", Err: err}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tpl.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(r)
+		}
+	}()
+	if err := fn(&spTpl); err != nil {
+		if _, rollbackErr := tpl.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return &RollbackError{Err: err, RollbackErr: rollbackErr}
+		}
+		return err
+	}
+	if _, err := tpl.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return &DataAccessError{Message: "release savepoint error", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package dbdiff
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingHook logs every statement and vetoes those containing veto.
+type recordingHook struct {
+	log []string
+}
+
+func (hook *recordingHook) Before(ctx context.Context, statement *Statement) error {
+	if strings.Contains(statement.Sql, "veto") {
+		return errors.New("not allowed")
+	}
+	return nil
+}
+
+func (hook *recordingHook) After(ctx context.Context, statement *Statement, duration time.Duration, err error) {
+	entry := statement.Sql
+	if statement.InTransaction {
+		entry += " (tx)"
+	}
+	if err != nil {
+		entry += " failed"
+	}
+	hook.log = append(hook.log, entry)
+}
+
+func TestDBTemplate_InTransaction(t *testing.T) {
+	tpl := rowsTemplate(t, []string{"id", "name"}, []driver.Value{int64(1), "ann"})
+	result := rowsResults[t.Name()]
+	hook := &recordingHook{}
+	tpl.Hooks = []StatementHook{hook}
+
+	students := []Student{}
+	err := tpl.InTransaction(func(tx Operations) error {
+		if _, err := tx.Exec("insert 1"); err != nil {
+			return err
+		}
+		return tx.QueryList("select", &students)
+	})
+	verify(t, 1, "InTransaction", "commit", err, nil)
+	verify(t, 2, "InTransaction", "mapped", students[0].Name, "ann")
+	verify(t, 3, "InTransaction", "log", strings.Join(result.log, ";"), "BEGIN;insert 1;select;COMMIT")
+	verify(t, 4, "InTransaction", "hooks", strings.Join(hook.log, ";"), "insert 1 (tx);select (tx)")
+
+	result.log = nil
+	err = tpl.InTransaction(func(tx Operations) error {
+		tx.Exec("insert 2")
+		_, err := tx.Exec("insert fail")
+		return err
+	})
+	_, ok := err.(*DataAccessError)
+	verify(t, 5, "InTransaction", "exec error", ok, true)
+	verify(t, 6, "InTransaction", "rollback", strings.Join(result.log, ";"), "BEGIN;insert 2;insert fail;ROLLBACK")
+
+	result.log = nil
+	func() {
+		defer func() {
+			verify(t, 7, "InTransaction", "panic", recover(), "boom")
+		}()
+		tpl.InTransaction(func(tx Operations) error {
+			panic("boom")
+		})
+	}()
+	verify(t, 8, "InTransaction", "panic rollback", strings.Join(result.log, ";"), "BEGIN;ROLLBACK")
+
+	//a nested transaction is a savepoint, its failure keeps the outer one
+	result.log = nil
+	err = tpl.InTransaction(func(tx Operations) error {
+		tx.InTransaction(func(tx Operations) error {
+			_, err := tx.Exec("insert fail")
+			return err
+		})
+		return tx.InTransaction(func(tx Operations) error {
+			_, err := tx.Exec("insert 3")
+			return err
+		})
+	})
+	verify(t, 9, "InTransaction", "savepoint", err, nil)
+	verify(t, 10, "InTransaction", "savepoint log", strings.Join(result.log, ";"),
+		"BEGIN;SAVEPOINT dbdiff_sp_1;insert fail;ROLLBACK TO SAVEPOINT dbdiff_sp_1;SAVEPOINT dbdiff_sp_1;insert 3;RELEASE SAVEPOINT dbdiff_sp_1;COMMIT")
+}
+
+func TestDBTemplate_Exec(t *testing.T) {
+	tpl := rowsTemplate(t, nil)
+	result := rowsResults[t.Name()]
+	hook := &recordingHook{}
+	tpl.Hooks = []StatementHook{hook}
+
+	res, err := tpl.Exec("update 1")
+	verify(t, 1, "Exec", "err", err, nil)
+	affected, _ := res.RowsAffected()
+	verify(t, 2, "Exec", "affected", affected, int64(1))
+
+	_, err = tpl.Exec("update veto")
+	_, ok := err.(*VetoError)
+	verify(t, 3, "Exec", "veto", ok, true)
+
+	results, err := tpl.ExecBatch([]string{"update 2", "update fail", "update 3"})
+	verify(t, 4, "ExecBatch", "results", len(results), 1)
+	verify(t, 5, "ExecBatch", "err", err.Error(), "access data error:batch statement 2 of 3 error with access data error:Db exec error with exec failed")
+	verify(t, 6, "Exec", "log", strings.Join(result.log, ";"), "update 1;update 2;update fail")
+	verify(t, 7, "Exec", "hooks", strings.Join(hook.log, ";"), "update 1;update 2;update fail failed")
+}
@@ -0,0 +1,509 @@
+package dbdiff
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHTunnel reaches the database through a bastion host. The tunnel holds one
+// ssh client, connected at the first connection of the pool, and every
+// connection is a channel forwarded by the bastion to the database, so the
+// handshake happens once however many connections the pool opens.
+type SSHTunnel struct {
+	// Host is the bastion, Port defaults to 22 and User to the local user.
+	Host string
+	Port int
+	User string
+	// KeyFile is the only identity offered when set. Passphrase decrypts it,
+	// see PromptPassphrase.
+	KeyFile    string
+	Passphrase string
+	// AgentSocket is the ssh-agent socket, $SSH_AUTH_SOCK when empty and
+	// "none" to disable the agent. Without KeyFile, the agent keys and the
+	// unencrypted default keys of ~/.ssh are offered.
+	AgentSocket string
+	// KnownHostsFile verifies the host keys instead of ~/.ssh/known_hosts.
+	// Unknown host keys are always refused, unless InsecureIgnoreHostKey.
+	KnownHostsFile        string
+	InsecureIgnoreHostKey bool
+	// Jump are the hosts, [user@]host[:port], crossed in order before Host.
+	// They are verified and authenticated with the same options.
+	Jump []string
+}
+
+// ParseSSHTunnel reads a tunnel from [user@]host[:port].
+func ParseSSHTunnel(destination string) (*SSHTunnel, error) {
+	hop, err := parseSSHHop(destination)
+	if err != nil {
+		return nil, err
+	}
+	return &SSHTunnel{Host: hop.host, Port: hop.port, User: hop.user}, nil
+}
+
+type sshHop struct {
+	user string
+	host string
+	port int
+}
+
+func parseSSHHop(destination string) (sshHop, error) {
+	hop := sshHop{host: destination}
+	if i := strings.LastIndex(hop.host, "@"); i >= 0 {
+		hop.user, hop.host = hop.host[:i], hop.host[i+1:]
+	}
+	if host, port, err := net.SplitHostPort(hop.host); err == nil {
+		if hop.port, err = strconv.Atoi(port); err != nil {
+			return hop, &DBConnError{Message: "invalid ssh port in " + destination}
+		}
+		hop.host = host
+	}
+	if hop.host == "" {
+		return hop, &DBConnError{Message: "missing ssh host in " + destination}
+	}
+	return hop, nil
+}
+
+func (hop sshHop) addr() string {
+	port := hop.port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(hop.host, strconv.Itoa(port))
+}
+
+// hops are the jump hosts, then the bastion.
+func (tunnel *SSHTunnel) hops() ([]sshHop, error) {
+	if tunnel.Host == "" {
+		return nil, &DBConnError{Message: "missing ssh host"}
+	}
+	var hops []sshHop
+	for _, jump := range tunnel.Jump {
+		hop, err := parseSSHHop(jump)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, hop)
+	}
+	return append(hops, sshHop{user: tunnel.User, host: tunnel.Host, port: tunnel.Port}), nil
+}
+
+// sshTunnels are the tunnels registered with the mysql driver by network
+// name, one dialer per tunnel whatever the number of contexts.
+var sshTunnels = struct {
+	sync.Mutex
+	byName map[string]*sshRoutes
+	last   int
+}{byName: map[string]*sshRoutes{}}
+
+// sshRoutes are the sessions of a registered tunnel: the shared one and the
+// ones of the contexts, which addresses end with "#<route>".
+type sshRoutes struct {
+	shared   *sshSession
+	sessions map[string]*sshSession
+}
+
+// register registers the dialer of the tunnel with the mysql driver and
+// returns its network name and the suffix of the addresses dialed for ctx.
+// Without a deadline nor a cancellation, the connections of equal tunnels
+// share one client. Otherwise the client belongs to ctx and is closed when
+// ctx is done.
+func (tunnel *SSHTunnel) register(ctx context.Context) (string, string, error) {
+	if _, err := tunnel.hops(); err != nil {
+		return "", "", err
+	}
+	copied := *tunnel
+	copied.Jump = append([]string{}, tunnel.Jump...)
+	name := fmt.Sprintf("dbdiff-ssh-%x", sha256.Sum256([]byte(fmt.Sprintf("%+v", copied))))[:27]
+
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	routes, ok := sshTunnels.byName[name]
+	if !ok {
+		routes = &sshRoutes{sessions: map[string]*sshSession{}}
+		sshTunnels.byName[name] = routes
+		mysql.RegisterDial(name, routes.dial)
+	}
+	if ctx.Done() == nil {
+		if routes.shared == nil {
+			routes.shared = &sshSession{tunnel: copied, ctx: ctx}
+		}
+		return name, "", nil
+	}
+	sshTunnels.last++
+	route := strconv.Itoa(sshTunnels.last)
+	session := &sshSession{tunnel: copied, ctx: ctx}
+	routes.sessions[route] = session
+	context.AfterFunc(ctx, func() {
+		sshTunnels.Lock()
+		delete(routes.sessions, route)
+		sshTunnels.Unlock()
+		session.close()
+	})
+	return name, "#" + route, nil
+}
+
+// dial dials addr through the session its suffix routes to.
+func (routes *sshRoutes) dial(addr string) (net.Conn, error) {
+	sshTunnels.Lock()
+	session := routes.shared
+	if i := strings.LastIndex(addr, "#"); i >= 0 {
+		session = routes.sessions[addr[i+1:]]
+		addr = addr[:i]
+	}
+	sshTunnels.Unlock()
+	if session == nil {
+		return nil, &DBConnError{Message: "ssh tunnel to " + addr, Err: net.ErrClosed}
+	}
+	return session.Dial(addr)
+}
+
+// Dial connects a client to the bastion and forwards a channel to addr, as
+// seen from the bastion. The connection closes the client.
+func (tunnel *SSHTunnel) Dial(addr string) (net.Conn, error) {
+	return tunnel.DialContext(context.Background(), addr)
+}
+
+// DialContext is Dial giving up when ctx is done.
+func (tunnel *SSHTunnel) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	if _, err := tunnel.hops(); err != nil {
+		return nil, err
+	}
+	session := &sshSession{tunnel: *tunnel, ctx: ctx}
+	return session.Dial(addr)
+}
+
+// sshSession dials through one client, connected again after it dies. The
+// client is closed with its last channel.
+type sshSession struct {
+	tunnel SSHTunnel
+	ctx    context.Context
+
+	mu     sync.Mutex
+	chain  *sshChain
+	closed bool
+}
+
+// sshChain are the clients of the jump hosts and of the bastion, each one
+// reached through the previous one.
+type sshChain struct {
+	clients  []*ssh.Client
+	agent    net.Conn
+	channels int
+}
+
+func (chain *sshChain) bastion() *ssh.Client {
+	return chain.clients[len(chain.clients)-1]
+}
+
+func (chain *sshChain) close() {
+	for i := len(chain.clients) - 1; i >= 0; i-- {
+		chain.clients[i].Close()
+	}
+	if chain.agent != nil {
+		chain.agent.Close()
+	}
+}
+
+func (session *sshSession) Dial(addr string) (net.Conn, error) {
+	chain, err := session.acquire()
+	if err != nil {
+		return nil, err
+	}
+	channel, err := chain.bastion().DialContext(session.ctx, "tcp", addr)
+	if err != nil {
+		session.release(chain)
+		if ctxErr := session.ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, &DBConnError{Message: "ssh tunnel to " + addr, Err: err}
+	}
+	return &sshConn{Conn: channel, session: session, chain: chain}, nil
+}
+
+// acquire returns the live chain, connecting it first, counting the channel
+// about to be opened.
+func (session *sshSession) acquire() (*sshChain, error) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.closed || session.ctx.Err() != nil {
+		err := session.ctx.Err()
+		if err == nil {
+			err = net.ErrClosed
+		}
+		return nil, &DBConnError{Message: "ssh tunnel to " + session.tunnel.Host, Err: err}
+	}
+	if session.chain == nil {
+		chain, err := session.tunnel.connect(session.ctx)
+		if err != nil {
+			return nil, err
+		}
+		session.chain = chain
+		go func() {
+			//a dead client is connected again at the next dial
+			chain.bastion().Wait()
+			session.mu.Lock()
+			if session.chain == chain {
+				session.chain = nil
+			}
+			session.mu.Unlock()
+		}()
+	}
+	session.chain.channels++
+	return session.chain, nil
+}
+
+func (session *sshSession) release(chain *sshChain) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	chain.channels--
+	if chain.channels == 0 {
+		if session.chain == chain {
+			session.chain = nil
+		}
+		chain.close()
+	}
+}
+
+func (session *sshSession) close() {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.closed = true
+	if session.chain != nil {
+		session.chain.close()
+		session.chain = nil
+	}
+}
+
+// connect connects the clients of the jump hosts, then of the bastion.
+func (tunnel *SSHTunnel) connect(ctx context.Context) (*sshChain, error) {
+	hops, err := tunnel.hops()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := tunnel.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	chain := &sshChain{}
+	auth, err := tunnel.authMethods(chain)
+	if err != nil {
+		chain.close()
+		return nil, err
+	}
+	localUser := ""
+	if current, err := user.Current(); err == nil {
+		localUser = current.Username
+	}
+
+	for _, hop := range hops {
+		addr := hop.addr()
+		var conn net.Conn
+		if len(chain.clients) == 0 {
+			conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		} else {
+			conn, err = chain.bastion().DialContext(ctx, "tcp", addr)
+		}
+		if err != nil {
+			chain.close()
+			return nil, &DBConnError{Message: "ssh tunnel: dial " + addr, Err: contextError(ctx, err)}
+		}
+		config := &ssh.ClientConfig{User: hop.user, Auth: auth, HostKeyCallback: hostKeys}
+		if config.User == "" {
+			config.User = localUser
+		}
+		client, err := handshake(ctx, conn, addr, config)
+		if err != nil {
+			chain.close()
+			return nil, &DBConnError{Message: "ssh tunnel: handshake with " + addr, Err: err}
+		}
+		chain.clients = append(chain.clients, client)
+	}
+	return chain, nil
+}
+
+// handshake runs the ssh handshake on conn, which is closed when ctx is done
+// first.
+func handshake(ctx context.Context, conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	//the channels of the jump hosts have no deadlines, closing works for both
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() || err != nil {
+		conn.Close()
+		return nil, contextError(ctx, err)
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// contextError prefers the error of ctx, when done, to the error of the
+// connection it closed.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (tunnel *SSHTunnel) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if tunnel.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	path := tunnel.KnownHostsFile
+	if path == "" {
+		path = "~/.ssh/known_hosts"
+	}
+	callback, err := knownhosts.New(expandHome(path))
+	if err != nil {
+		return nil, &DBConnError{Message: "read ssh known hosts", Err: err}
+	}
+	return callback, nil
+}
+
+// authMethods offers KeyFile alone when set, otherwise the keys of the agent,
+// which connection is kept by chain, and the default keys.
+func (tunnel *SSHTunnel) authMethods(chain *sshChain) ([]ssh.AuthMethod, error) {
+	if tunnel.KeyFile != "" {
+		signer, err := tunnel.signer()
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	var auth []ssh.AuthMethod
+	socket := tunnel.AgentSocket
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket != "" && socket != "none" {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, &DBConnError{Message: "connect ssh agent", Err: err}
+		}
+		chain.agent = conn
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		pem, err := ioutil.ReadFile(expandHome("~/.ssh/" + name))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(pem); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	return auth, nil
+}
+
+// signer reads KeyFile, decrypted by Passphrase.
+func (tunnel *SSHTunnel) signer() (ssh.Signer, error) {
+	pem, err := ioutil.ReadFile(expandHome(tunnel.KeyFile))
+	if err != nil {
+		return nil, &DBConnError{Message: "read ssh key", Err: err}
+	}
+	var signer ssh.Signer
+	if tunnel.Passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(tunnel.Passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pem)
+	}
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		return nil, &DBConnError{Message: "ssh key " + tunnel.KeyFile + " needs a passphrase"}
+	}
+	if err != nil {
+		return nil, &DBConnError{Message: "parse ssh key " + tunnel.KeyFile, Err: err}
+	}
+	return signer, nil
+}
+
+// needsPassphrase reports whether KeyFile is encrypted and Passphrase is
+// missing. A missing or broken key is reported when connecting.
+func (tunnel *SSHTunnel) needsPassphrase() bool {
+	if tunnel.KeyFile == "" || tunnel.Passphrase != "" {
+		return false
+	}
+	pem, err := ioutil.ReadFile(expandHome(tunnel.KeyFile))
+	if err != nil {
+		return false
+	}
+	_, err = ssh.ParsePrivateKey(pem)
+	_, missing := err.(*ssh.PassphraseMissingError)
+	return missing
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// sshConn is a channel forwarded by the bastion. The channels have no
+// deadlines, a deadline closes the connection instead.
+type sshConn struct {
+	net.Conn
+	session *sshSession
+	chain   *sshChain
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	closed bool
+}
+
+func (conn *sshConn) Close() error {
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return nil
+	}
+	conn.closed = true
+	if conn.timer != nil {
+		conn.timer.Stop()
+	}
+	conn.mu.Unlock()
+	err := conn.Conn.Close()
+	conn.session.release(conn.chain)
+	return err
+}
+
+func (conn *sshConn) SetDeadline(t time.Time) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.timer != nil {
+		conn.timer.Stop()
+		conn.timer = nil
+	}
+	if !t.IsZero() && !conn.closed {
+		conn.timer = time.AfterFunc(time.Until(t), func() {
+			conn.Conn.Close()
+		})
+	}
+	return nil
+}
+
+func (conn *sshConn) SetReadDeadline(t time.Time) error  { return conn.SetDeadline(t) }
+func (conn *sshConn) SetWriteDeadline(t time.Time) error { return conn.SetDeadline(t) }
@@ -0,0 +1,284 @@
+package dbdiff
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer is a bastion forwarding direct-tcpip channels, which accepts the
+// key authorized and counts its handshakes.
+type sshServer struct {
+	addr       string
+	hostKey    ssh.Signer
+	handshakes int32
+	listener   net.Listener
+}
+
+func newSSHServer(t *testing.T, authorized ssh.PublicKey) *sshServer {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	hostKey, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+		if string(key.Marshal()) != string(authorized.Marshal()) {
+			return nil, errors.New("unauthorized")
+		}
+		return nil, nil
+	}}
+	config.AddHostKey(hostKey)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &sshServer{addr: listener.Addr().String(), hostKey: hostKey, listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server
+}
+
+func (server *sshServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	atomic.AddInt32(&server.handshakes, 1)
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if newChannel.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+			newChannel.Reject(ssh.UnknownChannelType, "direct-tcpip only")
+			continue
+		}
+		forwarded, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, _ := newChannel.Accept()
+		go ssh.DiscardRequests(requests)
+		go func() {
+			io.Copy(channel, forwarded)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(forwarded, channel)
+			forwarded.Close()
+		}()
+	}
+}
+
+func (server *sshServer) knownHosts(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey.PublicKey())
+	if err := ioutil.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (server *sshServer) tunnel(knownHostsFile, keyFile string) *SSHTunnel {
+	host, port, _ := net.SplitHostPort(server.addr)
+	tunnel := &SSHTunnel{Host: host, User: "deploy", KeyFile: keyFile, KnownHostsFile: knownHostsFile}
+	tunnel.Port, _ = strconv.Atoi(port)
+	return tunnel
+}
+
+func echoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(conn, conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func writeKey(t *testing.T, dir, name string, key ed25519.PrivateKey, passphrase string) string {
+	var block *pem.Block
+	var err error
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func ping(conn net.Conn) string {
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	io.ReadFull(conn, buf)
+	return string(buf)
+}
+
+func TestSSHTunnel_Dial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	authorized, _ := ssh.NewPublicKey(public)
+	server := newSSHServer(t, authorized)
+	defer server.listener.Close()
+	db := echoServer(t)
+	knownHosts := server.knownHosts(t, dir)
+	tunnel := server.tunnel(knownHosts, writeKey(t, dir, "id_ed25519", private, ""))
+
+	//the connections of a session share one client
+	session := &sshSession{tunnel: *tunnel, ctx: context.Background()}
+	first, err := session.Dial(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := session.Dial(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 1, "Dial", "stream", ping(first)+ping(second), "pingping")
+	verify(t, 2, "Dial", "one handshake", atomic.LoadInt32(&server.handshakes), int32(1))
+	first.Close()
+	second.Close()
+	third, err := session.Dial(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 3, "Dial", "closed with the last channel", atomic.LoadInt32(&server.handshakes), int32(2))
+	third.Close()
+
+	//each jump host is one more client
+	jumped := server.tunnel(knownHosts, tunnel.KeyFile)
+	jumped.Jump = []string{"deploy@" + server.addr}
+	conn, err := jumped.Dial(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 4, "Dial", "jump", ping(conn), "ping")
+	verify(t, 5, "Dial", "jump handshakes", atomic.LoadInt32(&server.handshakes), int32(4))
+	conn.Close()
+
+	//unknown host keys are refused
+	other := newSSHServer(t, authorized)
+	defer other.listener.Close()
+	_, err = other.tunnel(knownHosts, tunnel.KeyFile).Dial(db)
+	verify(t, 6, "Dial", "unknown host key", err != nil && strings.Contains(err.Error(), "knownhosts: key is unknown"), true)
+
+	//encrypted keys are decrypted with the passphrase
+	encrypted := server.tunnel(knownHosts, writeKey(t, dir, "id_encrypted", private, "secret"))
+	_, err = encrypted.Dial(db)
+	verify(t, 7, "Dial", "missing passphrase", err.Error(), "connection error:ssh key "+encrypted.KeyFile+" needs a passphrase")
+	verify(t, 8, "needsPassphrase", "encrypted", encrypted.needsPassphrase(), true)
+	os.Setenv("DBDIFF_TEST_SSH_PASSPHRASE", "secret")
+	defer os.Unsetenv("DBDIFF_TEST_SSH_PASSPHRASE")
+	(&EnvCredentials{PassphraseVar: "DBDIFF_TEST_SSH_PASSPHRASE"}).Resolve(&DBConn{SSH: encrypted})
+	conn, err = encrypted.Dial(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, 9, "Dial", "passphrase", ping(conn), "ping")
+	conn.Close()
+	verify(t, 10, "needsPassphrase", "unencrypted", tunnel.needsPassphrase(), false)
+}
+
+func TestSSHTunnel_DialContext(t *testing.T) {
+	//a bastion that never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	tunnel := &SSHTunnel{Host: host, InsecureIgnoreHostKey: true, AgentSocket: "none"}
+	tunnel.Port, _ = strconv.Atoi(port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = tunnel.DialContext(ctx, "db:3306")
+	verify(t, 1, "DialContext", "deadline", err.(*DBConnError).Err, context.DeadlineExceeded)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = tunnel.DialContext(ctx, "db:3306")
+	verify(t, 2, "DialContext", "canceled", err.(*DBConnError).Err, context.Canceled)
+
+	//a tunnel registered for a context refuses to dial once it is done
+	ctx, cancel = context.WithCancel(context.Background())
+	name, route, _ := tunnel.register(ctx)
+	otherName, otherRoute, _ := tunnel.register(context.Background())
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	sshTunnels.Lock()
+	routes := sshTunnels.byName[name]
+	_, routed := routes.sessions[strings.TrimPrefix(route, "#")]
+	sshTunnels.Unlock()
+	verify(t, 3, "register", "released", routed, false)
+	verify(t, 3, "register", "one dialer", otherName, name)
+	verify(t, 3, "register", "shared route", otherRoute, "")
+	_, err = routes.dial("db:3306" + route)
+	verify(t, 3, "dial", "released", err.(*DBConnError).Err, net.ErrClosed)
+
+	_, err = (&SSHTunnel{Host: "bastion", Jump: []string{"edge:ssh"}}).DialContext(context.Background(), "db:3306")
+	_, ok := err.(*DBConnError)
+	verify(t, 4, "DialContext", "invalid jump", ok, true)
+	_, err = (&SSHTunnel{}).Dial("db:3306")
+	verify(t, 5, "Dial", "missing host", err != nil, true)
+
+	//the connection url goes through the registered dialer
+	connUrl, _ := (&DBConn{DriverName: MYSQL, Username: "app", Ip: "db", Port: 3306, DBName: "app", SSH: tunnel}).ConnUrl()
+	verify(t, 6, "ConnUrl", "ssh", strings.HasPrefix(connUrl, "app@dbdiff-ssh-") && strings.HasSuffix(connUrl, "(db:3306)/app"), true)
+
+	connUrl, _ = (&DBConn{DriverName: MYSQL, Username: "app", Ip: "db", Port: 3306, DBName: "app", SSH: tunnel}).connUrl(ctx)
+	verify(t, 7, "connUrl", "routed", strings.Contains(connUrl, "(db:3306#"), true)
+}
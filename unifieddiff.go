@@ -0,0 +1,190 @@
+package dbdiff
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const DefaultDiffContext = 3
+
+var (
+	autoIncrementRegexp = regexp.MustCompile(`\s+AUTO_INCREMENT=\d+`)
+	displayWidthRegexp  = regexp.MustCompile(`\b(tinyint|smallint|mediumint|int|bigint)\((\d+)\)((\s+unsigned)?\s+zerofill)?`)
+	utf8mb3Regexp       = regexp.MustCompile(`\butf8mb3`)
+)
+
+// NormalizeCreateTable strips the volatile AUTO_INCREMENT=N clause from a
+// SHOW CREATE TABLE statement, together with the version differences the
+// normalizer hides for columns: integer display widths and utf8mb3.
+func (normalizer *Normalizer) NormalizeCreateTable(createTableSql string) string {
+	createTableSql = autoIncrementRegexp.ReplaceAllString(createTableSql, "")
+	if normalizer == nil {
+		return createTableSql
+	}
+	createTableSql = utf8mb3Regexp.ReplaceAllString(createTableSql, "utf8")
+	if !normalizer.KeepDisplayWidth {
+		createTableSql = displayWidthRegexp.ReplaceAllStringFunc(createTableSql, func(match string) string {
+			parts := displayWidthRegexp.FindStringSubmatch(match)
+			if parts[3] != "" || parts[1] == "tinyint" && parts[2] == "1" {
+				return match
+			}
+			return parts[1]
+		})
+	}
+	return createTableSql
+}
+
+// createTableDiff is the unified diff of the normalized CREATE TABLE
+// statements of a table present on both sides, empty when they are equal.
+func (normalizer *Normalizer) createTableDiff(diffTable *DiffTable) string {
+	var (
+		old = normalizer.NormalizeCreateTable(diffTable.TableOld.CreateTableSql)
+		new = normalizer.NormalizeCreateTable(diffTable.TableNew.CreateTableSql)
+	)
+	if old == new {
+		return ""
+	}
+	return UnifiedDiff("old/"+diffTable.TableName, "new/"+diffTable.TableName, old, new, DefaultDiffContext)
+}
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// UnifiedDiff compares two texts line by line and renders the differences in
+// the format of diff -u, with context lines around every hunk.
+func UnifiedDiff(nameOld, nameNew, old, new string, context int) string {
+	var (
+		linesOld = splitLines(old)
+		linesNew = splitLines(new)
+		ops      = diffLines(linesOld, linesNew)
+		buff     bytes.Buffer
+	)
+	changed := false
+	for _, op := range ops {
+		changed = changed || op.kind != ' '
+	}
+	if !changed {
+		return ""
+	}
+	fmt.Fprintf(&buff, "--- %s\n+++ %s\n", nameOld, nameNew)
+
+	for start := 0; start < len(ops); {
+		//find the next change and the end of its hunk
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		from := first - context
+		if from < start {
+			from = start
+		}
+		to, equal := first, 0
+		for to < len(ops) && equal <= 2*context {
+			if ops[to].kind == ' ' {
+				equal++
+			} else {
+				equal = 0
+			}
+			to++
+		}
+		if equal > context {
+			to -= equal - context
+		}
+
+		lineOld, lineNew := 1, 1
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				lineOld++
+			}
+			if op.kind != '-' {
+				lineNew++
+			}
+		}
+		countOld, countNew := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				countOld++
+			}
+			if op.kind != '-' {
+				countNew++
+			}
+		}
+		fmt.Fprintf(&buff, "@@ -%s +%s @@\n", hunkRange(lineOld, countOld), hunkRange(lineNew, countNew))
+		for _, op := range ops[from:to] {
+			buff.WriteByte(op.kind)
+			buff.WriteString(op.line)
+			buff.WriteByte('\n')
+		}
+		start = to
+	}
+	return buff.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		//diff -u names the line before an empty range
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// diffLines finds the longest common subsequence of the two texts and lists
+// the lines to keep, remove (-) and add (+).
+func diffLines(old, new []string) []diffOp {
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			ops = append(ops, diffOp{' ', old[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', old[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', new[j]})
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		ops = append(ops, diffOp{'-', old[i]})
+	}
+	for ; j < len(new); j++ {
+		ops = append(ops, diffOp{'+', new[j]})
+	}
+	return ops
+}
@@ -0,0 +1,73 @@
+package dbdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+`
+	verify(t, 1, "UnifiedDiff", "two hunks", UnifiedDiff("old", "new", old, new, 3), expected)
+	verify(t, 2, "UnifiedDiff", "equal", UnifiedDiff("old", "new", old, old, 3), "")
+
+	expected = `--- old
++++ new
+@@ -0,0 +1 @@
++a
+`
+	verify(t, 3, "UnifiedDiff", "empty old", UnifiedDiff("old", "new", "", "a", 3), expected)
+
+	expected = `--- old
++++ new
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+`
+	verify(t, 4, "UnifiedDiff", "context 1", UnifiedDiff("old", "new", "a\nb\nc\nd\ne", "a\nB\nc\nd\ne", 1), expected)
+}
+
+func TestNormalizeCreateTable(t *testing.T) {
+	var (
+		normalizer = NewNormalizer()
+		mysql57    = "CREATE TABLE `student` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  `flag` tinyint(1) DEFAULT NULL,\n" +
+			"  `code` int(5) unsigned zerofill DEFAULT NULL\n) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8"
+		mysql80 = "CREATE TABLE `student` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  `flag` tinyint(1) DEFAULT NULL,\n" +
+			"  `code` int(5) unsigned zerofill DEFAULT NULL\n) ENGINE=InnoDB AUTO_INCREMENT=7 DEFAULT CHARSET=utf8mb3"
+	)
+	verify(t, 1, "NormalizeCreateTable", "versions", normalizer.NormalizeCreateTable(mysql57), normalizer.NormalizeCreateTable(mysql80))
+	verify(t, 2, "NormalizeCreateTable", "auto increment", strings.Contains(normalizer.NormalizeCreateTable(mysql57), "AUTO_INCREMENT=42"), false)
+	verify(t, 3, "NormalizeCreateTable", "column auto increment", strings.Contains(normalizer.NormalizeCreateTable(mysql57), "NOT NULL AUTO_INCREMENT,"), true)
+
+	diffTable := &DiffTable{
+		TableName: "student",
+		TableOld:  &Table{CreateTableSql: mysql57},
+		TableNew:  &Table{CreateTableSql: strings.Replace(mysql80, "DEFAULT NULL\n)", "DEFAULT NULL,\n  `name` varchar(64) DEFAULT NULL\n)", 1)},
+	}
+	expected := "--- old/student\n+++ new/student\n@@ -1,5 +1,6 @@\n" +
+		" CREATE TABLE `student` (\n" +
+		"   `id` int NOT NULL AUTO_INCREMENT,\n" +
+		"   `flag` tinyint(1) DEFAULT NULL,\n" +
+		"-  `code` int(5) unsigned zerofill DEFAULT NULL\n" +
+		"+  `code` int(5) unsigned zerofill DEFAULT NULL,\n" +
+		"+  `name` varchar(64) DEFAULT NULL\n" +
+		" ) ENGINE=InnoDB DEFAULT CHARSET=utf8\n"
+	verify(t, 4, "createTableDiff", "student", normalizer.createTableDiff(diffTable), expected)
+}